@@ -79,7 +79,11 @@ func (c *Config) runAddCmd(cmd *cobra.Command, args []string) (err error) {
 				if err != nil {
 					return err
 				}
-				if ts.TargetIgnore.Match(strings.TrimPrefix(path, destDirPrefix)) {
+				targetName := strings.TrimPrefix(path, destDirPrefix)
+				if info.IsDir() {
+					targetName = chezmoi.AsDir(targetName)
+				}
+				if ts.TargetIgnore.Match(targetName) {
 					cmd.Printf("warning: %s: skipping file ignored by .chezmoiignore\n", path)
 					return nil
 				}
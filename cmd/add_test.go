@@ -38,6 +38,43 @@ func TestAddAfterModification(t *testing.T) {
 	)
 }
 
+func TestAddBaseSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":                                         "# contents of .bashrc\n",
+			".config/htop/htoprc":                             "# contents of .config/htop/htoprc\n",
+			".local/share/chezmoi":                            &vfst.Dir{Perm: 0700},
+			".local/share/chezmoi-base":                       &vfst.Dir{Perm: 0700},
+			".local/share/chezmoi-base/dot_bashrc":            "# base .bashrc\n",
+			".local/share/chezmoi-base/dot_config/htop/.keep": "",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	c := newTestConfig(
+		fs,
+		withBaseSourceDirs([]string{"/home/user/.local/share/chezmoi-base"}),
+	)
+	assert.NoError(t, c.runAddCmd(nil, []string{"/home/user/.bashrc", "/home/user/.config/htop/htoprc"}))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_config/htop/htoprc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .config/htop/htoprc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi-base/dot_bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# base .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi-base/dot_config/htop/htoprc",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
 func TestAddCommand(t *testing.T) {
 	for _, tc := range []struct {
 		name   string
@@ -292,6 +329,41 @@ func TestAddCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "add_linked_file",
+			args: []string{"/home/user/.vimrc"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+				"/home/user/.vimrc": &vfst.Symlink{Target: ".local/share/chezmoi/link_dot_vimrc"},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/link_dot_vimrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("set nocompatible\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/symlink_dot_vimrc",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
+		{
+			name:   "add_linked_file_follow",
+			args:   []string{"/home/user/.vimrc"},
+			follow: true,
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+				"/home/user/.vimrc": &vfst.Symlink{Target: ".local/share/chezmoi/link_dot_vimrc"},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/link_dot_vimrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("set nocompatible\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/dot_vimrc",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
 		{
 			name:   "add_followed_symlink",
 			args:   []string{"/home/user/foo"},
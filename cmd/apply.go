@@ -1,7 +1,16 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
 	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	vfs "github.com/twpayne/go-vfs"
 )
 
 var applyCmd = &cobra.Command{
@@ -13,9 +22,23 @@ var applyCmd = &cobra.Command{
 	RunE:    config.runApplyCmd,
 }
 
+type applyCmdConfig struct {
+	force       bool
+	interactive bool
+	merge       bool
+}
+
+// errApplyQuit is returned when the user chooses to quit an interactive apply.
+var errApplyQuit = errors.New("quit")
+
 func init() {
 	rootCmd.AddCommand(applyCmd)
 
+	persistentFlags := applyCmd.PersistentFlags()
+	persistentFlags.BoolVar(&config.apply.force, "force", false, "overwrite files changed since they were last written")
+	persistentFlags.BoolVarP(&config.apply.interactive, "interactive", "i", false, "prompt before applying each target")
+	persistentFlags.BoolVar(&config.apply.merge, "merge", false, "merge files changed since they were last written")
+
 	markRemainingZshCompPositionalArgumentsAsFiles(applyCmd, 1)
 }
 
@@ -26,5 +49,113 @@ func (c *Config) runApplyCmd(cmd *cobra.Command, args []string) error {
 	}
 	defer persistentState.Close()
 
-	return c.applyArgs(args, persistentState)
+	// In a dry run, print the targets that would be removed, as they are
+	// otherwise only printed with --verbose.
+	if c.Remove && c.DryRun && !c.Verbose && len(args) == 0 {
+		if err := c.printTargetsToRemove(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.applyArgs(args, persistentState); err != nil && !errors.Is(err, errApplyQuit) {
+		return err
+	}
+	return nil
+}
+
+// newApplyConfirmFunc returns a chezmoi.ConfirmFunc that prints the diff of
+// each target that would change, in the configured diff format, and prompts
+// the user whether to apply it.
+func (c *Config) newApplyConfirmFunc() chezmoi.ConfirmFunc {
+	all := false
+	return func(targetPath string, preview func(chezmoi.Mutator) error) (bool, error) {
+		if all {
+			return true, nil
+		}
+
+		var diffMutator chezmoi.Mutator
+		switch c.Diff.Format {
+		case "chezmoi":
+			diffMutator = chezmoi.NewVerboseMutator(c.Stdout, chezmoi.NullMutator{}, c.colored, c.maxDiffDataSize)
+		case "git":
+			unifiedEncoder := diff.NewUnifiedEncoder(c.Stdout, diff.DefaultContextLines)
+			diffMutator = chezmoi.NewGitDiffMutator(unifiedEncoder, chezmoi.NewFSMutator(vfs.NewReadOnlyFS(c.fs)), c.DestDir+string(filepath.Separator))
+		default:
+			return false, fmt.Errorf("unknown diff format: %q", c.Diff.Format)
+		}
+		anyMutator := chezmoi.NewAnyMutator(diffMutator)
+		if err := preview(anyMutator); err != nil {
+			return false, err
+		}
+		if !anyMutator.Mutated() {
+			return true, nil
+		}
+
+		choice, err := c.prompt(fmt.Sprintf("Apply %s", targetPath), "ynqa")
+		if err != nil {
+			return false, err
+		}
+		switch choice {
+		case 'y':
+			return true, nil
+		case 'n':
+			return false, nil
+		case 'q':
+			return false, errApplyQuit
+		case 'a':
+			all = true
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+// newApplyConflictFunc returns a chezmoi.ConflictFunc that resolves conflicts
+// according to the --force and --merge flags, or skips the conflicting file
+// with a warning, and a function to clean up any temporary files it creates.
+func (c *Config) newApplyConflictFunc(ts *chezmoi.TargetState) (chezmoi.ConflictFunc, func()) {
+	var tempDir string
+	cleanup := func() {
+		if tempDir != "" {
+			_ = os.RemoveAll(tempDir)
+		}
+	}
+	return func(file *chezmoi.File, targetPath string) (bool, error) {
+		switch {
+		case c.apply.force:
+			return true, nil
+		case c.apply.merge:
+			if tempDir == "" {
+				var err error
+				tempDir, err = ioutil.TempDir("", "chezmoi")
+				if err != nil {
+					return false, err
+				}
+			}
+			return false, c.runMergeCommand(ts, targetPath, file, tempDir)
+		default:
+			_, err := fmt.Fprintf(c.Stderr, "warning: %s: changed since chezmoi last wrote it, skipping\n", targetPath)
+			return false, err
+		}
+	}, cleanup
+}
+
+// printTargetsToRemove prints the targets that would be removed by --remove,
+// one per line.
+func (c *Config) printTargetsToRemove() error {
+	ts, err := c.getTargetState(nil)
+	if err != nil {
+		return err
+	}
+	targetsToRemove, err := ts.TargetsToRemove(vfs.NewReadOnlyFS(c.fs))
+	if err != nil {
+		return err
+	}
+	for _, target := range targetsToRemove {
+		if _, err := fmt.Fprintln(c.Stdout, target); err != nil {
+			return err
+		}
+	}
+	return nil
 }
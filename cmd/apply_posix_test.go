@@ -3,9 +3,17 @@
 package cmd
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vfs "github.com/twpayne/go-vfs"
 	"github.com/twpayne/go-vfs/vfst"
 )
 
@@ -74,6 +82,33 @@ func getApplyScriptTestCases(tempDir string) []scriptTestCase {
 				),
 			},
 		},
+		{
+			name: "before_and_after",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"run_after_a": "#!/bin/sh\ncat " + filepath.Join(tempDir, "b") + " >>" + filepath.Join(tempDir, "evidence") + "\n",
+					"b":           "b\n",
+					"run_before_c": "#!/bin/sh\nif [ -f " + filepath.Join(tempDir, "b") + " ]; then\n" +
+						"  echo exists >>" + filepath.Join(tempDir, "evidence") + "\n" +
+						"else\n" +
+						"  echo missing >>" + filepath.Join(tempDir, "evidence") + "\n" +
+						"fi\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath(filepath.Join(tempDir, "evidence"),
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString(strings.Join([]string{
+						"missing\n",
+						"b\n",
+						"exists\n",
+						"b\n",
+						"exists\n",
+						"b\n",
+					}, "")),
+				),
+			},
+		},
 	}
 }
 
@@ -82,3 +117,443 @@ func getRunOnceFiles() map[string]interface{} {
 		"/home/user/.local/share/chezmoi/run_once_foo.tmpl": "#!/bin/sh\necho bar >> {{ .TempFile }}\n",
 	}
 }
+
+func getRunOnChangeFiles() map[string]interface{} {
+	return map[string]interface{}{
+		"/home/user/.local/share/chezmoi/run_onchange_foo.tmpl": "#!/bin/sh\necho {{ .Value }} >> {{ .TempFile }}\n",
+	}
+}
+
+func TestApplyModify(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		root  interface{}
+		tests []vfst.Test
+	}{
+		{
+			name: "modify_existing_file",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": "# installer\nexport FOO=foo\n",
+				"/home/user/.local/share/chezmoi/modify_dot_bashrc": "#!/bin/sh\nsed s/foo/bar/\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# installer\nexport FOO=bar\n"),
+				),
+			},
+		},
+		{
+			name: "modify_missing_file",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/modify_dot_bashrc": "#!/bin/sh\nsed /FOO/d\necho export FOO=bar\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("export FOO=bar\n"),
+				),
+			},
+		},
+		{
+			name: "modify_template",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": "export FOO=foo\n",
+				"/home/user/.local/share/chezmoi/modify_dot_bashrc.tmpl": "#!/bin/sh\nsed s/foo/{{ \"bar\" }}/\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("export FOO=bar\n"),
+				),
+			},
+		},
+		{
+			name: "modify_empty",
+			root: map[string]interface{}{
+				"/home/user/.bashrc": "export FOO=foo\n",
+				"/home/user/.local/share/chezmoi/modify_dot_bashrc": "",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("export FOO=foo\n"),
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			// Apply twice to check that modify scripts are idempotent.
+			for i := 0; i < 2; i++ {
+				assert.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+			}
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
+
+func TestApplyErrorDoesNotRunBeforeScripts(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dot_bashrc.tmpl":   "{{ fail \"error\" }}\n",
+			"run_before_script": "#!/bin/sh\necho before >>" + filepath.Join(tempDir, "evidence") + "\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Error(t, newTestConfig(fs).runApplyCmd(nil, nil))
+	vfst.RunTests(t, vfs.OSFS, "",
+		vfst.TestPath(filepath.Join(tempDir, "evidence"),
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestApplyModifyRunsOnce(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.bashrc": "export FOO=foo\n",
+		"/home/user/.local/share/chezmoi/modify_dot_bashrc": "#!/bin/sh\necho modify >>" + filepath.Join(tempDir, "evidence") + "\nsed s/foo/bar/\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	// The modify script is run once to preview the change and its output is
+	// reused to apply it.
+	c := newTestConfig(
+		fs,
+		withApplyCmdConfig(applyCmdConfig{
+			interactive: true,
+		}),
+		withStdin(bytes.NewBufferString("y\n")),
+		withStdout(&bytes.Buffer{}),
+	)
+	assert.NoError(t, c.runApplyCmd(nil, nil))
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestContentsString("export FOO=bar\n"),
+		),
+	)
+	vfst.RunTests(t, vfs.OSFS, "",
+		vfst.TestPath(filepath.Join(tempDir, "evidence"),
+			vfst.TestContentsString("modify\n"),
+		),
+	)
+}
+
+func TestApplyModifyError(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/modify_dot_bashrc": "#!/bin/sh\necho error >&2\nexit 1\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	assert.EqualError(t, newTestConfig(fs).runApplyCmd(nil, nil), ".bashrc: exit status 1: error")
+}
+
+func TestApplyInteractive(t *testing.T) {
+	root := map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":  "# old contents of .bashrc\n",
+			".profile": "# old contents of .profile\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc":  "# new contents of .bashrc\n",
+				"dot_profile": "# new contents of .profile\n",
+				"dot_vimrc":   "# contents of .vimrc\n",
+				"run_install": "#!/bin/sh\n# install\n",
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name        string
+		stdin       string
+		wantPrompts []string
+		wantStdout  string
+		tests       []vfst.Test
+	}{
+		{
+			name:  "yes_no",
+			stdin: "y\nn\ny\nn\n",
+			wantPrompts: []string{
+				"Apply /home/user/.bashrc",
+				"Apply /home/user/.profile",
+				"Apply /home/user/.vimrc",
+				"Apply /home/user/install",
+			},
+			wantStdout: "#!/bin/sh\n# install\nApply /home/user/install [y,n,q,a]? ",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestContentsString("# new contents of .bashrc\n"),
+				),
+				vfst.TestPath("/home/user/.profile",
+					vfst.TestContentsString("# old contents of .profile\n"),
+				),
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestContentsString("# contents of .vimrc\n"),
+				),
+			},
+		},
+		{
+			name:  "all",
+			stdin: "n\na\n",
+			wantPrompts: []string{
+				"Apply /home/user/.bashrc",
+				"Apply /home/user/.profile",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestContentsString("# old contents of .bashrc\n"),
+				),
+				vfst.TestPath("/home/user/.profile",
+					vfst.TestContentsString("# new contents of .profile\n"),
+				),
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestContentsString("# contents of .vimrc\n"),
+				),
+			},
+		},
+		{
+			name:  "quit",
+			stdin: "y\nq\n",
+			wantPrompts: []string{
+				"Apply /home/user/.bashrc",
+				"Apply /home/user/.profile",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestContentsString("# new contents of .bashrc\n"),
+				),
+				vfst.TestPath("/home/user/.profile",
+					vfst.TestContentsString("# old contents of .profile\n"),
+				),
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(root)
+			require.NoError(t, err)
+			defer cleanup()
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withApplyCmdConfig(applyCmdConfig{
+					interactive: true,
+				}),
+				withStdin(bytes.NewBufferString(tc.stdin)),
+				withStdout(stdout),
+			)
+			assert.NoError(t, c.runApplyCmd(nil, nil))
+			var gotPrompts []string
+			for _, match := range regexp.MustCompile(`Apply \S+ \[y,n,q,a\]\? `).FindAllString(stdout.String(), -1) {
+				gotPrompts = append(gotPrompts, strings.TrimSuffix(match, " [y,n,q,a]? "))
+			}
+			assert.Equal(t, tc.wantPrompts, gotPrompts)
+			assert.Contains(t, stdout.String(), tc.wantStdout)
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
+
+func TestApplyInteractiveDir(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".ssh": &vfst.Dir{Perm: 0o755},
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_vim/vimrc":          "# contents of .vim/vimrc\n",
+				"private_dot_ssh/config": "# contents of .ssh/config\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withApplyCmdConfig(applyCmdConfig{
+			interactive: true,
+		}),
+		withStdin(bytes.NewBufferString("n\ny\nn\n")),
+		withStdout(stdout),
+	)
+	assert.NoError(t, c.runApplyCmd(nil, nil))
+	var gotPrompts []string
+	for _, match := range regexp.MustCompile(`Apply \S+ \[y,n,q,a\]\? `).FindAllString(stdout.String(), -1) {
+		gotPrompts = append(gotPrompts, strings.TrimSuffix(match, " [y,n,q,a]? "))
+	}
+	assert.Equal(t, []string{
+		"Apply /home/user/.ssh",
+		"Apply /home/user/.ssh/config",
+		"Apply /home/user/.vim",
+	}, gotPrompts)
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.vim",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/.ssh",
+			vfst.TestIsDir,
+			vfst.TestModePerm(0o755),
+		),
+		vfst.TestPath("/home/user/.ssh/config",
+			vfst.TestContentsString("# contents of .ssh/config\n"),
+		),
+	)
+}
+
+func TestApplyConflict(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		apply      applyCmdConfig
+		wantStderr string
+		tests      []vfst.Test
+	}{
+		{
+			name:       "skip",
+			wantStderr: "warning: /home/user/.gitconfig: changed since chezmoi last wrote it, skipping\n",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.gitconfig",
+					vfst.TestContentsString("# edited contents of .gitconfig\n"),
+				),
+				vfst.TestPath("/home/user/.profile",
+					vfst.TestContentsString("# new contents of .profile\n"),
+				),
+			},
+		},
+		{
+			name: "force",
+			apply: applyCmdConfig{
+				force: true,
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.gitconfig",
+					vfst.TestContentsString("# new contents of .gitconfig\n"),
+				),
+				vfst.TestPath("/home/user/.profile",
+					vfst.TestContentsString("# new contents of .profile\n"),
+				),
+			},
+		},
+		{
+			name: "merge",
+			apply: applyCmdConfig{
+				merge: true,
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.gitconfig",
+					vfst.TestContentsString("# edited contents of .gitconfig\n"),
+				),
+				vfst.TestPath("/home/user/.profile",
+					vfst.TestContentsString("# new contents of .profile\n"),
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user": map[string]interface{}{
+					".config/chezmoi": &vfst.Dir{Perm: 0o700},
+					".local/share/chezmoi": map[string]interface{}{
+						"dot_gitconfig": "# contents of .gitconfig\n",
+						"dot_profile":   "# contents of .profile\n",
+					},
+				},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+
+			// Apply once to record the state of the written files.
+			assert.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+
+			// Edit .gitconfig outside chezmoi and update the source state.
+			require.NoError(t, fs.WriteFile("/home/user/.gitconfig", []byte("# edited contents of .gitconfig\n"), 0o644))
+			require.NoError(t, fs.WriteFile("/home/user/.local/share/chezmoi/dot_gitconfig", []byte("# new contents of .gitconfig\n"), 0o644))
+			require.NoError(t, fs.WriteFile("/home/user/.local/share/chezmoi/dot_profile", []byte("# new contents of .profile\n"), 0o644))
+
+			stderr := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withApplyCmdConfig(tc.apply),
+				withStderr(stderr),
+			)
+			c.Merge.Command = "true"
+			assert.NoError(t, c.runApplyCmd(nil, nil))
+			assert.Equal(t, tc.wantStderr, stderr.String())
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
+
+func TestApplyConflictRemoved(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		root   map[string]interface{}
+		remove bool
+		target string
+		source string
+	}{
+		{
+			name: "exact",
+			root: map[string]interface{}{
+				"exact_dir/file": "# contents of dir/file\n",
+			},
+			target: "/home/user/dir/file",
+			source: "/home/user/.local/share/chezmoi/exact_dir/file",
+		},
+		{
+			name: "remove",
+			root: map[string]interface{}{
+				"dot_gitconfig": "# contents of .gitconfig\n",
+			},
+			remove: true,
+			target: "/home/user/.gitconfig",
+			source: "/home/user/.local/share/chezmoi/dot_gitconfig",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user": map[string]interface{}{
+					".config/chezmoi":      &vfst.Dir{Perm: 0o700},
+					".local/share/chezmoi": tc.root,
+				},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+
+			// Apply once to record the state of the written file.
+			assert.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+
+			// Remove the file from the source state so that chezmoi removes
+			// the target.
+			require.NoError(t, fs.Remove(tc.source))
+			if tc.remove {
+				require.NoError(t, fs.WriteFile("/home/user/.local/share/chezmoi/.chezmoiremove", []byte(filepath.Base(tc.target)+"\n"), 0o644))
+			}
+			assert.NoError(t, newTestConfig(fs, withRemove(tc.remove)).runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "", vfst.TestPath(tc.target, vfst.TestDoesNotExist))
+
+			// Create the target outside chezmoi and add it back to the source
+			// state. As chezmoi removed the target, this is not a conflict.
+			if tc.remove {
+				require.NoError(t, fs.Remove("/home/user/.local/share/chezmoi/.chezmoiremove"))
+			}
+			require.NoError(t, fs.WriteFile(tc.target, []byte("# edited contents\n"), 0o644))
+			require.NoError(t, fs.WriteFile(tc.source, []byte("# new contents\n"), 0o644))
+			stderr := &bytes.Buffer{}
+			assert.NoError(t, newTestConfig(fs, withStderr(stderr)).runApplyCmd(nil, nil))
+			assert.Equal(t, "", stderr.String())
+			vfst.RunTests(t, fs, "", vfst.TestPath(tc.target, vfst.TestContentsString("# new contents\n")))
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 	vfs "github.com/twpayne/go-vfs"
 	"github.com/twpayne/go-vfs/vfst"
 )
@@ -119,6 +122,33 @@ func TestApplyCommand(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "partial_template_with_funcs",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dir/file.tmpl":         `{{ template "foo" }}`,
+					".chezmoitemplates/foo": `{{ "CONTENTS" | lower }}`,
+				},
+			},
+		},
+		{
+			name: "include_template_with_args",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dir/file.tmpl":         `{{ includeTemplate "foo" (dict "suffix" "ents") }}`,
+					".chezmoitemplates/foo": `{{ if .chezmoi.sourceDir }}cont{{ end }}{{ .suffix }}`,
+				},
+			},
+		},
+		{
+			name: "template_directive",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dir/file.tmpl":         "# chezmoi:template:left-delimiter=[[ right-delimiter=]]\n[[ includeTemplate \"foo\" ]]",
+					".chezmoitemplates/foo": "{{/* chezmoi:template:missingkey=zero */}}\n{{ if not .missing }}cont{{ end }}ents",
+				},
+			},
+		},
 		{
 			name: "partial_template_in_subdir",
 			root: map[string]interface{}{
@@ -151,9 +181,18 @@ func TestApplyCommand(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			tc.root["/home/user/.local/share/chezmoi/dir/file"] = "contents"
-			tc.root["/home/user/.local/share/chezmoi/dir/other"] = "other stuff"
-			tc.root["/home/user/.local/share/chezmoi/symlink_symlink"] = "target"
+			// Add the default source files, unless the test case provides a
+			// template with the same target name.
+			sourceFiles, _ := tc.root["/home/user/.local/share/chezmoi"].(map[string]interface{})
+			for sourceName, contents := range map[string]string{
+				"dir/file":        "contents",
+				"dir/other":       "other stuff",
+				"symlink_symlink": "target",
+			} {
+				if _, ok := sourceFiles[sourceName+".tmpl"]; !ok {
+					tc.root["/home/user/.local/share/chezmoi/"+sourceName] = contents
+				}
+			}
 			fs, cleanup, err := vfst.NewTestFS(tc.root)
 			require.NoError(t, err)
 			defer cleanup()
@@ -183,6 +222,500 @@ func TestApplyCommand(t *testing.T) {
 	}
 }
 
+func TestApplyBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        interface{}
+		wantMutated bool
+		tests       []vfst.Test
+	}{
+		{
+			name: "create_file",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_ssh/block_config": "Host *\n  ForwardAgent no\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.ssh/config",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0644),
+					vfst.TestContentsString(strings.Join([]string{
+						"# BEGIN chezmoi managed block\n",
+						"Host *\n",
+						"  ForwardAgent no\n",
+						"# END chezmoi managed block\n",
+					}, "")),
+				),
+			},
+		},
+		{
+			name: "append_block",
+			root: map[string]interface{}{
+				"/home/user/.ssh/config": &vfst.File{
+					Perm:     0600,
+					Contents: []byte("Host example.com\n  User user\n"),
+				},
+				"/home/user/.local/share/chezmoi/dot_ssh/block_config": "Host *\n  ForwardAgent no\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.ssh/config",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0600),
+					vfst.TestContentsString(strings.Join([]string{
+						"Host example.com\n",
+						"  User user\n",
+						"# BEGIN chezmoi managed block\n",
+						"Host *\n",
+						"  ForwardAgent no\n",
+						"# END chezmoi managed block\n",
+					}, "")),
+				),
+			},
+		},
+		{
+			name: "replace_block_template",
+			root: map[string]interface{}{
+				"/home/user/.ssh/config": strings.Join([]string{
+					"# BEGIN chezmoi managed block\n",
+					"Host *\n",
+					"  ForwardAgent yes\n",
+					"# END chezmoi managed block\n",
+					"Host example.com\n",
+					"  User user\n",
+				}, ""),
+				"/home/user/.local/share/chezmoi/dot_ssh/block_config.tmpl": "Host *\n  ForwardAgent {{ \"no\" }}\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.ssh/config",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString(strings.Join([]string{
+						"# BEGIN chezmoi managed block\n",
+						"Host *\n",
+						"  ForwardAgent no\n",
+						"# END chezmoi managed block\n",
+						"Host example.com\n",
+						"  User user\n",
+					}, "")),
+				),
+			},
+		},
+		{
+			name: "changes_outside_block",
+			root: map[string]interface{}{
+				"/home/user/.ssh/config": strings.Join([]string{
+					"Host example.com\n",
+					"  User user\n",
+					"# BEGIN chezmoi managed block\n",
+					"Host *\n",
+					"  ForwardAgent no\n",
+					"# END chezmoi managed block\n",
+				}, ""),
+				"/home/user/.local/share/chezmoi/dot_ssh/block_config": "Host *\n  ForwardAgent no\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.ssh/config",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString(strings.Join([]string{
+						"Host example.com\n",
+						"  User user\n",
+						"# BEGIN chezmoi managed block\n",
+						"Host *\n",
+						"  ForwardAgent no\n",
+						"# END chezmoi managed block\n",
+					}, "")),
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			anyMutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
+			assert.NoError(t, newTestConfig(fs, withMutator(anyMutator)).runApplyCmd(nil, nil))
+			assert.Equal(t, tc.wantMutated, anyMutator.Mutated())
+			assert.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
+
+func TestApplyCreate(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        interface{}
+		wantMutated bool
+		tests       []vfst.Test
+	}{
+		{
+			name: "create",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/create_dot_foo": "# contents of .foo\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.foo",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0644),
+					vfst.TestContentsString("# contents of .foo\n"),
+				),
+			},
+		},
+		{
+			name: "existing_file",
+			root: map[string]interface{}{
+				"/home/user/.foo": &vfst.File{
+					Perm:     0600,
+					Contents: []byte("# edited contents of .foo\n"),
+				},
+				"/home/user/.local/share/chezmoi/create_dot_foo": "# contents of .foo\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.foo",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0600),
+					vfst.TestContentsString("# edited contents of .foo\n"),
+				),
+			},
+		},
+		{
+			name: "existing_symlink",
+			root: map[string]interface{}{
+				"/home/user/.foo": &vfst.Symlink{Target: "bar"},
+				"/home/user/.local/share/chezmoi/create_dot_foo": "# contents of .foo\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.foo",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget("bar"),
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			anyMutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
+			assert.NoError(t, newTestConfig(fs, withMutator(anyMutator)).runApplyCmd(nil, nil))
+			assert.Equal(t, tc.wantMutated, anyMutator.Mutated())
+			assert.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
+
+func TestApplyLink(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        interface{}
+		follow      bool
+		mode        chezmoi.Mode
+		wantMutated bool
+		tests       []vfst.Test
+	}{
+		{
+			name: "link",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget(".local/share/chezmoi/link_dot_vimrc"),
+				),
+			},
+		},
+		{
+			name: "link_in_subdir",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_config/nvim/link_init.vim": "set nocompatible\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.config/nvim/init.vim",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget("../../.local/share/chezmoi/dot_config/nvim/link_init.vim"),
+				),
+			},
+		},
+		{
+			name: "replace_file",
+			root: map[string]interface{}{
+				"/home/user/.vimrc": "set compatible\n",
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget(".local/share/chezmoi/link_dot_vimrc"),
+				),
+			},
+		},
+		{
+			name: "existing_link",
+			root: map[string]interface{}{
+				"/home/user/.vimrc": &vfst.Symlink{Target: ".local/share/chezmoi/link_dot_vimrc"},
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget(".local/share/chezmoi/link_dot_vimrc"),
+				),
+			},
+		},
+		{
+			name: "replace_symlink",
+			root: map[string]interface{}{
+				"/home/user/.vimrc":                              &vfst.Symlink{Target: "dotfiles/vimrc"},
+				"/home/user/dotfiles/vimrc":                      "set compatible\n",
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget(".local/share/chezmoi/link_dot_vimrc"),
+				),
+				vfst.TestPath("/home/user/dotfiles/vimrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("set compatible\n"),
+				),
+			},
+		},
+		{
+			name: "follow_symlink",
+			root: map[string]interface{}{
+				"/home/user/.vimrc":                              &vfst.Symlink{Target: "dotfiles/vimrc"},
+				"/home/user/dotfiles/vimrc":                      "set compatible\n",
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+			},
+			follow:      true,
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget("dotfiles/vimrc"),
+				),
+				vfst.TestPath("/home/user/dotfiles/vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget("../.local/share/chezmoi/link_dot_vimrc"),
+				),
+			},
+		},
+		{
+			name: "follow_symlink_to_source",
+			root: map[string]interface{}{
+				"/home/user/.vimrc": &vfst.Symlink{Target: "./.local/share/chezmoi/link_dot_vimrc"},
+				"/home/user/.local/share/chezmoi/link_dot_vimrc": "set nocompatible\n",
+			},
+			follow: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget("./.local/share/chezmoi/link_dot_vimrc"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/link_dot_vimrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("set nocompatible\n"),
+				),
+			},
+		},
+		{
+			name: "link_executable",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/link_executable_dot_script": "#!/bin/sh\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.script",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0755),
+					vfst.TestContentsString("#!/bin/sh\n"),
+				),
+			},
+		},
+		{
+			name: "link_template",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/link_dot_vimrc.tmpl": "set {{ \"nocompatible\" }}\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("set nocompatible\n"),
+				),
+			},
+		},
+		{
+			name: "mode_symlink",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"dot_bashrc.tmpl":       "# {{ \"bashrc\" }}\n",
+					"dot_vimrc":             "set nocompatible\n",
+					"empty_dot_hushlogin":   "",
+					"executable_dot_script": "#!/bin/sh\n",
+					"private_dot_netrc":     "machine example.com\n",
+				},
+			},
+			mode:        chezmoi.ModeSymlink,
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.bashrc",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# bashrc\n"),
+				),
+				vfst.TestPath("/home/user/.hushlogin",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString(""),
+				),
+				vfst.TestPath("/home/user/.netrc",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0600),
+					vfst.TestContentsString("machine example.com\n"),
+				),
+				vfst.TestPath("/home/user/.script",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0755),
+					vfst.TestContentsString("#!/bin/sh\n"),
+				),
+				vfst.TestPath("/home/user/.vimrc",
+					vfst.TestModeType(os.ModeSymlink),
+					vfst.TestSymlinkTarget(".local/share/chezmoi/dot_vimrc"),
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			mode := tc.mode
+			if mode == "" {
+				mode = chezmoi.ModeFile
+			}
+			anyMutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
+			assert.NoError(t, newTestConfig(fs, withFollow(tc.follow), withMode(mode), withMutator(anyMutator)).runApplyCmd(nil, nil))
+			assert.Equal(t, tc.wantMutated, anyMutator.Mutated())
+			assert.NoError(t, newTestConfig(fs, withFollow(tc.follow), withMode(mode)).runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "", tc.tests)
+			// Check that the result is in sync.
+			anyMutator = chezmoi.NewAnyMutator(chezmoi.NullMutator{})
+			assert.NoError(t, newTestConfig(fs, withFollow(tc.follow), withMode(mode), withMutator(anyMutator)).runApplyCmd(nil, nil))
+			assert.False(t, anyMutator.Mutated())
+		})
+	}
+}
+
+func TestApplyMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        interface{}
+		wantMutated bool
+		tests       []vfst.Test
+	}{
+		{
+			name: "json",
+			root: map[string]interface{}{
+				"/home/user/settings.json":                            `{"editor.fontSize": 12, "files": {"eol": "\r\n", "trimTrailingWhitespace": false}}`,
+				"/home/user/.local/share/chezmoi/merge_settings.json": `{"files": {"trimTrailingWhitespace": true}}`,
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/settings.json",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString(strings.Join([]string{
+						"{\n",
+						"  \"editor.fontSize\": 12,\n",
+						"  \"files\": {\n",
+						"    \"eol\": \"\\r\\n\",\n",
+						"    \"trimTrailingWhitespace\": true\n",
+						"  }\n",
+						"}\n",
+					}, "")),
+				),
+			},
+		},
+		{
+			name: "json_missing",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/merge_settings.json.tmpl": `{"files": {"trimTrailingWhitespace": {{ true }}}}`,
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/settings.json",
+					vfst.TestModeIsRegular,
+					vfst.TestModePerm(0644),
+					vfst.TestContentsString(strings.Join([]string{
+						"{\n",
+						"  \"files\": {\n",
+						"    \"trimTrailingWhitespace\": true\n",
+						"  }\n",
+						"}\n",
+					}, "")),
+				),
+			},
+		},
+		{
+			name: "json_unchanged",
+			root: map[string]interface{}{
+				"/home/user/settings.json":                            `{"files": {"trimTrailingWhitespace": true}, "editor.fontSize": 12}`,
+				"/home/user/.local/share/chezmoi/merge_settings.json": `{"files": {"trimTrailingWhitespace": true}}`,
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/settings.json",
+					vfst.TestContentsString(`{"files": {"trimTrailingWhitespace": true}, "editor.fontSize": 12}`),
+				),
+			},
+		},
+		{
+			name: "toml",
+			root: map[string]interface{}{
+				"/home/user/.config/foo.toml":                               "a = 1\n\n[b]\n  c = \"d\"\n",
+				"/home/user/.local/share/chezmoi/dot_config/merge_foo.toml": "[b]\n  e = \"f\"\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.config/foo.toml",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("a = 1\n\n[b]\n  c = \"d\"\n  e = \"f\"\n"),
+				),
+			},
+		},
+		{
+			name: "yaml",
+			root: map[string]interface{}{
+				"/home/user/.config/foo.yml":                               "a: 1\nb:\n  c: d\n",
+				"/home/user/.local/share/chezmoi/dot_config/merge_foo.yml": "b:\n  c: e\n",
+			},
+			wantMutated: true,
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.config/foo.yml",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("a: 1\nb:\n  c: e\n"),
+				),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			anyMutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
+			assert.NoError(t, newTestConfig(fs, withMutator(anyMutator)).runApplyCmd(nil, nil))
+			assert.Equal(t, tc.wantMutated, anyMutator.Mutated())
+			assert.NoError(t, newTestConfig(fs).runApplyCmd(nil, nil))
+			vfst.RunTests(t, fs, "", tc.tests)
+		})
+	}
+}
+
 func TestApplyFollow(t *testing.T) {
 	for _, tc := range []struct {
 		name   string
@@ -252,11 +785,14 @@ func TestApplyFollow(t *testing.T) {
 
 func TestApplyRemove(t *testing.T) {
 	for _, tc := range []struct {
-		name     string
-		noRemove bool
-		root     interface{}
-		data     map[string]interface{}
-		tests    []vfst.Test
+		name       string
+		noRemove   bool
+		dryRun     bool
+		root       interface{}
+		data       map[string]interface{}
+		wantErr    string
+		wantStdout string
+		tests      []vfst.Test
 	}{
 		{
 			name: "simple",
@@ -350,17 +886,185 @@ func TestApplyRemove(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "unanchored",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "foo",
+				"/home/user/.local/share/chezmoi/dir/bar":        "# contents of bar\n",
+				"/home/user/foo":     "# contents of foo\n",
+				"/home/user/dir/foo": "# contents of foo\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/dir/foo",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
+		{
+			name: "unanchored_unmanaged_dir",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "foo",
+				"/home/user/foo":       "# contents of foo\n",
+				"/home/user/dir/foo":   "# contents of foo\n",
+				"/home/user/.dir/foo/": &vfst.Dir{Perm: 0755},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/dir/foo",
+					vfst.TestModeIsRegular,
+				),
+				vfst.TestPath("/home/user/.dir/foo",
+					vfst.TestIsDir,
+				),
+			},
+		},
+		{
+			name: "anchored_double_star",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "/dir/**/foo",
+				"/home/user/.local/share/chezmoi/dir/sub/bar":    "# contents of bar\n",
+				"/home/user/dir/foo":                             "# contents of foo\n",
+				"/home/user/dir/sub/foo":                         "# contents of foo\n",
+				"/home/user/dir/other/foo":                       "# contents of foo\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/dir/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/dir/sub/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/dir/other/foo",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
+		{
+			name: "anchored",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "/foo",
+				"/home/user/foo":     "# contents of foo\n",
+				"/home/user/dir/foo": "# contents of foo\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/dir/foo",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name: "dir_only",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "foo/",
+				"/home/user/foo/bar":                             "# contents of bar\n",
+				"/home/user/dir/foo":                             "# contents of foo\n",
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/dir/foo",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name: "invalid_pattern",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "foo\n[\n",
+				"/home/user/foo": "# contents of foo\n",
+			},
+			wantErr: "/home/user/.local/share/chezmoi/.chezmoiremove:2: \"[\": syntax error in pattern",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name: "dont_remove_managed_file",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "f*",
+				"/home/user/.local/share/chezmoi/foo":            "# contents of foo\n",
+				"/home/user/foo":                                 "# old contents of foo\n",
+			},
+			wantErr: ".chezmoiremove: refusing to remove managed targets: /home/user/foo (matched by f*)",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# old contents of foo\n"),
+				),
+			},
+		},
+		{
+			name: "dont_remove_managed_dir_children",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "foo\nfoo/*\n",
+				"/home/user/.local/share/chezmoi/foo/bar":        "# contents of bar\n",
+				"/home/user/foo/bar":                             "# contents of bar\n",
+				"/home/user/foo/baz":                             "# contents of baz\n",
+			},
+			wantErr: ".chezmoiremove: refusing to remove managed targets: /home/user/foo (matched by foo); /home/user/foo/bar (matched by foo/*)",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo/bar",
+					vfst.TestModeIsRegular,
+				),
+				vfst.TestPath("/home/user/foo/baz",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name:   "dry_run",
+			dryRun: true,
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoiremove": "f*",
+				"/home/user/foo/bar":                             "# contents of bar\n",
+				"/home/user/fuzz":                                "# contents of fuzz\n",
+			},
+			wantStdout: "/home/user/fuzz\n/home/user/foo\n",
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/foo/bar",
+					vfst.TestModeIsRegular,
+				),
+				vfst.TestPath("/home/user/fuzz",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			fs, cleanup, err := vfst.NewTestFS(tc.root)
 			require.NoError(t, err)
 			defer cleanup()
-			c := newTestConfig(
-				fs,
+			stdout := &bytes.Buffer{}
+			options := []configOption{
 				withData(tc.data),
 				withRemove(!tc.noRemove),
-			)
-			assert.NoError(t, c.runApplyCmd(nil, nil))
+				withStdout(stdout),
+			}
+			if tc.dryRun {
+				options = append(options,
+					withDryRun(true),
+					withMutator(chezmoi.NullMutator{}),
+					withVerbose(false),
+				)
+			}
+			c := newTestConfig(fs, options...)
+			if tc.wantErr != "" {
+				assert.EqualError(t, c.runApplyCmd(nil, nil), tc.wantErr)
+			} else {
+				assert.NoError(t, c.runApplyCmd(nil, nil))
+			}
+			assert.Equal(t, tc.wantStdout, stdout.String())
 			vfst.RunTests(t, fs, "", tc.tests)
 		})
 	}
@@ -439,6 +1143,47 @@ func TestApplyRunOnce(t *testing.T) {
 	assert.Equal(t, []byte("bar\n"), actualData)
 }
 
+func TestApplyRunOnChange(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+	tempFile := filepath.Join(tempDir, "foo")
+
+	fs, cleanup, err := vfst.NewTestFS(
+		[]interface{}{
+			getRunOnChangeFiles(),
+		},
+	)
+	require.NoError(t, err)
+	defer cleanup()
+
+	for _, tc := range []struct {
+		value string
+		want  string
+	}{
+		{value: "bar", want: "bar\n"},
+		{value: "bar", want: "bar\n"},
+		{value: "baz", want: "bar\nbaz\n"},
+		{value: "baz", want: "bar\nbaz\n"},
+		{value: "bar", want: "bar\nbaz\nbar\n"},
+	} {
+		c := newTestConfig(
+			fs,
+			withDestDir("/"),
+			withData(map[string]interface{}{
+				"TempFile": tempFile,
+				"Value":    tc.value,
+			}),
+		)
+		require.NoError(t, c.runApplyCmd(nil, nil))
+		actualData, err := ioutil.ReadFile(tempFile)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(tc.want), actualData)
+	}
+}
+
 func TestApplyRemoveEmptySymlink(t *testing.T) {
 	for _, tc := range []struct {
 		name  string
@@ -86,3 +86,9 @@ func getRunOnceFiles() map[string]interface{} {
 		"/home/user/.local/share/chezmoi/run_once_foo.bat.tmpl": "@powershell.exe -NoProfile -NonInteractive -c \"Write-Host -NoNewLine ('bar{0}' -f (0x0A -as [char]))\">> {{ .TempFile }}\n",
 	}
 }
+
+func getRunOnChangeFiles() map[string]interface{} {
+	return map[string]interface{}{
+		"/home/user/.local/share/chezmoi/run_onchange_foo.bat.tmpl": "@powershell.exe -NoProfile -NonInteractive -c \"Write-Host -NoNewLine ('{{ .Value }}{0}' -f (0x0A -as [char]))\">> {{ .TempFile }}\n",
+	}
+}
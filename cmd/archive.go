@@ -2,33 +2,121 @@ package cmd
 
 import (
 	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 )
 
 var archiveCmd = &cobra.Command{
 	Use:     "archive",
 	Args:    cobra.NoArgs,
-	Short:   "Write a tar archive of the target state to stdout",
+	Short:   "Write an archive of the target state to stdout or a file",
 	Long:    mustGetLongHelp("archive"),
 	Example: getExample("archive"),
 	PreRunE: config.ensureNoError,
 	RunE:    config.runArchiveCmd,
 }
 
+type archiveCmdConfig struct {
+	exclude []string
+	format  string
+	include []string
+	output  string
+}
+
 func init() {
 	rootCmd.AddCommand(archiveCmd)
+
+	persistentFlags := archiveCmd.PersistentFlags()
+	persistentFlags.StringSliceVarP(&config.archive.exclude, "exclude", "x", nil, "exclude entry types")
+	persistentFlags.StringVar(&config.archive.format, "format", "", "format (tar, tar.gz, or zip)")
+	persistentFlags.StringSliceVarP(&config.archive.include, "include", "i", []string{"all"}, "include entry types")
+	persistentFlags.StringVarP(&config.archive.output, "output", "o", "", "output filename")
+
+	panicOnError(archiveCmd.MarkPersistentFlagFilename("output"))
 }
 
 func (c *Config) runArchiveCmd(cmd *cobra.Command, args []string) error {
+	include, err := chezmoi.ParseEntryTypeSet(c.archive.include)
+	if err != nil {
+		return err
+	}
+	exclude, err := chezmoi.ParseEntryTypeSet(c.archive.exclude)
+	if err != nil {
+		return err
+	}
+
+	format := c.archive.format
+	if format == "" {
+		format = archiveFormatFromOutput(c.archive.output)
+	}
+
 	ts, err := c.getTargetState(nil)
 	if err != nil {
 		return err
 	}
-	w := tar.NewWriter(c.Stdout)
-	if err := ts.Archive(w, os.FileMode(c.Umask)); err != nil {
+
+	warn := func(message string) {
+		_, _ = fmt.Fprintf(c.Stderr, "warning: %s\n", message)
+	}
+
+	output := &bytes.Buffer{}
+	include &^= exclude
+	umask := os.FileMode(c.Umask)
+	switch strings.ToLower(format) {
+	case "tar":
+		w := tar.NewWriter(output)
+		if err := ts.Archive(w, include, umask, warn); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	case "tar.gz", "tgz":
+		gw := gzip.NewWriter(output)
+		w := tar.NewWriter(gw)
+		if err := ts.Archive(w, include, umask, warn); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	case "zip":
+		w := chezmoi.NewZipWriter(output)
+		if err := ts.Archive(w, include, umask, warn); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: unknown format", format)
+	}
+
+	if c.archive.output == "" {
+		_, err := c.Stdout.Write(output.Bytes())
 		return err
 	}
-	return w.Close()
+	return c.fs.WriteFile(c.archive.output, output.Bytes(), 0o666)
+}
+
+// archiveFormatFromOutput returns the archive format implied by the extension
+// of output, defaulting to tar.
+func archiveFormatFromOutput(output string) string {
+	switch lowerOutput := strings.ToLower(output); {
+	case strings.HasSuffix(lowerOutput, ".tar.gz"), strings.HasSuffix(lowerOutput, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lowerOutput, ".zip"):
+		return "zip"
+	default:
+		return "tar"
+	}
 }
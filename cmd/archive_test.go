@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"io"
 	"io/ioutil"
 	"path/filepath"
@@ -47,3 +49,153 @@ func TestArchiveCmd(t *testing.T) {
 	_, err = r.Next()
 	assert.Equal(t, err, io.EOF)
 }
+
+func TestArchiveCmdFormats(t *testing.T) {
+	root := map[string]interface{}{
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			"dir/file":                "contents",
+			"run_once_before_install": "#!/bin/sh\n",
+			"symlink_symlink":         "target",
+		},
+	}
+	type entry struct {
+		name       string
+		linkname   string
+		contents   string
+		paxRecords map[string]string
+	}
+	for _, tc := range []struct {
+		name    string
+		archive archiveCmdConfig
+		want    []entry
+	}{
+		{
+			name: "tar",
+			archive: archiveCmdConfig{
+				include: []string{"all"},
+			},
+			want: []entry{
+				{name: "dir"},
+				{name: "dir/file", contents: "contents"},
+				{
+					name:     "install",
+					contents: "#!/bin/sh\n",
+					paxRecords: map[string]string{
+						"CHEZMOI.before": "true",
+						"CHEZMOI.once":   "true",
+						"CHEZMOI.type":   "script",
+					},
+				},
+				{name: "symlink", linkname: "target"},
+			},
+		},
+		{
+			name: "tar.gz_exclude_scripts",
+			archive: archiveCmdConfig{
+				exclude: []string{"scripts"},
+				format:  "tar.gz",
+				include: []string{"all"},
+			},
+			want: []entry{
+				{name: "dir"},
+				{name: "dir/file", contents: "contents"},
+				{name: "symlink", linkname: "target"},
+			},
+		},
+		{
+			name: "zip_output_files",
+			archive: archiveCmdConfig{
+				include: []string{"files"},
+				output:  "/home/user/archive.zip",
+			},
+			want: []entry{
+				{name: "dir/file", contents: "contents"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(root)
+			require.NoError(t, err)
+			defer cleanup()
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withStdout(stdout),
+			)
+			c.archive = tc.archive
+			require.NoError(t, c.runArchiveCmd(nil, nil))
+
+			var got []entry
+			if tc.archive.output != "" {
+				data, err := fs.ReadFile(tc.archive.output)
+				require.NoError(t, err)
+				assert.Equal(t, 0, stdout.Len())
+				r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+				require.NoError(t, err)
+				for _, f := range r.File {
+					if f.Mode().IsRegular() {
+						assert.Equal(t, zip.Deflate, f.Method)
+					}
+					rc, err := f.Open()
+					require.NoError(t, err)
+					contents, err := ioutil.ReadAll(rc)
+					require.NoError(t, err)
+					require.NoError(t, rc.Close())
+					got = append(got, entry{
+						name:     f.Name,
+						contents: string(contents),
+					})
+				}
+			} else {
+				var r io.Reader = stdout
+				if tc.archive.format == "tar.gz" {
+					r, err = gzip.NewReader(stdout)
+					require.NoError(t, err)
+				}
+				tr := tar.NewReader(r)
+				for {
+					h, err := tr.Next()
+					if err == io.EOF {
+						break
+					}
+					require.NoError(t, err)
+					contents, err := ioutil.ReadAll(tr)
+					require.NoError(t, err)
+					got = append(got, entry{
+						name:       filepath.ToSlash(h.Name),
+						linkname:   h.Linkname,
+						contents:   string(contents),
+						paxRecords: h.PAXRecords,
+					})
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestArchiveCmdModify(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi/dot_bashrc":         "# contents of .bashrc\n",
+		"/home/user/.local/share/chezmoi/modify_dot_inputrc": "#!/bin/sh\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withStdout(stdout),
+		withStderr(stderr),
+	)
+	assert.NoError(t, c.runArchiveCmd(nil, nil))
+	assert.Equal(t, "warning: .inputrc: skipping file whose contents depend on the destination\n", stderr.String())
+	r := tar.NewReader(stdout)
+
+	h, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, ".bashrc", h.Name)
+
+	_, err = r.Next()
+	assert.Equal(t, err, io.EOF)
+}
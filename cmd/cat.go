@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/twpayne/chezmoi/internal/chezmoi"
@@ -35,7 +36,7 @@ func (c *Config) runCatCmd(cmd *cobra.Command, args []string) error {
 	for i, entry := range entries {
 		switch entry := entry.(type) {
 		case *chezmoi.File:
-			contents, err := entry.Contents()
+			contents, err := entry.TargetContents(c.fs, c.mutator, filepath.Join(ts.DestDir, entry.TargetName()))
 			if err != nil {
 				return err
 			}
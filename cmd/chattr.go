@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,11 +24,23 @@ var chattrCmd = &cobra.Command{
 
 type boolModifier int
 
+type stringModifier struct {
+	modified bool
+	value    string
+}
+
 type attributeModifiers struct {
+	after      boolModifier
+	before     boolModifier
+	create     boolModifier
 	empty      boolModifier
 	encrypt    boolModifier
 	exact      boolModifier
 	executable boolModifier
+	group      stringModifier
+	link       boolModifier
+	onChange   boolModifier
+	owner      stringModifier
 	private    boolModifier
 	template   boolModifier
 }
@@ -36,10 +49,15 @@ func init() {
 	rootCmd.AddCommand(chattrCmd)
 
 	attributes := []string{
+		"after",
+		"before",
+		"create",
 		"empty", "e",
 		"encrypt",
 		"exact",
 		"executable", "x",
+		"link",
+		"onchange",
 		"private", "p",
 		"template", "t",
 	}
@@ -47,6 +65,9 @@ func init() {
 	for _, attribute := range attributes {
 		words = append(words, attribute, "-"+attribute, "+"+attribute, "no"+attribute)
 	}
+	for _, attribute := range []string{"group", "owner"} {
+		words = append(words, attribute+"=", "-"+attribute, "no"+attribute)
+	}
 	panicOnError(chattrCmd.MarkZshCompPositionalArgumentWords(1, words...))
 	markRemainingZshCompPositionalArgumentsAsFiles(chattrCmd, 2)
 }
@@ -68,10 +89,28 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	updates := make(map[string]func() error)
+	attributes := make(map[string][]byte)
 	for _, entry := range entries {
-		dir, oldBase := filepath.Split(entry.SourceName())
-		oldpath := filepath.Join(ts.SourceDir, dir, oldBase)
+		oldpath := ts.SourcePath(entry)
+		dir, oldBase := filepath.Split(oldpath)
+		if ams.owner.modified || ams.group.modified {
+			attributesPath, data, err := c.modifyOwnership(ts, entry, dir, attributes, ams)
+			if err != nil {
+				return err
+			}
+			attributes[attributesPath] = data
+		}
 		switch entry := entry.(type) {
+		case *chezmoi.Block:
+			ba := chezmoi.ParseBlockAttributes(oldBase)
+			ba.Template = ams.template.modify(entry.Template)
+			newBase := ba.SourceName()
+			if newBase != oldBase {
+				newpath := filepath.Join(dir, newBase)
+				updates[oldpath] = func() error {
+					return c.mutator.Rename(oldpath, newpath)
+				}
+			}
 		case *chezmoi.Dir:
 			da := chezmoi.ParseDirAttributes(oldBase)
 			da.Exact = ams.exact.modify(entry.Exact)
@@ -82,7 +121,7 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			da.Perm = perm
 			newBase := da.SourceName()
 			if newBase != oldBase {
-				newpath := filepath.Join(ts.SourceDir, dir, newBase)
+				newpath := filepath.Join(dir, newBase)
 				updates[oldpath] = func() error {
 					return c.mutator.Rename(oldpath, newpath)
 				}
@@ -97,32 +136,52 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 				mode &= 0700
 			}
 			fa.Mode = mode
+			fa.Create = ams.create.modify(entry.Create)
+			// Use the parsed attribute as entry.Link is also set by the
+			// symlink mode.
+			fa.Link = ams.link.modify(fa.Link)
 			fa.Encrypted = ams.encrypt.modify(entry.Encrypted)
 			fa.Empty = ams.empty.modify(entry.Empty)
 			fa.Template = ams.template.modify(entry.Template)
-			newpath := filepath.Join(ts.SourceDir, dir, fa.SourceName())
+			newpath := filepath.Join(dir, fa.SourceName())
 			if fa.Encrypted != entry.Encrypted {
-				oldContents, err := c.fs.ReadFile(filepath.Join(c.SourceDir, entry.SourceName()))
+				update, err := c.makeEncryptUpdate(ts, entry, oldpath, newpath, fa.Encrypted)
 				if err != nil {
 					return err
 				}
-				var newContents []byte
-				if fa.Encrypted {
-					newContents, err = ts.GPG.Encrypt(entry.TargetName(), oldContents)
-				} else {
-					newContents, err = ts.GPG.Decrypt(entry.TargetName(), oldContents)
+				updates[oldpath] = update
+			} else if newpath != oldpath {
+				updates[oldpath] = func() error {
+					return c.mutator.Rename(oldpath, newpath)
 				}
+			}
+		case *chezmoi.Script:
+			sa := chezmoi.ParseScriptAttributes(oldBase)
+			sa.OnChange = ams.onChange.modify(entry.OnChange)
+			// A script is either run once or run on change, so running it on
+			// change clears once.
+			if ams.onChange > 0 {
+				sa.Once = false
+			}
+			sa.Before = ams.before.modify(entry.Before)
+			sa.After = ams.after.modify(entry.After)
+			// A script runs in at most one phase, so setting one phase
+			// clears the other.
+			if ams.before > 0 {
+				sa.After = false
+			}
+			if ams.after > 0 {
+				sa.Before = false
+			}
+			sa.Encrypted = ams.encrypt.modify(entry.Encrypted)
+			sa.Template = ams.template.modify(entry.Template)
+			newpath := filepath.Join(dir, sa.SourceName())
+			if sa.Encrypted != entry.Encrypted {
+				update, err := c.makeEncryptUpdate(ts, entry, oldpath, newpath, sa.Encrypted)
 				if err != nil {
 					return err
 				}
-				updates[oldpath] = func() error {
-					// FIXME replace file and contents atomically, see
-					// https://github.com/google/renameio/issues/16.
-					if err := c.mutator.WriteFile(newpath, newContents, 0644, oldContents); err != nil {
-						return err
-					}
-					return c.mutator.RemoveAll(oldpath)
-				}
+				updates[oldpath] = update
 			} else if newpath != oldpath {
 				updates[oldpath] = func() error {
 					return c.mutator.Rename(oldpath, newpath)
@@ -133,7 +192,7 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 			fa.Template = ams.template.modify(entry.Template)
 			newBase := fa.SourceName()
 			if newBase != oldBase {
-				newpath := filepath.Join(ts.SourceDir, dir, newBase)
+				newpath := filepath.Join(dir, newBase)
 				updates[oldpath] = func() error {
 					return c.mutator.Rename(oldpath, newpath)
 				}
@@ -141,6 +200,25 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Write each changed attributes file, which is sorted with the files
+	// in its directory.
+	for attributesPath, data := range attributes {
+		attributesPath, data := attributesPath, data
+		oldData, err := c.fs.ReadFile(attributesPath)
+		switch {
+		case os.IsNotExist(err):
+			oldData = nil
+		case err != nil:
+			return err
+		}
+		if bytes.Equal(data, oldData) {
+			continue
+		}
+		updates[attributesPath] = func() error {
+			return c.mutator.WriteFile(attributesPath, data, 0644, oldData)
+		}
+	}
+
 	// Sort oldpaths in reverse so we update files before their parent
 	// directories.
 	oldpaths := make([]string, 0, len(updates))
@@ -158,6 +236,62 @@ func (c *Config) runChattrCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// makeEncryptUpdate returns a function that replaces entry's source file at
+// oldpath with its encrypted or decrypted contents at newpath.
+func (c *Config) makeEncryptUpdate(ts *chezmoi.TargetState, entry chezmoi.Entry, oldpath, newpath string, encrypt bool) (func() error, error) {
+	oldContents, err := c.fs.ReadFile(oldpath)
+	if err != nil {
+		return nil, err
+	}
+	var newContents []byte
+	if encrypt {
+		newContents, err = ts.GPG.Encrypt(entry.TargetName(), oldContents)
+	} else {
+		newContents, err = ts.GPG.Decrypt(entry.TargetName(), oldContents)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		// FIXME replace file and contents atomically, see
+		// https://github.com/google/renameio/issues/16.
+		if err := c.mutator.WriteFile(newpath, newContents, 0644, oldContents); err != nil {
+			return err
+		}
+		return c.mutator.RemoveAll(oldpath)
+	}, nil
+}
+
+// modifyOwnership returns the path and new contents of the attributes file in
+// dir, the source directory containing entry, with the owner and group of
+// entry modified by ams. Contents already modified are taken from attributes.
+func (c *Config) modifyOwnership(ts *chezmoi.TargetState, entry chezmoi.Entry, dir string, attributes map[string][]byte, ams *attributeModifiers) (string, []byte, error) {
+	switch entry.(type) {
+	case *chezmoi.Block, *chezmoi.Script:
+		return "", nil, fmt.Errorf("%s: cannot set owner or group", entry.TargetName())
+	}
+	name := filepath.Base(entry.TargetName())
+	if strings.ContainsAny(name, " \t#*?[]{}\\") {
+		return "", nil, fmt.Errorf("%s: cannot set owner or group of target with special characters in its name", entry.TargetName())
+	}
+	attributesPath := filepath.Join(dir, ".chezmoiattributes")
+	data, ok := attributes[attributesPath]
+	if !ok {
+		var err error
+		data, err = c.fs.ReadFile(attributesPath)
+		if err != nil && !os.IsNotExist(err) {
+			return "", nil, err
+		}
+	}
+	data = chezmoi.SetOwnershipPattern(data, "/"+name, func(o chezmoi.Ownership) chezmoi.Ownership {
+		return chezmoi.Ownership{
+			Owner: ams.owner.modify(o.Owner),
+			Group: ams.group.modify(o.Group),
+		}
+	})
+	return attributesPath, data, nil
+}
+
 func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 	ams := &attributeModifiers{}
 	for _, attributeModifier := range strings.Split(s, ",") {
@@ -165,6 +299,21 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 		if attributeModifier == "" {
 			continue
 		}
+		if index := strings.IndexRune(attributeModifier, '='); index != -1 {
+			attribute, value := attributeModifier[:index], attributeModifier[index+1:]
+			if value == "" || strings.ContainsAny(value, " \t#") {
+				return nil, fmt.Errorf("%s: invalid value", attributeModifier)
+			}
+			switch attribute {
+			case "group":
+				ams.group = stringModifier{modified: true, value: value}
+			case "owner":
+				ams.owner = stringModifier{modified: true, value: value}
+			default:
+				return nil, fmt.Errorf("%s: unknown attribute", attribute)
+			}
+			continue
+		}
 		var modifier boolModifier
 		var attribute string
 		switch {
@@ -182,14 +331,34 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 			attribute = attributeModifier
 		}
 		switch attribute {
+		case "after":
+			ams.after = modifier
+		case "before":
+			ams.before = modifier
+		case "create":
+			ams.create = modifier
 		case "empty", "e":
 			ams.empty = modifier
 		case "encrypt":
 			ams.encrypt = modifier
 		case "exact":
 			ams.exact = modifier
+		case "group":
+			if modifier > 0 {
+				return nil, fmt.Errorf("%s: missing value", attribute)
+			}
+			ams.group = stringModifier{modified: true}
 		case "executable", "x":
 			ams.executable = modifier
+		case "link":
+			ams.link = modifier
+		case "onchange":
+			ams.onChange = modifier
+		case "owner":
+			if modifier > 0 {
+				return nil, fmt.Errorf("%s: missing value", attribute)
+			}
+			ams.owner = stringModifier{modified: true}
 		case "private", "p":
 			ams.private = modifier
 		case "template", "t":
@@ -198,6 +367,9 @@ func parseAttributeModifiers(s string) (*attributeModifiers, error) {
 			return nil, fmt.Errorf("%s: unknown attribute", attribute)
 		}
 	}
+	if ams.after > 0 && ams.before > 0 {
+		return nil, fmt.Errorf("%s: after and before are mutually exclusive", s)
+	}
 	return ams, nil
 }
 
@@ -211,3 +383,10 @@ func (bm boolModifier) modify(x bool) bool {
 		return x
 	}
 }
+
+func (sm stringModifier) modify(x string) string {
+	if sm.modified {
+		return sm.value
+	}
+	return x
+}
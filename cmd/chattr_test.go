@@ -119,6 +119,42 @@ func TestChattrCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "file_add_create",
+			args: []string{"+create", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"private_foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/private_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/create_private_foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+			},
+		},
+		{
+			name: "file_remove_create",
+			args: []string{"-create", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"create_foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/create_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+			},
+		},
 		{
 			name: "file_add_executable",
 			args: []string{"+executable", "/home/user/foo"},
@@ -155,6 +191,42 @@ func TestChattrCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "file_add_link",
+			args: []string{"+link", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/link_foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+			},
+		},
+		{
+			name: "file_remove_link",
+			args: []string{"-link", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"link_foo": "# contents of ~/foo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/foo",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# contents of ~/foo\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/link_foo",
+					vfst.TestDoesNotExist,
+				),
+			},
+		},
 		{
 			name: "file_add_private",
 			args: []string{"+private", "/home/user/foo"},
@@ -283,6 +355,129 @@ func TestChattrCommand(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "script_add_before",
+			args: []string{"+before", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"run_once_foo.tmpl": "#!/bin/sh\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_once_foo.tmpl",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_once_before_foo.tmpl",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("#!/bin/sh\n"),
+				),
+			},
+		},
+		{
+			name: "script_replace_before_with_after",
+			args: []string{"+after", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"run_before_foo": "#!/bin/sh\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_before_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_after_foo",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name: "script_replace_once_with_onchange",
+			args: []string{"+onchange", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"run_once_before_foo": "#!/bin/sh\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_once_before_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_onchange_before_foo",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name: "script_remove_after",
+			args: []string{"-after", "/home/user/foo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"run_after_foo": "#!/bin/sh\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_after_foo",
+					vfst.TestDoesNotExist,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_foo",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
+		{
+			name: "file_set_owner_and_group",
+			args: []string{"owner=root,group=wheel", "/home/user/.sudo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiattributes": "# comment\n/.bashrc group=users\n",
+					"dot_sudo":           "# contents of .sudo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/.chezmoiattributes",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# comment\n/.bashrc group=users\n/.sudo owner=root group=wheel\n"),
+				),
+			},
+		},
+		{
+			name: "file_remove_owner",
+			args: []string{"noowner", "/home/user/.sudo"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoiattributes": "/.sudo owner=root # comment\n/.sudo group=wheel\n",
+					"dot_sudo":           "# contents of .sudo\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/.chezmoiattributes",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("# comment\n/.sudo group=wheel\n"),
+				),
+			},
+		},
+		{
+			name: "dir_set_owner_and_private",
+			args: []string{"owner=root,private", "/home/user/etc/ssh", "/home/user/etc/ssh/sshd_config"},
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"etc/ssh/sshd_config": "# contents of sshd_config\n",
+				},
+			},
+			tests: []vfst.Test{
+				vfst.TestPath("/home/user/.local/share/chezmoi/etc/.chezmoiattributes",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("/ssh owner=root\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/etc/private_ssh/.chezmoiattributes",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("/sshd_config owner=root\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/etc/private_ssh/private_sshd_config",
+					vfst.TestModeIsRegular,
+				),
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			fs, cleanup, err := vfst.NewTestFS(tc.root)
@@ -336,6 +531,18 @@ func TestParseAttributeModifiers(t *testing.T) {
 		{s: "empty,executable,private,template", want: &attributeModifiers{empty: 1, executable: 1, private: 1, template: 1}},
 		{s: "+empty,+executable,+private,+template", want: &attributeModifiers{empty: 1, executable: 1, private: 1, template: 1}},
 		{s: "-empty,-executable,-private,-template", want: &attributeModifiers{empty: -1, executable: -1, private: -1, template: -1}},
+		{s: "before", want: &attributeModifiers{before: 1}},
+		{s: "nocreate", want: &attributeModifiers{create: -1}},
+		{s: "-after", want: &attributeModifiers{after: -1}},
+		{s: "after,before", wantErr: true},
+		{s: "onchange", want: &attributeModifiers{onChange: 1}},
+		{s: "owner=root", want: &attributeModifiers{owner: stringModifier{modified: true, value: "root"}}},
+		{s: "group=0,private", want: &attributeModifiers{group: stringModifier{modified: true, value: "0"}, private: 1}},
+		{s: "noowner", want: &attributeModifiers{owner: stringModifier{modified: true}}},
+		{s: "-group", want: &attributeModifiers{group: stringModifier{modified: true}}},
+		{s: "owner", wantErr: true},
+		{s: "owner=", wantErr: true},
+		{s: "empty=true", wantErr: true},
 		{s: "foo", wantErr: true},
 		{s: "empty,foo", wantErr: true},
 		{s: "empty,foo", wantErr: true},
@@ -45,6 +45,16 @@ type templateConfig struct {
 	Options []string
 }
 
+type backupConfig struct {
+	Dir     string
+	Enabled bool
+}
+
+type blockConfig struct {
+	Begin string
+	End   string
+}
+
 // A Config represents a configuration.
 type Config struct {
 	configFile        string
@@ -52,18 +62,24 @@ type Config struct {
 	fs                vfs.FS
 	mutator           chezmoi.Mutator
 	SourceDir         string
+	BaseSourceDirs    []string
 	DestDir           string
 	Umask             permValue
 	DryRun            bool
 	Follow            bool
+	Mode              chezmoi.Mode
 	Remove            bool
 	Verbose           bool
 	Color             string
 	Debug             bool
+	events            string
+	eventsFile        io.WriteCloser
 	GPG               chezmoi.GPG
 	GPGRecipient      string
 	SourceVCS         sourceVCSConfig
 	Template          templateConfig
+	Backup            backupConfig
+	Block             blockConfig
 	Merge             mergeConfig
 	Bitwarden         bitwardenCmdConfig
 	CD                cdCmdConfig
@@ -80,6 +96,8 @@ type Config struct {
 	maxDiffDataSize   int
 	templateFuncs     template.FuncMap
 	add               addCmdConfig
+	apply             applyCmdConfig
+	archive           archiveCmdConfig
 	completion        completionCmdConfig
 	data              dataCmdConfig
 	dump              dumpCmdConfig
@@ -88,15 +106,19 @@ type Config struct {
 	_import           importCmdConfig
 	init              initCmdConfig
 	keyring           keyringCmdConfig
+	lint              lintCmdConfig
 	managed           managedCmdConfig
 	purge             purgeCmdConfig
 	remove            removeCmdConfig
+	status            statusCmdConfig
 	update            updateCmdConfig
 	upgrade           upgradeCmdConfig
 	Stdin             io.Reader
+	stdinReader       *bufio.Reader
 	Stdout            io.Writer
 	Stderr            io.Writer
 	bds               *xdg.BaseDirectorySpecification
+	fileStateBucket   []byte
 	scriptStateBucket []byte
 }
 
@@ -104,6 +126,12 @@ type Config struct {
 type configOption func(*Config)
 
 var (
+	decodeFormatMap = map[string]func([]byte, interface{}) error{
+		"json": json.Unmarshal,
+		"toml": toml.Unmarshal,
+		"yaml": yaml.Unmarshal,
+	}
+
 	formatMap = map[string]func(io.Writer, interface{}) error{
 		"json": func(w io.Writer, value interface{}) error {
 			e := json.NewEncoder(w)
@@ -134,6 +162,7 @@ var (
 func newConfig(options ...configOption) *Config {
 	c := &Config{
 		Umask: permValue(getUmask()),
+		Mode:  chezmoi.ModeFile,
 		Color: "auto",
 		SourceVCS: sourceVCSConfig{
 			Command: "git",
@@ -141,6 +170,10 @@ func newConfig(options ...configOption) *Config {
 		Template: templateConfig{
 			Options: chezmoi.DefaultTemplateOptions,
 		},
+		Block: blockConfig{
+			Begin: chezmoi.DefaultBlockBegin,
+			End:   chezmoi.DefaultBlockEnd,
+		},
 		Diff: diffCmdConfig{
 			Format: "chezmoi",
 		},
@@ -150,8 +183,12 @@ func newConfig(options ...configOption) *Config {
 		GPG: chezmoi.GPG{
 			Command: "gpg",
 		},
+		archive: archiveCmdConfig{
+			include: []string{"all"},
+		},
 		maxDiffDataSize:   1 * 1024 * 1024, // 1MB
 		templateFuncs:     sprig.TxtFuncMap(),
+		fileStateBucket:   []byte("file"),
 		scriptStateBucket: []byte("script"),
 		Stdin:             os.Stdin,
 		Stdout:            os.Stdout,
@@ -182,14 +219,26 @@ func (c *Config) applyArgs(args []string, persistentState chezmoi.PersistentStat
 	applyOptions := &chezmoi.ApplyOptions{
 		DestDir:           ts.DestDir,
 		DryRun:            c.DryRun,
+		FileStateBucket:   c.fileStateBucket,
 		Ignore:            ts.TargetIgnore.Match,
 		PersistentState:   persistentState,
 		Remove:            c.Remove,
 		ScriptStateBucket: c.scriptStateBucket,
+		SourcePath:        ts.SourcePath,
 		Stdout:            c.Stdout,
 		Umask:             ts.Umask,
 		Verbose:           c.Verbose,
 	}
+	if c.apply.interactive {
+		applyOptions.Confirm = c.newApplyConfirmFunc()
+	}
+	// Conflicts are only detected when targets would actually be changed, so
+	// that commands like diff show all differences.
+	if !c.DryRun {
+		conflictFunc, cleanup := c.newApplyConflictFunc(ts)
+		defer cleanup()
+		applyOptions.Conflict = conflictFunc
+	}
 	if len(args) == 0 {
 		return ts.Apply(fs, c.mutator, c.Follow, applyOptions)
 	}
@@ -300,6 +349,13 @@ func (c *Config) ensureSourceDirectory() error {
 	}
 }
 
+func (c *Config) getBackupsDir() string {
+	if c.Backup.Dir != "" {
+		return c.Backup.Dir
+	}
+	return filepath.Join(filepath.Dir(c.getPersistentStateFile()), "backups")
+}
+
 func (c *Config) getData() (map[string]interface{}, error) {
 	defaultData, err := c.getDefaultData()
 	if err != nil {
@@ -431,8 +487,28 @@ func (c *Config) getPersistentStateFile() string {
 }
 
 func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chezmoi.TargetState, error) {
-	fs := vfs.NewReadOnlyFS(c.fs)
+	ts, err := c.newTargetState()
+	if err != nil {
+		return nil, err
+	}
+	if err := ts.Populate(vfs.NewReadOnlyFS(c.fs), populateOptions); err != nil {
+		return nil, err
+	}
+	if Version != nil && ts.MinVersion != nil && Version.LessThan(*ts.MinVersion) {
+		return nil, fmt.Errorf("chezmoi version %s too old, source state requires at least %s", Version, ts.MinVersion)
+	}
+	return ts, nil
+}
 
+func (c *Config) getVCS() (VCS, error) {
+	vcs, ok := vcses[filepath.Base(c.SourceVCS.Command)]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported source VCS command", c.SourceVCS.Command)
+	}
+	return vcs, nil
+}
+
+func (c *Config) newTargetState() (*chezmoi.TargetState, error) {
 	data, err := c.getData()
 	if err != nil {
 		return nil, err
@@ -446,35 +522,38 @@ func (c *Config) getTargetState(populateOptions *chezmoi.PopulateOptions) (*chez
 		}
 	}
 
+	switch c.Mode {
+	case chezmoi.ModeFile, chezmoi.ModeSymlink:
+	default:
+		return nil, fmt.Errorf("%s: unknown mode", c.Mode)
+	}
+
 	// For backwards compatibility, prioritize gpgRecipient over gpg.recipient.
 	if c.GPGRecipient != "" {
 		c.GPG.Recipient = c.GPGRecipient
 	}
 
-	ts := chezmoi.NewTargetState(
+	formats := make(map[string]chezmoi.Format)
+	for name, encode := range formatMap {
+		formats[name] = chezmoi.Format{
+			Decode: decodeFormatMap[name],
+			Encode: encode,
+		}
+	}
+
+	return chezmoi.NewTargetState(
+		chezmoi.WithBaseSourceDirs(c.BaseSourceDirs),
+		chezmoi.WithBlockMarkers(c.Block.Begin, c.Block.End),
 		chezmoi.WithDestDir(destDir),
+		chezmoi.WithFormats(formats),
 		chezmoi.WithGPG(&c.GPG),
+		chezmoi.WithMode(c.Mode),
 		chezmoi.WithSourceDir(c.SourceDir),
 		chezmoi.WithTemplateData(data),
 		chezmoi.WithTemplateFuncs(c.templateFuncs),
 		chezmoi.WithTemplateOptions(c.Template.Options),
 		chezmoi.WithUmask(os.FileMode(c.Umask)),
-	)
-	if err := ts.Populate(fs, populateOptions); err != nil {
-		return nil, err
-	}
-	if Version != nil && ts.MinVersion != nil && Version.LessThan(*ts.MinVersion) {
-		return nil, fmt.Errorf("chezmoi version %s too old, source state requires at least %s", Version, ts.MinVersion)
-	}
-	return ts, nil
-}
-
-func (c *Config) getVCS() (VCS, error) {
-	vcs, ok := vcses[filepath.Base(c.SourceVCS.Command)]
-	if !ok {
-		return nil, fmt.Errorf("%s: unsupported source VCS command", c.SourceVCS.Command)
-	}
-	return vcs, nil
+	), nil
 }
 
 func (c *Config) output(dir, name string, argv ...string) ([]byte, error) {
@@ -491,13 +570,17 @@ func (c *Config) output(dir, name string, argv ...string) ([]byte, error) {
 
 //nolint:unparam
 func (c *Config) prompt(s, choices string) (byte, error) {
-	r := bufio.NewReader(c.Stdin)
+	// Reuse the same buffered reader for every prompt so that input buffered
+	// by one prompt is not lost to the next.
+	if c.stdinReader == nil {
+		c.stdinReader = bufio.NewReader(c.Stdin)
+	}
 	for {
-		_, err := fmt.Printf("%s [%s]? ", s, strings.Join(strings.Split(choices, ""), ","))
+		_, err := fmt.Fprintf(c.Stdout, "%s [%s]? ", s, strings.Join(strings.Split(choices, ""), ","))
 		if err != nil {
 			return 0, err
 		}
-		line, err := r.ReadString('\n')
+		line, err := c.stdinReader.ReadString('\n')
 		if err != nil {
 			return 0, err
 		}
@@ -149,24 +149,54 @@ func withAddCmdConfig(add addCmdConfig) configOption {
 	}
 }
 
+func withApplyCmdConfig(apply applyCmdConfig) configOption {
+	return func(c *Config) {
+		c.apply = apply
+	}
+}
+
+func withBaseSourceDirs(baseSourceDirs []string) configOption {
+	return func(c *Config) {
+		c.BaseSourceDirs = baseSourceDirs
+	}
+}
+
 func withData(data map[string]interface{}) configOption {
 	return func(c *Config) {
 		c.Data = data
 	}
 }
 
+func withDataCmdConfig(dataCmdConfig dataCmdConfig) configOption {
+	return func(c *Config) {
+		c.data = dataCmdConfig
+	}
+}
+
 func withDestDir(destDir string) configOption {
 	return func(c *Config) {
 		c.DestDir = destDir
 	}
 }
 
+func withDryRun(dryRun bool) configOption {
+	return func(c *Config) {
+		c.DryRun = dryRun
+	}
+}
+
 func withDumpCmdConfig(dumpCmdConfig dumpCmdConfig) configOption {
 	return func(c *Config) {
 		c.dump = dumpCmdConfig
 	}
 }
 
+func withEditCmdConfig(editCmdConfig editCmdConfig) configOption {
+	return func(c *Config) {
+		c.edit = editCmdConfig
+	}
+}
+
 func withFollow(follow bool) configOption {
 	return func(c *Config) {
 		c.Follow = follow
@@ -179,6 +209,18 @@ func withGenericSecretCmdConfig(genericSecretCmdConfig genericSecretCmdConfig) c
 	}
 }
 
+func withLintCmdConfig(lintCmdConfig lintCmdConfig) configOption {
+	return func(c *Config) {
+		c.lint = lintCmdConfig
+	}
+}
+
+func withMode(mode chezmoi.Mode) configOption {
+	return func(c *Config) {
+		c.Mode = mode
+	}
+}
+
 func withMutator(mutator chezmoi.Mutator) configOption {
 	return func(c *Config) {
 		c.mutator = mutator
@@ -197,6 +239,18 @@ func withRemoveCmdConfig(remove removeCmdConfig) configOption {
 	}
 }
 
+func withStatusCmdConfig(statusCmdConfig statusCmdConfig) configOption {
+	return func(c *Config) {
+		c.status = statusCmdConfig
+	}
+}
+
+func withStderr(stderr io.Writer) configOption {
+	return func(c *Config) {
+		c.Stderr = stderr
+	}
+}
+
 func withStdin(stdin io.Reader) configOption {
 	return func(c *Config) {
 		c.Stdin = stdin
@@ -231,3 +285,9 @@ func withTestUser(username string) configOption {
 		}
 	}
 }
+
+func withVerbose(verbose bool) configOption {
+	return func(c *Config) {
+		c.Verbose = verbose
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	vfs "github.com/twpayne/go-vfs"
 )
 
 type dataCmdConfig struct {
@@ -33,9 +34,12 @@ func (c *Config) runDataCmd(cmd *cobra.Command, args []string) error {
 	if !ok {
 		return fmt.Errorf("%s: unknown format", c.data.format)
 	}
-	data, err := c.getData()
+	ts, err := c.newTargetState()
 	if err != nil {
 		return err
 	}
-	return format(c.Stdout, data)
+	if err := ts.PopulateTemplateData(vfs.NewReadOnlyFS(c.fs)); err != nil {
+		return err
+	}
+	return format(c.Stdout, ts.TemplateData)
 }
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestDataCmd(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		root interface{}
+		data map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "config_only",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+			},
+			data: map[string]interface{}{
+				"email": "user@example.com",
+			},
+			want: map[string]interface{}{
+				"email": "user@example.com",
+			},
+		},
+		{
+			name: "data_files",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoidata.json":            `{"colors": {"background": "black", "foreground": "white"}}`,
+					".chezmoidata.toml":            "packages = [\"git\", \"vim\"]\n",
+					"dot_config/.chezmoidata.yaml": "colors:\n  foreground: green\n",
+				},
+			},
+			want: map[string]interface{}{
+				"colors": map[string]interface{}{
+					"background": "black",
+					"foreground": "green",
+				},
+				"packages": []interface{}{"git", "vim"},
+			},
+		},
+		{
+			name: "config_takes_precedence",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.chezmoidata.yaml": "email: data@example.com\ncolors:\n  background: black\n",
+			},
+			data: map[string]interface{}{
+				"email": "config@example.com",
+				"colors": map[string]interface{}{
+					"foreground": "white",
+				},
+			},
+			want: map[string]interface{}{
+				"email": "config@example.com",
+				"colors": map[string]interface{}{
+					"background": "black",
+					"foreground": "white",
+				},
+			},
+		},
+		{
+			name: "broken_templates",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".chezmoidata.yaml": "email: data@example.com\n",
+					".chezmoiignore":    "{{ .missing }\n",
+					"dot_bashrc.tmpl":   "{{ end }}\n",
+				},
+			},
+			want: map[string]interface{}{
+				"email": "data@example.com",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withData(tc.data),
+				withDataCmdConfig(dataCmdConfig{
+					format: "json",
+				}),
+				withStdout(stdout),
+			)
+			require.NoError(t, c.runDataCmd(nil, nil))
+			var got map[string]interface{}
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &got))
+			delete(got, "chezmoi")
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -3,9 +3,11 @@
 package cmd
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,3 +37,78 @@ func TestDiffDoesNotRunScript(t *testing.T) {
 		),
 	)
 }
+
+func TestDiffOwnership(t *testing.T) {
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":  "# contents of .bashrc\n",
+			".create":  "# contents of .create\n",
+			".link":    &vfst.Symlink{Target: ".local/share/chezmoi/link_dot_link"},
+			".profile": "# contents of .profile\n",
+			".local/share/chezmoi": map[string]interface{}{
+				".chezmoiattributes": "# comment\n" +
+					".bashrc owner=" + uid + " group=" + gid + "\n" +
+					".create group=54321\n" +
+					".link group=54321\n" +
+					".profile owner=" + uid + "\n" +
+					".profile group=54321\n",
+				"create_dot_create": "# new contents of .create\n",
+				"dot_bashrc":        "# contents of .bashrc\n",
+				"dot_profile":       "# contents of .profile\n",
+				"link_dot_link":     "# contents of .link\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "chezmoi",
+			want: "" +
+				"chown -h :54321 /home/user/.create\n" +
+				"chown -h :54321 /home/user/.link\n" +
+				"chown -h :54321 /home/user/.profile\n",
+		},
+		{
+			format: "git",
+			want: "" +
+				"chown -h :54321 .create\n" +
+				"chown -h :54321 .link\n" +
+				"chown -h :54321 .profile\n",
+		},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(fs, withStdout(stdout))
+			c.Diff.Format = tc.format
+			assert.NoError(t, c.runDiffCmd(nil, nil))
+			assert.Equal(t, tc.want, stdout.String())
+		})
+	}
+}
+
+func TestDiffRemove(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".old": "# contents of .old\n",
+			".local/share/chezmoi": map[string]interface{}{
+				".chezmoiremove": ".old\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withRemove(true),
+		withStdout(stdout),
+		withVerbose(false),
+	)
+	assert.NoError(t, c.runDiffCmd(nil, nil))
+	assert.Equal(t, "rm -rf /home/user/.old\n", stdout.String())
+}
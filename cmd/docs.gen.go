@@ -673,6 +673,18 @@ func init() {
 		"\n" +
 		"    chezmoi data\n" +
 		"\n" +
+		"Data that is the same on all machines and is not secret, for example a list of\n" +
+		"packages or a color scheme, can be stored in the source directory in a\n" +
+		"`.chezmoidata.json`, `.chezmoidata.toml`, or `.chezmoidata.yaml` file, for\n" +
+		"example `~/.local/share/chezmoi/.chezmoidata.yaml` might contain:\n" +
+		"\n" +
+		"    packages:\n" +
+		"      - git\n" +
+		"      - vim\n" +
+		"\n" +
+		"Values in your config file take precedence over values in `.chezmoidata.*`\n" +
+		"files, so you can still override them on specific machines.\n" +
+		"\n" +
 		"For more advanced usage, you can use the full power of the\n" +
 		"[`text/template`](https://pkg.go.dev/text/template) language. chezmoi includes\n" +
 		"all of the text functions from [sprig](http://masterminds.github.io/sprig/) and\n" +
@@ -717,7 +729,10 @@ func init() {
 		"    f*\n" +
 		"    !foo\n" +
 		"\n" +
-		"will ignore all files beginning with an `f` except `foo`.\n" +
+		"will ignore all files beginning with an `f` except `foo`. As in `.gitignore`\n" +
+		"files, patterns are applied in order and the last pattern that matches wins,\n" +
+		"patterns beginning with a `/` only match in the directory containing the\n" +
+		"`.chezmoiignore` file, and patterns ending with a `/` only match directories.\n" +
 		"\n" +
 		"## Use completely separate config files on different machines\n" +
 		"\n" +
@@ -821,14 +836,20 @@ func init() {
 		"    chezmoi apply --remove\n" +
 		"\n" +
 		"chezmoi will remove anything in the target directory that matches the pattern.\n" +
-		"As this command is potentially dangerous, you should run chezmoi in verbose,\n" +
-		"dry-run mode beforehand to see what would be removed:\n" +
+		"As this command is potentially dangerous, you should run chezmoi in dry-run\n" +
+		"mode beforehand to list what would be removed:\n" +
 		"\n" +
-		"    chezmoi apply --remove --dry-run --verbose\n" +
+		"    chezmoi apply --remove --dry-run\n" +
 		"\n" +
+		"`.chezmoiremove` uses the same pattern rules as `.chezmoiignore`, so a pattern\n" +
+		"like `foo` matches `foo` in your home directory and in every directory managed\n" +
+		"by chezmoi. Use `/foo` to only match `foo` in your home directory.\n" +
 		"`.chezmoiremove` is interpreted as a template, so you can remove different files\n" +
-		"on different machines. Negative matches (patterns prefixed with a `!`) or\n" +
-		"targets listed in `.chezmoiignore` will never be removed.\n" +
+		"on different machines. Targets re-included by a negative pattern (prefixed with\n" +
+		"a `!`), targets listed in `.chezmoiignore`, and targets managed by chezmoi will\n" +
+		"never be removed. Patterns containing `**` are matched against every directory\n" +
+		"below the anchored part of the pattern, for example `/.cache/**/*.tmp` matches\n" +
+		"all `.tmp` files in `~/.cache`.\n" +
 		"\n" +
 		"## Include a subdirectory from another repository, like Oh My Zsh\n" +
 		"\n" +
@@ -879,6 +900,65 @@ func init() {
 		"Now, when the program modifies its configuration file it will modify the file in\n" +
 		"the source state instead.\n" +
 		"\n" +
+		"If you only want to manage part of a file, and let other programs manage the\n" +
+		"rest, use a modify script. A modify script is a file in the source directory\n" +
+		"with the `modify_` prefix. When chezmoi computes the target state, it runs the\n" +
+		"modify script with the current contents of the file on its standard input, and\n" +
+		"the modify script's standard output becomes the file's target contents. For\n" +
+		"example, to ensure that `~/.bashrc` exports `FOO=bar` while keeping any lines\n" +
+		"that installers append, create `modify_dot_bashrc` with the contents:\n" +
+		"\n" +
+		"    #!/bin/sh\n" +
+		"    sed '/^export FOO=/d'\n" +
+		"    echo export FOO=bar\n" +
+		"\n" +
+		"Modify scripts are run by `chezmoi apply`, `chezmoi diff`, and `chezmoi verify`,\n" +
+		"including in dry run mode, so they should not have any side effects. Modify\n" +
+		"scripts should be idempotent: running them on their own output should not change\n" +
+		"it. A modify script that is empty or only contains whitespace leaves the file\n" +
+		"unchanged.\n" +
+		"\n" +
+		"Files that should be created with some initial contents, and then left to be\n" +
+		"managed by another program, can be given the `create_` prefix instead.\n" +
+		"\n" +
+		"For JSON, TOML, and YAML files, such as VSCode's `settings.json`, you can manage\n" +
+		"only some of the settings by giving the source file the `merge_` prefix. The\n" +
+		"source file's document is deep-merged into the existing file: keys in the source\n" +
+		"file are set in the target file and all other keys are kept. For example,\n" +
+		"`merge_settings.json` with the contents:\n" +
+		"\n" +
+		"    {\n" +
+		"      \"files.trimTrailingWhitespace\": true\n" +
+		"    }\n" +
+		"\n" +
+		"ensures that `files.trimTrailingWhitespace` is always `true` without changing\n" +
+		"any other setting. The target file's format is determined by its extension,\n" +
+		"which must be one of `.json`, `.toml`, `.yaml`, or `.yml`. When the target file\n" +
+		"is updated it is reformatted, and comments are not preserved.\n" +
+		"\n" +
+		"To manage only a block of lines in a file, for example a `Host *` section in\n" +
+		"`~/.ssh/config`, give the source file the `block_` prefix. chezmoi will keep the\n" +
+		"lines between a begin marker line and an end marker line in the target file\n" +
+		"up to date with the source file's contents, leave the rest of the file\n" +
+		"unchanged, and append the block if it is missing. For example,\n" +
+		"`dot_ssh/block_config` with the contents:\n" +
+		"\n" +
+		"    Host *\n" +
+		"      ForwardAgent no\n" +
+		"\n" +
+		"will ensure that `~/.ssh/config` contains:\n" +
+		"\n" +
+		"    # BEGIN chezmoi managed block\n" +
+		"    Host *\n" +
+		"      ForwardAgent no\n" +
+		"    # END chezmoi managed block\n" +
+		"\n" +
+		"If the contents of the block are empty, for example because a template evaluates\n" +
+		"to an empty string, then the block is removed. The marker lines use the same\n" +
+		"line endings as the rest of the file, so files with Windows (CRLF) line endings\n" +
+		"keep them. The marker lines can be changed with the `block.begin` and\n" +
+		"`block.end` configuration variables.\n" +
+		"\n" +
 		"## Keep data private\n" +
 		"\n" +
 		"chezmoi automatically detects when files and directories are private when adding\n" +
@@ -939,6 +1019,10 @@ func init() {
 		"`chezmoi edit` will transparently decrypt the file before editing and re-encrypt\n" +
 		"it afterwards.\n" +
 		"\n" +
+		"Scripts can also be encrypted by giving them the `encrypted_` prefix after\n" +
+		"`run_`, for example `run_encrypted_once_register-license.sh`. They are decrypted\n" +
+		"before being executed.\n" +
+		"\n" +
 		"#### Asymmetric (private/public-key) encryption\n" +
 		"\n" +
 		"Specify the encryption key to use in your configuration file (`chezmoi.toml`)\n" +
@@ -1178,7 +1262,21 @@ func init() {
 		"\n" +
 		"Scripts are any file in the source directory with the prefix `run_`, and are\n" +
 		"executed in alphabetical order. Scripts that should only be run when their\n" +
-		"contents change have the prefix `run_once_`.\n" +
+		"contents change have the prefix `run_once_`. Scripts that should be run whenever\n" +
+		"their contents change, including changes back to previous contents, have the\n" +
+		"prefix `run_onchange_`. This is useful for scripts that are templates, for\n" +
+		"example a script that installs a list of packages from your template data can\n" +
+		"include a hash of the list so that it is run again whenever the list changes:\n" +
+		"\n" +
+		"    # packages hash: {{ .packages | join \" \" | sha256sum }}\n" +
+		"\n" +
+		"By default, scripts are run in order with the other entries in the target state,\n" +
+		"so a script runs after the files that sort before it have been written. Scripts\n" +
+		"with the `before_` attribute, for example `run_before_install-packages.sh` or\n" +
+		"`run_once_before_install-packages.sh`, are run before any files, directories, or\n" +
+		"symlinks are updated. Scripts with the `after_` attribute are run after\n" +
+		"everything else. All templates are executed before any script is run, so an\n" +
+		"error in a template stops `chezmoi apply` before it runs any scripts.\n" +
 		"\n" +
 		"Scripts break chezmoi's declarative approach, and as such should be used\n" +
 		"sparingly. Any script should be idempotent, even `run_once_` scripts.\n" +
@@ -1498,10 +1596,12 @@ func init() {
 		"<!--- toc --->\n" +
 		"* [Concepts](#concepts)\n" +
 		"* [Global command line flags](#global-command-line-flags)\n" +
+		"  * [`--backup`](#--backup)\n" +
 		"  * [`--color` *value*](#--color-value)\n" +
 		"  * [`-c`, `--config` *filename*](#-c---config-filename)\n" +
 		"  * [`--debug`](#--debug)\n" +
 		"  * [`-D`, `--destination` *directory*](#-d---destination-directory)\n" +
+		"  * [`--events` *filename*](#--events-filename)\n" +
 		"  * [`-f`, `--follow`](#-f---follow)\n" +
 		"  * [`-n`, `--dry-run`](#-n---dry-run)\n" +
 		"  * [`-h`, `--help`](#-h---help)\n" +
@@ -1512,8 +1612,11 @@ func init() {
 		"* [Configuration file](#configuration-file)\n" +
 		"  * [Configuration variables](#configuration-variables)\n" +
 		"* [Source state attributes](#source-state-attributes)\n" +
+		"* [Base source directories](#base-source-directories)\n" +
 		"* [Special files and directories](#special-files-and-directories)\n" +
 		"  * [`.chezmoi.<format>.tmpl`](#chezmoiformattmpl)\n" +
+		"  * [`.chezmoiattributes`](#chezmoiattributes)\n" +
+		"  * [`.chezmoidata.<format>`](#chezmoidataformat)\n" +
 		"  * [`.chezmoiignore`](#chezmoiignore)\n" +
 		"  * [`.chezmoiremove`](#chezmoiremove)\n" +
 		"  * [`.chezmoitemplates`](#chezmoitemplates)\n" +
@@ -1540,15 +1643,18 @@ func init() {
 		"  * [`hg` [*arguments]](#hg-arguments)\n" +
 		"  * [`init` [*repo*]](#init-repo)\n" +
 		"  * [`import` *filename*](#import-filename)\n" +
+		"  * [`lint`](#lint)\n" +
 		"  * [`manage` *targets*](#manage-targets)\n" +
 		"  * [`managed`](#managed)\n" +
 		"  * [`merge` *targets*](#merge-targets)\n" +
 		"  * [`purge`](#purge)\n" +
 		"  * [`remove` *targets*](#remove-targets)\n" +
+		"  * [`restore-backup` [*backup*]](#restore-backup-backup)\n" +
 		"  * [`rm` *targets*](#rm-targets)\n" +
 		"  * [`secret`](#secret)\n" +
 		"  * [`source` [*args*]](#source-args)\n" +
 		"  * [`source-path` [*targets*]](#source-path-targets)\n" +
+		"  * [`status` [*targets*]](#status-targets)\n" +
 		"  * [`unmanage` *targets*](#unmanage-targets)\n" +
 		"  * [`unmanaged`](#unmanaged)\n" +
 		"  * [`update`](#update)\n" +
@@ -1560,7 +1666,10 @@ func init() {
 		"* [Template variables](#template-variables)\n" +
 		"* [Template functions](#template-functions)\n" +
 		"  * [`bitwarden` [*args*]](#bitwarden-args)\n" +
+		"  * [`decrypt` *path*](#decrypt-path)\n" +
 		"  * [`gopass` *gopass-name*](#gopass-gopass-name)\n" +
+		"  * [`include` *path*](#include-path)\n" +
+		"  * [`includeTemplate` *name* [*args*]](#includetemplate-name-args)\n" +
 		"  * [`keepassxc` *entry*](#keepassxc-entry)\n" +
 		"  * [`keepassxcAttribute` *entry* *attribute*](#keepassxcattribute-entry-attribute)\n" +
 		"  * [`keyring` *service* *user*](#keyring-service-user)\n" +
@@ -1568,6 +1677,7 @@ func init() {
 		"  * [`lastpassRaw` *id*](#lastpassraw-id)\n" +
 		"  * [`onepassword` *uuid*](#onepassword-uuid)\n" +
 		"  * [`onepasswordDocument` *uuid*](#onepassworddocument-uuid)\n" +
+		"  * [`output` *name* [*args*]](#output-name-args)\n" +
 		"  * [`pass` *pass-name*](#pass-pass-name)\n" +
 		"  * [`promptString` *prompt*](#promptstring-prompt)\n" +
 		"  * [`secret` [*args*]](#secret-args)\n" +
@@ -1602,6 +1712,17 @@ func init() {
 		"\n" +
 		"Command line flags override any values set in the configuration file.\n" +
 		"\n" +
+		"### `--backup`\n" +
+		"\n" +
+		"Before any target in the destination directory is overwritten, renamed, has\n" +
+		"its permissions, owner, or group changed, or is removed by any command, copy it\n" +
+		"into a new backup directory. Files in the source directory are never backed up.\n" +
+		"Each run creates at most one backup directory, named with the current UTC time,\n" +
+		"containing the original files and a `manifest.json` describing them. Backups\n" +
+		"are stored in `backup.dir`, which defaults to a `backups` directory next to\n" +
+		"chezmoi's state file. Backups are never made in dry run mode. Use `chezmoi\n" +
+		"restore-backup` to restore a backup.\n" +
+		"\n" +
 		"### `--color` *value*\n" +
 		"\n" +
 		"Colorize diffs, *value* can be `on`, `off`, or `auto`. The default value is\n" +
@@ -1619,6 +1740,42 @@ func init() {
 		"\n" +
 		"Use *directory* as the destination directory.\n" +
 		"\n" +
+		"### `--events` *filename*\n" +
+		"\n" +
+		"Write an event to *filename* for every change that chezmoi makes or would make,\n" +
+		"as one JSON object per line. If *filename* is of the form `fd:`*n* then events\n" +
+		"are written to the already-open file descriptor *n*. Events are written\n" +
+		"alongside chezmoi's normal output. `--events` is not supported by the `diff`,\n" +
+		"`status`, and `verify` commands.\n" +
+		"\n" +
+		"Each event has the following fields, omitted if they do not apply:\n" +
+		"\n" +
+		"| Field        | Description                                                  |\n" +
+		"| ------------ | ------------------------------------------------------------ |\n" +
+		"| `op`         | The operation, e.g. `writeFile`, `chmod`, or `scriptFinish`  |\n" +
+		"| `path`       | The target                                                   |\n" +
+		"| `newPath`    | The new name of the target, for `rename`                     |\n" +
+		"| `cmd`        | The command, for `runCmd` and `idempotentCmdOutput`          |\n" +
+		"| `oldMode`    | The mode of the target before the operation                  |\n" +
+		"| `newMode`    | The mode of the target after the operation                   |\n" +
+		"| `oldSHA256`  | The SHA256 sum of the contents before the operation          |\n" +
+		"| `newSHA256`  | The SHA256 sum of the contents after the operation           |\n" +
+		"| `uid`, `gid` | The new owner and group, for `lchown`                        |\n" +
+		"| `exitStatus` | The exit status, for commands and `scriptFinish`             |\n" +
+		"| `duration`   | The duration of the operation in nanoseconds                 |\n" +
+		"| `error`      | The error, if the operation failed                           |\n" +
+		"| `dryRun`     | `true` if the operation was not performed, with `--dry-run`  |\n" +
+		"\n" +
+		"Scripts generate a `scriptStart` event before they are run and a\n" +
+		"`scriptFinish` event after. With `--dry-run`, scripts are not run, so their\n" +
+		"`scriptFinish` events have no `exitStatus`. The SHA256 sum of a symlink is the SHA256 sum of\n" +
+		"its target.\n" +
+		"\n" +
+		"#### `--events` examples\n" +
+		"\n" +
+		"    chezmoi apply --events chezmoi-events.jsonl\n" +
+		"    chezmoi apply --events fd:3 3>&1 >/dev/null\n" +
+		"\n" +
 		"### `-f`, `--follow`\n" +
 		"\n" +
 		"If the last part of a target is a symlink, deal with what the symlink\n" +
@@ -1636,7 +1793,9 @@ func init() {
 		"\n" +
 		"### `-r`. `--remove`\n" +
 		"\n" +
-		"Also remove targets according to `.chezmoiremove`.\n" +
+		"Also remove targets according to `.chezmoiremove`. If `apply` is run with\n" +
+		"`--dry-run` and without `--verbose`, print the targets that would be removed,\n" +
+		"one per line, without removing them.\n" +
 		"\n" +
 		"### `-S`, `--source` *directory*\n" +
 		"\n" +
@@ -1668,39 +1827,45 @@ func init() {
 		"\n" +
 		"The following configuration variables are available:\n" +
 		"\n" +
-		"| Variable                | Type     | Default value             | Description                                         |\n" +
-		"| ----------------------- | -------- | ------------------------- | --------------------------------------------------- |\n" +
-		"| `bitwarden.command`     | string   | `bw`                      | Bitwarden CLI command                               |\n" +
-		"| `cd.command`            | string   | *none*                    | Shell to run in `cd` command                        |\n" +
-		"| `color`                 | string   | `auto`                    | Colorize diffs                                      |\n" +
-		"| `data`                  | any      | *none*                    | Template data                                       |\n" +
-		"| `destDir`               | string   | `~`                       | Destination directory                               |\n" +
-		"| `diff.format`           | string   | `chezmoi`                 | Diff format, either `chezmoi` or `git`              |\n" +
-		"| `diff.pager`            | string   | *none*                    | Pager                                               |\n" +
-		"| `dryRun`                | bool     | `false`                   | Dry run mode                                        |\n" +
-		"| `follow`                | bool     | `false`                   | Follow symlinks                                     |\n" +
-		"| `genericSecret.command` | string   | *none*                    | Generic secret command                              |\n" +
-		"| `gopass.command`        | string   | `gopass`                  | gopass CLI command                                  |\n" +
-		"| `gpg.command`           | string   | `gpg`                     | GPG CLI command                                     |\n" +
-		"| `gpg.recipient`         | string   | *none*                    | GPG recipient                                       |\n" +
-		"| `gpg.symmetric`         | bool     | `false`                   | Use symmetric GPG encryption                        |\n" +
-		"| `keepassxc.args`        | []string | *none*                    | Extra args to KeePassXC CLI command                 |\n" +
-		"| `keepassxc.command`     | string   | `keepassxc-cli`           | KeePassXC CLI command                               |\n" +
-		"| `keepassxc.database`    | string   | *none*                    | KeePassXC database                                  |\n" +
-		"| `lastpass.command`      | string   | `lpass`                   | Lastpass CLI command                                |\n" +
-		"| `merge.args`            | []string | *none*                    | Extra args to 3-way merge command                   |\n" +
-		"| `merge.command`         | string   | `vimdiff`                 | 3-way merge command                                 |\n" +
-		"| `onepassword.command`   | string   | `op`                      | 1Password CLI command                               |\n" +
-		"| `pass.command`          | string   | `pass`                    | Pass CLI command                                    |\n" +
-		"| `remove`                | bool     | `false`                   | Remove targets                                      |\n" +
-		"| `sourceDir`             | string   | `~/.local/share/chezmoi`  | Source directory                                    |\n" +
-		"| `sourceVCS.autoCommit`  | bool     | `false`                   | Commit changes to the source state after any change |\n" +
-		"| `sourceVCS.autoPush`    | bool     | `false`                   | Push changes to the source state after any change   |\n" +
-		"| `sourceVCS.command`     | string   | `git`                     | Source version control system                       |\n" +
-		"| `template.options`      | []string | `[\"missingkey=error\"]`    | Template options                                    |\n" +
-		"| `umask`                 | int      | *from system*             | Umask                                               |\n" +
-		"| `vault.command`         | string   | `vault`                   | Vault CLI command                                   |\n" +
-		"| `verbose`               | bool     | `false`                   | Verbose mode                                        |\n" +
+		"| Variable                | Type     | Default value                   | Description                                         |\n" +
+		"| ----------------------- | -------- | ------------------------------- | --------------------------------------------------- |\n" +
+		"| `backup.dir`            | string   | *none*                          | Directory to store backups in                       |\n" +
+		"| `backup.enabled`        | bool     | `false`                         | Back up targets before changing them                |\n" +
+		"| `baseSourceDirs`        | []string | *none*                          | Source directories that `sourceDir` is layered on   |\n" +
+		"| `bitwarden.command`     | string   | `bw`                            | Bitwarden CLI command                               |\n" +
+		"| `block.begin`           | string   | `# BEGIN chezmoi managed block` | Begin marker line of managed blocks                 |\n" +
+		"| `block.end`             | string   | `# END chezmoi managed block`   | End marker line of managed blocks                   |\n" +
+		"| `cd.command`            | string   | *none*                          | Shell to run in `cd` command                        |\n" +
+		"| `color`                 | string   | `auto`                          | Colorize diffs                                      |\n" +
+		"| `data`                  | any      | *none*                          | Template data                                       |\n" +
+		"| `destDir`               | string   | `~`                             | Destination directory                               |\n" +
+		"| `diff.format`           | string   | `chezmoi`                       | Diff format, either `chezmoi` or `git`              |\n" +
+		"| `diff.pager`            | string   | *none*                          | Pager                                               |\n" +
+		"| `dryRun`                | bool     | `false`                         | Dry run mode                                        |\n" +
+		"| `follow`                | bool     | `false`                         | Follow symlinks                                     |\n" +
+		"| `genericSecret.command` | string   | *none*                          | Generic secret command                              |\n" +
+		"| `gopass.command`        | string   | `gopass`                        | gopass CLI command                                  |\n" +
+		"| `gpg.command`           | string   | `gpg`                           | GPG CLI command                                     |\n" +
+		"| `gpg.recipient`         | string   | *none*                          | GPG recipient                                       |\n" +
+		"| `gpg.symmetric`         | bool     | `false`                         | Use symmetric GPG encryption                        |\n" +
+		"| `keepassxc.args`        | []string | *none*                          | Extra args to KeePassXC CLI command                 |\n" +
+		"| `keepassxc.command`     | string   | `keepassxc-cli`                 | KeePassXC CLI command                               |\n" +
+		"| `keepassxc.database`    | string   | *none*                          | KeePassXC database                                  |\n" +
+		"| `lastpass.command`      | string   | `lpass`                         | Lastpass CLI command                                |\n" +
+		"| `merge.args`            | []string | *none*                          | Extra args to 3-way merge command                   |\n" +
+		"| `merge.command`         | string   | `vimdiff`                       | 3-way merge command                                 |\n" +
+		"| `mode`                  | string   | `file`                          | Mode, `file` or `symlink`                           |\n" +
+		"| `onepassword.command`   | string   | `op`                            | 1Password CLI command                               |\n" +
+		"| `pass.command`          | string   | `pass`                          | Pass CLI command                                    |\n" +
+		"| `remove`                | bool     | `false`                         | Remove targets                                      |\n" +
+		"| `sourceDir`             | string   | `~/.local/share/chezmoi`        | Source directory                                    |\n" +
+		"| `sourceVCS.autoCommit`  | bool     | `false`                         | Commit changes to the source state after any change |\n" +
+		"| `sourceVCS.autoPush`    | bool     | `false`                         | Push changes to the source state after any change   |\n" +
+		"| `sourceVCS.command`     | string   | `git`                           | Source version control system                       |\n" +
+		"| `template.options`      | []string | `[\"missingkey=error\"]`          | Template options                                    |\n" +
+		"| `umask`                 | int      | *from system*                   | Umask                                               |\n" +
+		"| `vault.command`         | string   | `vault`                         | Vault CLI command                                   |\n" +
+		"| `verbose`               | bool     | `false`                         | Verbose mode                                        |\n" +
 		"\n" +
 		"## Source state attributes\n" +
 		"\n" +
@@ -1714,8 +1879,16 @@ func init() {
 		"\n" +
 		"| Prefix       | Effect                                                                         |\n" +
 		"| ------------ | ------------------------------------------------------------------------------ |\n" +
-		"| `encrypted_` | Encrypt the file in the source state.                                          |\n" +
+		"| `block_`     | Only manage a block of lines in the target file.                               |\n" +
+		"| `create_`    | Only create the target file if it does not already exist.                      |\n" +
+		"| `modify_`    | Treat the contents as a script that modifies an existing file.                 |\n" +
+		"| `merge_`     | Deep-merge the contents into an existing JSON, TOML, or YAML file.             |\n" +
+		"| `link_`      | Create a symlink to the source file instead of a regular file, see below.      |\n" +
+		"| `encrypted_` | Encrypt the file or script in the source state.                                |\n" +
 		"| `once_`      | Only run script once.                                                          |\n" +
+		"| `onchange_`  | Only run script when its contents have changed since it was last run.          |\n" +
+		"| `before_`    | Run script before updating the destination.                                    |\n" +
+		"| `after_`     | Run script after updating the destination.                                     |\n" +
 		"| `private_`   | Remove all group and world permissions from the target file or directory.      |\n" +
 		"| `empty_`     | Ensure the file exists, even if is empty. By default, empty files are removed. |\n" +
 		"| `exact_`     | Remove anything not managed by chezmoi.                                        |\n" +
@@ -1728,17 +1901,58 @@ func init() {
 		"| ------- | ---------------------------------------------------- |\n" +
 		"| `.tmpl` | Treat the contents of the source file as a template. |\n" +
 		"\n" +
-		"Order of prefixes is important, the order is `run_`, `exact_`, `private_`,\n" +
-		"`empty_`, `executable_`, `symlink_`, `once_`, `dot_`.\n" +
+		"Order of prefixes is important, the order is `run_` or `block_`, `create_`,\n" +
+		"`modify_`, `merge_`, or `link_`, `exact_`, `encrypted_`, `private_`, `empty_`,\n" +
+		"`executable_`, `symlink_`, `once_` or `onchange_`, `before_` or `after_`,\n" +
+		"`dot_`.\n" +
+		"\n" +
+		"Each target must be created by exactly one entry in the source state. If two or\n" +
+		"more entries in the source state have the same target, for example `dot_bashrc`\n" +
+		"and `dot_bashrc.tmpl`, then chezmoi reports an error listing all of them.\n" +
 		"\n" +
 		"Different target types allow different prefixes and suffixes:\n" +
 		"\n" +
-		"| Target type   | Allowed prefixes                                          | Allowed suffixes |\n" +
-		"| ------------- | --------------------------------------------------------- | ---------------- |\n" +
-		"| Directory     | `exact_`, `private_`, `dot_`                              | *none*           |\n" +
-		"| Regular file  | `encrypted_`, `private_`, `empty_`, `executable_`, `dot_` | `.tmpl`          |\n" +
-		"| Script        | `run_`, `once_`                                           | `.tmpl`          |\n" +
-		"| Symbolic link | `symlink_`, `dot_`,                                       | `.tmpl`          |\n" +
+		"| Target type   | Allowed prefixes                                                                                      | Allowed suffixes |\n" +
+		"| ------------- | ----------------------------------------------------------------------------------------------------- | ---------------- |\n" +
+		"| Directory     | `exact_`, `private_`, `dot_`                                                                          | *none*           |\n" +
+		"| Regular file  | `create_`, `modify_`, `merge_`, or `link_`, `encrypted_`, `private_`, `empty_`, `executable_`, `dot_` | `.tmpl`          |\n" +
+		"| Script        | `run_`, `encrypted_`, `once_` or `onchange_`, `before_` or `after_`                                   | `.tmpl`          |\n" +
+		"| Managed block | `block_`, `dot_`                                                                                      | `.tmpl`          |\n" +
+		"| Symbolic link | `symlink_`, `dot_`,                                                                                   | `.tmpl`          |\n" +
+		"\n" +
+		"Regular files with the `link_` prefix, or all regular files if the `mode`\n" +
+		"configuration variable is set to `symlink`, are applied as symlinks from the\n" +
+		"target to the file in the source directory, so that edits to the target are\n" +
+		"made directly in the source directory. Only files that are not templates,\n" +
+		"encrypted, empty, executable, or private, and that do not have the `create_`,\n" +
+		"`modify_`, or `merge_` prefixes, are linked. All other files are written as\n" +
+		"regular files.\n" +
+		"`chezmoi verify` considers a correct symlink to be in sync, and `chezmoi add`\n" +
+		"ignores symlinks to the source file of a linked target.\n" +
+		"\n" +
+		"## Base source directories\n" +
+		"\n" +
+		"The source state can be built from several source directories by setting the\n" +
+		"`baseSourceDirs` configuration variable, for example to a shared company\n" +
+		"dotfiles repo. Each of the `baseSourceDirs` is read in order, followed by\n" +
+		"`sourceDir`. Later source directories override earlier ones:\n" +
+		"\n" +
+		"* An entry for a target replaces the entry for the same target from an earlier\n" +
+		"  source directory, except that the contents of directories are merged.\n" +
+		"* `.chezmoiignore` and `.chezmoiremove` patterns are added in order, so a later\n" +
+		"  pattern prefixed with `!` re-includes a target ignored by an earlier source\n" +
+		"  directory.\n" +
+		"* Templates in `.chezmoitemplates` and values in `.chezmoidata.<format>` files\n" +
+		"  replace those with the same name from earlier source directories.\n" +
+		"* The `include` and `decrypt` template functions read files from the latest\n" +
+		"  source directory that contains them.\n" +
+		"\n" +
+		"`chezmoi add` always writes to `sourceDir`, creating parent directories there\n" +
+		"as needed, so that an added target overrides any entry in the base source\n" +
+		"directories. Commands that operate on existing entries, including `chattr`,\n" +
+		"`edit`, `forget`, `merge`, `remove`, and `source-path`, operate on the source\n" +
+		"file in the source directory that provides the entry. `chezmoi dump` lists the\n" +
+		"source paths of any entries that an entry overrides in its `overrides` field.\n" +
 		"\n" +
 		"## Special files and directories\n" +
 		"\n" +
@@ -1757,18 +1971,82 @@ func init() {
 		"    data:\n" +
 		"        email: \"{{ $email }}\"\n" +
 		"\n" +
+		"### `.chezmoiattributes`\n" +
+		"\n" +
+		"If a file called `.chezmoiattributes` exists in the source state then it is\n" +
+		"interpreted as a set of patterns that set the owner and group of targets. Each\n" +
+		"line contains a pattern followed by one or more `owner=`*owner* and\n" +
+		"`group=`*group* attributes, where *owner* and *group* are either names or\n" +
+		"numeric ids. Patterns follow the same rules as `.chezmoiignore` patterns and\n" +
+		"match against the target path. A pattern that matches a directory also matches\n" +
+		"everything in it, and a pattern that names a single target sets the ownership\n" +
+		"of just that target.\n" +
+		"\n" +
+		"When more than one pattern matches a target, later patterns override the owner\n" +
+		"or group set by earlier ones. Targets that are not matched by any pattern keep\n" +
+		"the owner and group of the user running chezmoi.\n" +
+		"\n" +
+		"Comments are introduced with the `#` character and run until the end of the\n" +
+		"line. `.chezmoiattributes` is interpreted as a template and\n" +
+		"`.chezmoiattributes` files in subdirectories apply only to that subdirectory.\n" +
+		"\n" +
+		"The owner and group of a single target can also be set with `chezmoi chattr`.\n" +
+		"\n" +
+		"Setting the owner and group usually requires chezmoi to run as root, typically\n" +
+		"with `--destination /`. `chezmoi diff` and `chezmoi verify` report targets whose\n" +
+		"owner or group differ from their attributes. Setting the owner and group is\n" +
+		"not supported on Windows.\n" +
+		"\n" +
+		"#### `.chezmoiattributes` examples\n" +
+		"\n" +
+		"    etc owner=root group=root\n" +
+		"    etc/sudoers group=wheel # the owner is still root\n" +
+		"    {{- if eq .chezmoi.os \"darwin\" }}\n" +
+		"    etc/hosts group=admin\n" +
+		"    {{- end }}\n" +
+		"\n" +
+		"### `.chezmoidata.<format>`\n" +
+		"\n" +
+		"If a file called `.chezmoidata.<format>` exists in the source state, it is\n" +
+		"interpreted as template data in the given format. *format* must be one of\n" +
+		"`json`, `toml`, or `yaml`. `.chezmoidata.<format>` files can be in the root of\n" +
+		"the source state or in any subdirectory. The `.chezmoidata.<format>` files in a\n" +
+		"directory are read before anything else in that directory, so their values are\n" +
+		"available to the `.chezmoiignore`, `.chezmoiremove`, and `.chezmoiattributes`\n" +
+		"templates in that directory and its subdirectories, and in later source\n" +
+		"directories. `.chezmoidata.<format>` files in ignored directories are not read.\n" +
+		"Template data from the config file takes precedence over template data from\n" +
+		"`.chezmoidata.<format>` files.\n" +
+		"\n" +
+		"#### `.chezmoidata.<format>` examples\n" +
+		"\n" +
+		"    colors:\n" +
+		"      background: black\n" +
+		"      foreground: white\n" +
+		"    packages:\n" +
+		"      - git\n" +
+		"      - vim\n" +
+		"\n" +
 		"### `.chezmoiignore`\n" +
 		"\n" +
 		"If a file called `.chezmoiignore` exists in the source state then it is\n" +
-		"interpreted as a set of patterns to ignore. Patterns are matched using\n" +
-		"[`doublestar.PathMatch`](https://pkg.go.dev/github.com/bmatcuk/doublestar?tab=doc#PathMatch)\n" +
-		"and match against the target path, not the source path.\n" +
-		"\n" +
-		"Patterns can be excluded by prefixing them with a `!` character. All excludes\n" +
-		"take priority over all includes.\n" +
+		"interpreted as a set of patterns to ignore. Patterns follow the same rules as\n" +
+		"`.gitignore` files and match against the target path, not the source path:\n" +
+		"\n" +
+		"* Patterns are matched using\n" +
+		"  [`doublestar.PathMatch`](https://pkg.go.dev/github.com/bmatcuk/doublestar?tab=doc#PathMatch).\n" +
+		"* A pattern that begins with a `/`, or that contains a `/` other than at its\n" +
+		"  end, is anchored to the directory containing the `.chezmoiignore` file. All\n" +
+		"  other patterns match at any depth.\n" +
+		"* A pattern that ends with a `/` only matches directories.\n" +
+		"* A pattern prefixed with a `!` re-includes targets matched by earlier\n" +
+		"  patterns.\n" +
+		"* Patterns are applied in order and the last pattern that matches a target\n" +
+		"  determines whether it is ignored. Targets in ignored directories are always\n" +
+		"  ignored.\n" +
 		"\n" +
 		"Comments are introduced with the `#` character and run until the end of the\n" +
-		"line.\n" +
+		"line. Invalid patterns are reported with their file and line number.\n" +
 		"\n" +
 		"`.chezmoiignore` is interpreted as a template. This allows different files to be\n" +
 		"ignored on different machines.\n" +
@@ -1777,10 +2055,12 @@ func init() {
 		"\n" +
 		"#### `.chezmoiignore` examples\n" +
 		"\n" +
-		"    README.md\n" +
+		"    /README.md\n" +
 		"\n" +
-		"    *.txt   # ignore *.txt in the target directory\n" +
+		"    /*.txt  # ignore *.txt in the target directory\n" +
 		"    */*.txt # ignore *.txt in subdirectories of the target directory\n" +
+		"    *.swp   # ignore *.swp everywhere\n" +
+		"    .cache/ # ignore all .cache directories\n" +
 		"\n" +
 		"    {{- if ne .email \"john.smith@company.com\" }}\n" +
 		"    # Ignore .company-directory unless configured with a company email\n" +
@@ -1795,13 +2075,29 @@ func init() {
 		"\n" +
 		"If a file called `.chezmoiremove` exists in the source state then it is\n" +
 		"interpreted as a list of targets to remove. `.chezmoiremove` is interpreted as a\n" +
-		"template.\n" +
+		"template and its patterns follow the same rules as `.chezmoiignore`.\n" +
+		"\n" +
+		"Unanchored patterns only match targets in the destination directory itself and\n" +
+		"in directories managed by chezmoi. Other directories are not searched. Anchored\n" +
+		"patterns, including those containing `**`, match every target below their\n" +
+		"anchored part, whether or not it is managed by chezmoi. Use an anchored pattern,\n" +
+		"like `/foo`, to only match a target in the destination directory itself, or to\n" +
+		"remove a target in a directory that chezmoi does not manage. See\n" +
+		"[`CHANGES.md`](https://github.com/twpayne/chezmoi/blob/master/docs/CHANGES.md)\n" +
+		"for how this differs from earlier versions of chezmoi.\n" +
+		"\n" +
+		"Targets that are managed by chezmoi, or that contain targets that are managed\n" +
+		"by chezmoi, cannot be removed. If any pattern in `.chezmoiremove` matches such a\n" +
+		"target then chezmoi reports an error listing the patterns and targets and\n" +
+		"removes nothing.\n" +
 		"\n" +
 		"### `.chezmoitemplates`\n" +
 		"\n" +
 		"If a directory called `.chezmoitemplates` exists, then all files in this\n" +
 		"directory are parsed as templates are available as templates with a name equal\n" +
-		"to the relative path of the file.\n" +
+		"to the relative path of the file. They are parsed with the same template\n" +
+		"functions and options as all other templates. They can be included with\n" +
+		"`template`, or with `includeTemplate` to pass them extra data.\n" +
 		"\n" +
 		"#### `.chezmoitemplates` examples\n" +
 		"\n" +
@@ -1879,20 +2175,81 @@ func init() {
 		"Ensure that *targets* are in the target state, updating them if necessary. If no\n" +
 		"targets are specified, the state of all targets are ensured.\n" +
 		"\n" +
+		"chezmoi records a hash of every file that it writes. If a file has been\n" +
+		"changed since chezmoi last wrote it, for example by editing it by hand, and\n" +
+		"does not match the target state, then the file is skipped with a warning.\n" +
+		"Files with the `modify_` or `merge_` prefixes are never skipped.\n" +
+		"\n" +
+		"#### `--force`\n" +
+		"\n" +
+		"Overwrite files that have been changed since chezmoi last wrote them.\n" +
+		"\n" +
+		"#### `-i`, `--interactive`\n" +
+		"\n" +
+		"For each target that would change, print its diff in the format set by\n" +
+		"`diff.format` and prompt whether to apply it (`y`), skip it (`n`), quit (`q`),\n" +
+		"or apply it and all remaining targets without prompting (`a`). The contents of\n" +
+		"scripts are printed before prompting whether to run them. Skipping a directory\n" +
+		"only skips changes to the directory itself. Everything in it is still prompted\n" +
+		"for, unless the directory does not exist.\n" +
+		"\n" +
+		"#### `--merge`\n" +
+		"\n" +
+		"Run `chezmoi merge` on files that have been changed since chezmoi last wrote\n" +
+		"them, instead of skipping them.\n" +
+		"\n" +
 		"#### `apply` examples\n" +
 		"\n" +
 		"    chezmoi apply\n" +
 		"    chezmoi apply --dry-run --verbose\n" +
+		"    chezmoi apply --interactive\n" +
 		"    chezmoi apply ~/.bashrc\n" +
 		"\n" +
 		"### `archive`\n" +
 		"\n" +
-		"Write a tar archive of the target state to stdout. This can be piped into `tar`\n" +
-		"to inspect the target state.\n" +
+		"Write an archive of the target state to stdout or a file. This can be piped into\n" +
+		"`tar` to inspect the target state, or used to seed containers and other\n" +
+		"machines.\n" +
+		"\n" +
+		"Scripts are archived as executable files. In tar archives, scripts are marked\n" +
+		"with the PAX record `CHEZMOI.type=script` and, depending on their attributes,\n" +
+		"the PAX records `CHEZMOI.once`, `CHEZMOI.onchange`, `CHEZMOI.before`, and\n" +
+		"`CHEZMOI.after` set to `true`. Files containing only a block are marked with\n" +
+		"`CHEZMOI.type=block`. Zip archives record the same PAX records in each file's\n" +
+		"comment, one `key=value` pair per line, and store symlinks as files containing\n" +
+		"the link target. Files with the `modify_` or `merge_` prefixes depend on the\n" +
+		"contents of the destination, so they are skipped with a warning.\n" +
+		"\n" +
+		"#### `-x`, `--exclude` *types*\n" +
+		"\n" +
+		"Exclude entries of type *types*. *types* is a comma-separated list of types of\n" +
+		"entry to exclude. Valid types are `all`, `dirs`, `files`, `scripts`, and\n" +
+		"`symlinks`, and `dirs`, `files`, and `symlinks` can be abbreviated to `d`, `f`,\n" +
+		"and `s` respectively. Blocks are archived as files, so they are included and\n" +
+		"excluded with `files`. Excluding directories does not exclude the entries in\n" +
+		"them.\n" +
+		"\n" +
+		"#### `--format` *format*\n" +
+		"\n" +
+		"Write the archive in format *format*, which must be one of `tar`, `tar.gz`, or\n" +
+		"`zip`. If not set, the format is chosen from the extension of the output file,\n" +
+		"defaulting to `tar`.\n" +
+		"\n" +
+		"#### `-i`, `--include` *types*\n" +
+		"\n" +
+		"Only include entries of type *types*. *types* is a comma-separated list of types\n" +
+		"of entry to include, in the same form as `--exclude`. By default, entries of all\n" +
+		"types are included.\n" +
+		"\n" +
+		"#### `-o`, `--output` *filename*\n" +
+		"\n" +
+		"Write the archive to *filename* instead of stdout.\n" +
 		"\n" +
 		"#### `archive` examples\n" +
 		"\n" +
 		"    chezmoi archive | tar tvf -\n" +
+		"    chezmoi archive --exclude=scripts --output=dotfiles.tar.gz\n" +
+		"    chezmoi archive --format=zip --include=files,symlinks > dotfiles.zip\n" +
 		"\n" +
 		"### `cat` targets\n" +
 		"\n" +
@@ -1924,13 +2281,24 @@ func init() {
 		"\n" +
 		"| Attribute    | Abbreviation |\n" +
 		"| ------------ | ------------ |\n" +
+		"| `after`      | *none*       |\n" +
+		"| `before`     | *none*       |\n" +
+		"| `create`     | *none*       |\n" +
 		"| `empty`      | `e`          |\n" +
 		"| `encrypted`  | *none*       |\n" +
 		"| `exact`      | *none*       |\n" +
 		"| `executable` | `x`          |\n" +
+		"| `link`       | *none*       |\n" +
+		"| `onchange`   | *none*       |\n" +
 		"| `private`    | `p`          |\n" +
 		"| `template`   | `t`          |\n" +
 		"\n" +
+		"Set the owner or group of a target with `owner=`*owner* or `group=`*group*,\n" +
+		"and remove them with `noowner` or `nogroup`. These are stored in the\n" +
+		"`.chezmoiattributes` file in the target's source directory, on a line of their\n" +
+		"own. Removing the owner or group of a target does\n" +
+		"not remove any owner or group that it inherits from its parent directories.\n" +
+		"\n" +
 		"Multiple attributes modifications may be specified by separating them with a\n" +
 		"comma (`,`).\n" +
 		"\n" +
@@ -1939,6 +2307,8 @@ func init() {
 		"    chezmoi chattr template ~/.bashrc\n" +
 		"    chezmoi chattr noempty ~/.profile\n" +
 		"    chezmoi chattr private,template ~/.netrc\n" +
+		"    chezmoi chattr before ~/install-packages.sh\n" +
+		"    chezmoi chattr owner=root,group=wheel /etc/sudoers\n" +
 		"\n" +
 		"### `completion` *shell*\n" +
 		"\n" +
@@ -1956,8 +2326,12 @@ func init() {
 		"\n" +
 		"### `data`\n" +
 		"\n" +
-		"Write the computed template data in JSON format to stdout. The `data` command\n" +
-		"accepts additional flags:\n" +
+		"Write the computed template data in JSON format to stdout. The computed\n" +
+		"template data includes the data from the config file and from any\n" +
+		"`.chezmoidata.<format>` files in the source state. The `data` command does not\n" +
+		"execute any templates, so it includes the data from `.chezmoidata.<format>`\n" +
+		"files in all directories, including ignored ones. The `data` command accepts\n" +
+		"additional flags:\n" +
 		"\n" +
 		"#### `-f`, `--format` *format*\n" +
 		"\n" +
@@ -2150,12 +2524,22 @@ func init() {
 		"exactly match the contents of a downloaded archive. You will generally always\n" +
 		"want to set the `--destination`, `--exact`, and `--remove-destination` flags.\n" +
 		"\n" +
-		"The only supported archive format is `.tar.gz`.\n" +
+		"The archive is read from *filename*, or stdin if no *filename* is given.\n" +
+		"Uncompressed, gzip-compressed, and bzip2-compressed tar archives and zip\n" +
+		"archives are supported, and the format is detected from the contents of the\n" +
+		"archive. Missing parent directories are created, hard links are imported as\n" +
+		"copies of the files that they link to, and directories that are empty after\n" +
+		"importing get a `.keep` file. Scripts and blocks in archives written by `chezmoi\n" +
+		"archive` are imported as scripts and blocks with their original attributes.\n" +
 		"\n" +
 		"#### `--destination` *directory*\n" +
 		"\n" +
 		"Set the destination (in the source state) where the archive will be imported.\n" +
 		"\n" +
+		"#### `--encrypt`\n" +
+		"\n" +
+		"Encrypt imported files and scripts.\n" +
+		"\n" +
 		"#### `-x`, `--exact`\n" +
 		"\n" +
 		"Set the `exact` attribute on all imported directories.\n" +
@@ -2168,11 +2552,35 @@ func init() {
 		"\n" +
 		"Strip *n* leading components from paths.\n" +
 		"\n" +
+		"#### `-T`, `--template`\n" +
+		"\n" +
+		"Set the `template` attribute on all imported files, scripts, and blocks.\n" +
+		"\n" +
 		"#### `import` examples\n" +
 		"\n" +
 		"    curl -s -L -o oh-my-zsh-master.tar.gz https://github.com/robbyrussell/oh-my-zsh/archive/master.tar.gz\n" +
 		"    chezmoi import --strip-components 1 --destination ~/.oh-my-zsh oh-my-zsh-master.tar.gz\n" +
 		"\n" +
+		"### `lint`\n" +
+		"\n" +
+		"Check the source state for problems without modifying anything. `lint` reports\n" +
+		"templates, including those in `.chezmoitemplates`, that cannot be parsed,\n" +
+		"source names whose prefixes are in the wrong order, files and directories that\n" +
+		"are ignored because their names begin with `.`, patterns in `.chezmoiignore`\n" +
+		"that do not match any target, files with the `encrypted_` prefix that are not\n" +
+		"encrypted, and `.chezmoiversion` files that do not contain a valid version.\n" +
+		"`lint` exits with a non-zero status if any problems are found.\n" +
+		"\n" +
+		"#### `-f`, `--format` *format*\n" +
+		"\n" +
+		"Print problems in the given format. The accepted formats are `text` (one\n" +
+		"problem per line) and `json` (JSON).\n" +
+		"\n" +
+		"#### `lint` examples\n" +
+		"\n" +
+		"    chezmoi lint\n" +
+		"    chezmoi lint --format=json\n" +
+		"\n" +
 		"### `manage` *targets*\n" +
 		"\n" +
 		"`manage` is an alias for `add` for symmetry with `unmanage`.\n" +
@@ -2231,6 +2639,21 @@ func init() {
 		"\n" +
 		"Remove without prompting.\n" +
 		"\n" +
+		"### `restore-backup` [*backup*]\n" +
+		"\n" +
+		"Restore *backup*, a backup made with `--backup`, returning all of the targets\n" +
+		"that it contains to their original contents, permissions, owner, and group.\n" +
+		"Directories containing the targets are created if they do not exist. Files and\n" +
+		"symlinks created since the backup was made are not removed. *backup* is either\n" +
+		"the name of a backup or the path to a backup directory. If no *backup* is given,\n" +
+		"list the names of the available backups, oldest first.\n" +
+		"\n" +
+		"#### `restore-backup` examples\n" +
+		"\n" +
+		"    chezmoi apply --backup\n" +
+		"    chezmoi restore-backup\n" +
+		"    chezmoi restore-backup 20201016T150405Z\n" +
+		"\n" +
 		"### `rm` *targets*\n" +
 		"\n" +
 		"`rm` is an alias for `remove`.\n" +
@@ -2281,6 +2704,36 @@ func init() {
 		"    chezmoi source-path\n" +
 		"    chezmoi source-path ~/.bashrc\n" +
 		"\n" +
+		"### `status` [*targets*]\n" +
+		"\n" +
+		"Print the status of each of *targets* that would be changed by `chezmoi apply`,\n" +
+		"one per line, without changing anything. If no targets are specified then the\n" +
+		"status of all targets is printed. Each line contains a two-letter code followed\n" +
+		"by the target's path. The first letter describes changes to the target's\n" +
+		"existence, type, or contents and the second letter describes changes to its\n" +
+		"mode, owner, or group:\n" +
+		"\n" +
+		"| Code | Meaning                                           |\n" +
+		"| ---- | ------------------------------------------------- |\n" +
+		"| `A ` | The target will be added                          |\n" +
+		"| `M ` | The target's contents will be modified            |\n" +
+		"| ` M` | The target's mode, owner, or group will change    |\n" +
+		"| `MM` | The target's contents and mode will change        |\n" +
+		"| `D ` | The target will be removed                        |\n" +
+		"| `T ` | The target will be replaced with a different type |\n" +
+		"| `R ` | The script will be run                            |\n" +
+		"\n" +
+		"#### `-f`, `--format` *format*\n" +
+		"\n" +
+		"Print statuses in the given format. The accepted formats are `text` (one target\n" +
+		"per line) and `json` (JSON).\n" +
+		"\n" +
+		"#### `status` examples\n" +
+		"\n" +
+		"    chezmoi status\n" +
+		"    chezmoi status ~/.bashrc\n" +
+		"    chezmoi status --format=json\n" +
+		"\n" +
 		"### `unmanage` *targets*\n" +
 		"\n" +
 		"`unmanage` is an alias for `forget` for symmetry with `manage`.\n" +
@@ -2295,7 +2748,17 @@ func init() {
 		"\n" +
 		"### `update`\n" +
 		"\n" +
-		"Pull changes from the source VCS and apply any changes.\n" +
+		"Pull changes from the source VCS and apply any changes. Files that have been\n" +
+		"changed since chezmoi last wrote them are handled as by `chezmoi apply`.\n" +
+		"\n" +
+		"#### `--force`\n" +
+		"\n" +
+		"Overwrite files that have been changed since chezmoi last wrote them.\n" +
+		"\n" +
+		"#### `--merge`\n" +
+		"\n" +
+		"Run `chezmoi merge` on files that have been changed since chezmoi last wrote\n" +
+		"them, instead of skipping them.\n" +
 		"\n" +
 		"#### `update` examples\n" +
 		"\n" +
@@ -2327,7 +2790,7 @@ func init() {
 		"\n" +
 		"Verify that all *targets* match their target state. chezmoi exits with code 0\n" +
 		"(success) if all targets match their target state, or 1 (failure) otherwise. If\n" +
-		"no targets are specified then all targets are checked.\n" +
+		"no targets are specified then all targets are checked. Scripts are not checked.\n" +
 		"\n" +
 		"#### `verify` examples\n" +
 		"\n" +
@@ -2379,6 +2842,30 @@ func init() {
 		"For a full list of options, see\n" +
 		"[`Template.Option`](https://pkg.go.dev/text/template?tab=doc#Template.Option).\n" +
 		"\n" +
+		"The delimiters and options of a single template can be set with a directive\n" +
+		"line containing `chezmoi:template:` followed by space-separated *key*=*value*\n" +
+		"pairs. Directive lines are typically written as comments and are removed from\n" +
+		"the template before it is executed. Other text on a directive line, such as\n" +
+		"comment delimiters, is ignored. Directive lines must appear at the start of the\n" +
+		"template, before the first line that is not blank, a comment starting with\n" +
+		"`#`, `--`, `//`, `;`, or `<!--`, or another directive line, so that `chezmoi:template:` can appear later in the template's\n" +
+		"contents. Values may be quoted with double quotes. The\n" +
+		"supported keys are:\n" +
+		"\n" +
+		"| Key               | Value                                                     |\n" +
+		"| ----------------- | --------------------------------------------------------- |\n" +
+		"| `left-delimiter`  | Left action delimiter, default `{{`                       |\n" +
+		"| `right-delimiter` | Right action delimiter, default `}}`                      |\n" +
+		"| `missingkey`      | One of `default`, `invalid`, `zero`, or `error`           |\n" +
+		"\n" +
+		"For example, to template a file that already uses `{{` and `}}`:\n" +
+		"\n" +
+		"    # chezmoi:template:left-delimiter=\"[[\" right-delimiter=\"]]\"\n" +
+		"    name: {{ .Values.name }}\n" +
+		"    user: [[ .chezmoi.username ]]\n" +
+		"\n" +
+		"Directive lines are also recognized in `.chezmoitemplates`.\n" +
+		"\n" +
 		"## Template variables\n" +
 		"\n" +
 		"chezmoi provides the following automatically populated variables:\n" +
@@ -2420,6 +2907,17 @@ func init() {
 		"    username = {{ (bitwarden \"item\" \"example.com\").login.username }}\n" +
 		"    password = {{ (bitwarden \"item\" \"example.com\").login.password }}\n" +
 		"\n" +
+		"### `decrypt` *path*\n" +
+		"\n" +
+		"`decrypt` returns the decrypted contents of the file at *path*, relative to the\n" +
+		"source directory, using the same `gpg` configuration as files with the\n" +
+		"`encrypted_` prefix. *path* must not be outside the source directory. Errors\n" +
+		"are reported with the template's name and line number.\n" +
+		"\n" +
+		"#### `decrypt` examples\n" +
+		"\n" +
+		"    {{ decrypt \"private_dot_ssh/encrypted_private_id_rsa\" }}\n" +
+		"\n" +
 		"### `gopass` *gopass-name*\n" +
 		"\n" +
 		"`gopass` returns passwords stored in [gopass](https://www.gopass.pw/) using the\n" +
@@ -2432,6 +2930,41 @@ func init() {
 		"\n" +
 		"    {{ gopass \"<pass-name>\" }}\n" +
 		"\n" +
+		"### `include` *path*\n" +
+		"\n" +
+		"`include` returns the literal contents of the file at *path*, relative to the\n" +
+		"source directory. *path* must not be outside the source directory. Unlike\n" +
+		"`includeTemplate`, the contents are not executed as a template.\n" +
+		"\n" +
+		"#### `include` examples\n" +
+		"\n" +
+		"    {{ include \"dot_bashrc_common\" }}\n" +
+		"\n" +
+		"### `includeTemplate` *name* [*args*]\n" +
+		"\n" +
+		"`includeTemplate` returns the result of executing the template *name* from\n" +
+		"`.chezmoitemplates`. The template is executed with the template data, with the\n" +
+		"keys of each of *args*, which are typically created with `dict`, replacing the\n" +
+		"keys of the template data. This allows a single template to be used to generate\n" +
+		"several variants of the same text. A template that includes itself, directly or\n" +
+		"through other templates, is an error.\n" +
+		"\n" +
+		"#### `includeTemplate` examples\n" +
+		"\n" +
+		"Given:\n" +
+		"\n" +
+		"    .chezmoitemplates/alias\n" +
+		"    alias {{ .name }}='{{ .command }}'\n" +
+		"\n" +
+		"    dot_bashrc.tmpl\n" +
+		"    {{ includeTemplate \"alias\" (dict \"name\" \"ll\" \"command\" \"ls -l\") }}\n" +
+		"    {{ includeTemplate \"alias\" (dict \"name\" \"la\" \"command\" \"ls -a\") }}\n" +
+		"\n" +
+		"The target state of `.bashrc` will be:\n" +
+		"\n" +
+		"    alias ll='ls -l'\n" +
+		"    alias la='ls -a'\n" +
+		"\n" +
 		"### `keepassxc` *entry*\n" +
 		"\n" +
 		"`keepassxc` returns structured data retrieved from a\n" +
@@ -2529,6 +3062,18 @@ func init() {
 		"\n" +
 		"    {{- onepasswordDocument \"<uuid>\" -}}\n" +
 		"\n" +
+		"### `output` *name* [*args*]\n" +
+		"\n" +
+		"`output` returns the standard output of running the command *name* with\n" +
+		"arguments *args*. The command is run even with `--dry-run`, so it should not\n" +
+		"have side effects. If the command exits with a non-zero status then template\n" +
+		"execution fails with an error that includes the template's name and line\n" +
+		"number.\n" +
+		"\n" +
+		"#### `output` examples\n" +
+		"\n" +
+		"    current-context: {{ output \"kubectl\" \"config\" \"current-context\" | trim }}\n" +
+		"\n" +
 		"### `pass` *pass-name*\n" +
 		"\n" +
 		"`pass` returns passwords stored in [pass](https://www.passwordstore.org/) using\n" +
@@ -54,7 +54,7 @@ func (c *Config) runDumpCmd(cmd *cobra.Command, args []string) error {
 		}
 		var concreteValues []interface{}
 		for _, entry := range entries {
-			entryConcreteValue, err := entry.ConcreteValue(ts.TargetIgnore.Match, ts.SourceDir, os.FileMode(c.Umask), c.dump.recursive)
+			entryConcreteValue, err := entry.ConcreteValue(ts.TargetIgnore.Match, ts.SourcePaths, os.FileMode(c.Umask), c.dump.recursive)
 			if err != nil {
 				return err
 			}
@@ -42,6 +42,10 @@ func TestDumpCmd(t *testing.T) {
 					"type":       "file",
 					"sourcePath": filepath.Join("/", "home", "user", ".local", "share", "chezmoi", "dir", "file"),
 					"targetPath": filepath.Join("dir", "file"),
+					"create":     false,
+					"modify":     false,
+					"merge":      false,
+					"link":       false,
 					"empty":      false,
 					"encrypted":  false,
 					"perm":       float64(0644),
@@ -60,3 +64,51 @@ func TestDumpCmd(t *testing.T) {
 	}
 	assert.Equal(t, expected, actual)
 }
+
+func TestDumpCmdBaseSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi-base": map[string]interface{}{
+			".chezmoidata.json": `{"email":"john.smith@company.com","name":"John Smith"}`,
+			"dot_gitconfig":     "[user]\n",
+		},
+		"/home/user/.local/share/chezmoi": map[string]interface{}{
+			".chezmoidata.json":  `{"email":"john@home.org"}`,
+			"dot_gitconfig.tmpl": "{{ .name }} <{{ .email }}>",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	stdout := &bytes.Buffer{}
+	c := newTestConfig(
+		fs,
+		withBaseSourceDirs([]string{"/home/user/.local/share/chezmoi-base"}),
+		withDumpCmdConfig(dumpCmdConfig{
+			format:    "json",
+			recursive: true,
+		}),
+		withStdout(stdout),
+	)
+	assert.NoError(t, c.runDumpCmd(nil, nil))
+	var actual interface{}
+	assert.NoError(t, json.NewDecoder(stdout).Decode(&actual))
+	expected := []interface{}{
+		map[string]interface{}{
+			"type":       "file",
+			"sourcePath": filepath.Join("/", "home", "user", ".local", "share", "chezmoi", "dot_gitconfig.tmpl"),
+			"targetPath": ".gitconfig",
+			"create":     false,
+			"modify":     false,
+			"merge":      false,
+			"link":       false,
+			"empty":      false,
+			"encrypted":  false,
+			"perm":       float64(0644),
+			"template":   true,
+			"contents":   "John Smith <john@home.org>",
+			"overrides": []interface{}{
+				filepath.Join("/", "home", "user", ".local", "share", "chezmoi-base", "dot_gitconfig"),
+			},
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
@@ -41,7 +41,8 @@ func init() {
 
 type encryptedFile struct {
 	index          int
-	file           *chezmoi.File
+	entry          chezmoi.Entry
+	contents       func() ([]byte, error)
 	ciphertextPath string
 	plaintextPath  string
 }
@@ -77,23 +78,24 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Build a list of source file names to pass to the editor. Check that each
-	// is either a file or a symlink. If the entry is an encrypted file then
-	// remember it.
+	// is either a block, a file, a script, or a symlink. If the entry is an
+	// encrypted file or script then remember it.
 	argv := make([]string, len(entries))
 	var encryptedFiles []encryptedFile
 	for i, entry := range entries {
-		argv[i] = filepath.Join(c.SourceDir, entry.SourceName())
-		if file, ok := entry.(*chezmoi.File); ok {
-			if file.Encrypted {
-				ef := encryptedFile{
-					index:          i,
-					file:           file,
-					ciphertextPath: argv[i],
-				}
-				encryptedFiles = append(encryptedFiles, ef)
-			}
-		} else if _, ok := entry.(*chezmoi.Symlink); !ok {
-			return fmt.Errorf("%s: not a file or symlink", args[i])
+		argv[i] = ts.SourcePath(entry)
+		switch entry.(type) {
+		case *chezmoi.Block, *chezmoi.File, *chezmoi.Script, *chezmoi.Symlink:
+		default:
+			return fmt.Errorf("%s: not a block, file, script, or symlink", args[i])
+		}
+		if contents := encryptedContents(entry); contents != nil {
+			encryptedFiles = append(encryptedFiles, encryptedFile{
+				index:          i,
+				entry:          entry,
+				contents:       contents,
+				ciphertextPath: argv[i],
+			})
 		}
 	}
 
@@ -108,11 +110,11 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		defer os.RemoveAll(tempDir)
 		for i := range encryptedFiles {
 			ef := &encryptedFiles[i]
-			plaintext, err := ef.file.Contents()
+			plaintext, err := ef.contents()
 			if err != nil {
 				return err
 			}
-			ef.plaintextPath = filepath.Join(tempDir, ef.file.SourceName())
+			ef.plaintextPath = filepath.Join(tempDir, ef.entry.SourceName())
 			if err := os.MkdirAll(filepath.Dir(ef.plaintextPath), 0700&^os.FileMode(c.Umask)); err != nil {
 				return err
 			}
@@ -137,7 +139,11 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		if err := renameio.WriteFile(ef.ciphertextPath, ciphertext, 0644); err != nil {
+		oldCiphertext, err := c.fs.ReadFile(ef.ciphertextPath)
+		if err != nil {
+			return err
+		}
+		if err := c.mutator.WriteFile(ef.ciphertextPath, ciphertext, 0644, oldCiphertext); err != nil {
 			return err
 		}
 	}
@@ -153,23 +159,36 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	persistentState, err := c.getPersistentState(nil)
+	if err != nil {
+		return err
+	}
+	defer persistentState.Close()
+
 	readOnlyFS := vfs.NewReadOnlyFS(c.fs)
 	applyOptions := chezmoi.ApplyOptions{
 		DestDir:           ts.DestDir,
 		DryRun:            c.DryRun,
+		FileStateBucket:   c.fileStateBucket,
 		Ignore:            ts.TargetIgnore.Match,
+		PersistentState:   persistentState,
 		ScriptStateBucket: c.scriptStateBucket,
+		SourcePath:        ts.SourcePath,
 		Stdout:            c.Stdout,
 		Umask:             ts.Umask,
 		Verbose:           c.Verbose,
 	}
+	// Check whether each entry would change in a dry run, so that the state
+	// of scripts is not recorded before they are run.
+	dryRunApplyOptions := applyOptions
+	dryRunApplyOptions.DryRun = true
 	for i, entry := range entries {
 		anyMutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
 		var mutator chezmoi.Mutator = anyMutator
 		if c.edit.diff {
 			mutator = chezmoi.NewVerboseMutator(c.Stdout, mutator, c.colored, c.maxDiffDataSize)
 		}
-		if err := entry.Apply(readOnlyFS, mutator, c.Follow, &applyOptions); err != nil {
+		if err := entry.Apply(readOnlyFS, mutator, c.Follow, &dryRunApplyOptions); err != nil {
 			return err
 		}
 		if c.edit.apply && anyMutator.Mutated() {
@@ -195,3 +214,19 @@ func (c *Config) runEditCmd(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// encryptedContents returns the function that returns the plaintext contents of
+// entry if entry is an encrypted file or script, and nil otherwise.
+func encryptedContents(entry chezmoi.Entry) func() ([]byte, error) {
+	switch entry := entry.(type) {
+	case *chezmoi.File:
+		if entry.Encrypted {
+			return entry.Contents
+		}
+	case *chezmoi.Script:
+		if entry.Encrypted {
+			return entry.Contents
+		}
+	}
+	return nil
+}
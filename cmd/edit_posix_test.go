@@ -0,0 +1,112 @@
+// +build !windows
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestEditApplyRunOnce(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+	tempFile := filepath.Join(tempDir, "foo")
+
+	fs, cleanup, err := vfst.NewTestFS(
+		[]interface{}{
+			getRunOnceFiles(),
+		},
+	)
+	require.NoError(t, err)
+	defer cleanup()
+
+	visual, ok := os.LookupEnv("VISUAL")
+	require.NoError(t, os.Setenv("VISUAL", "true"))
+	defer func() {
+		if ok {
+			_ = os.Setenv("VISUAL", visual)
+		} else {
+			_ = os.Unsetenv("VISUAL")
+		}
+	}()
+
+	c := newTestConfig(
+		fs,
+		withDestDir("/"),
+		withData(map[string]interface{}{
+			"TempFile": tempFile,
+		}),
+		withEditCmdConfig(editCmdConfig{
+			apply: true,
+		}),
+	)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.runEditCmd(nil, []string{"/foo"}))
+		actualData, err := ioutil.ReadFile(tempFile)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("bar\n"), actualData)
+	}
+}
+
+func TestEditEncrypted(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+	editor := filepath.Join(tempDir, "editor")
+	require.NoError(t, ioutil.WriteFile(editor, []byte("#!/bin/sh\necho \"# edited\" >>\"$1\"\n"), 0o700))
+
+	visual, ok := os.LookupEnv("VISUAL")
+	require.NoError(t, os.Setenv("VISUAL", editor))
+	defer func() {
+		if ok {
+			_ = os.Setenv("VISUAL", visual)
+		} else {
+			_ = os.Unsetenv("VISUAL")
+		}
+	}()
+
+	for _, tc := range []struct {
+		name       string
+		sourceName string
+		targetPath string
+	}{
+		{
+			name:       "file",
+			sourceName: "encrypted_dot_netrc",
+			targetPath: "/home/user/.netrc",
+		},
+		{
+			name:       "script",
+			sourceName: "run_encrypted_install",
+			targetPath: "/home/user/install",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi/" + tc.sourceName: "-----BEGIN PGP MESSAGE-----\n# contents\n",
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			c := newTestConfig(fs)
+			c.GPG.Command = newTestGPGCommand(t, tempDir)
+			require.NoError(t, c.runEditCmd(nil, []string{tc.targetPath}))
+			vfst.RunTests(t, fs, "",
+				vfst.TestPath("/home/user/.local/share/chezmoi/"+tc.sourceName,
+					vfst.TestContentsString("-----BEGIN PGP MESSAGE-----\n# contents\n# edited\n"),
+				),
+			)
+		})
+	}
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"path/filepath"
-
 	"github.com/spf13/cobra"
 )
 
@@ -34,7 +32,7 @@ func (c *Config) runForgetCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	for _, entry := range entries {
-		if err := c.mutator.RemoveAll(filepath.Join(c.SourceDir, entry.SourceName())); err != nil {
+		if err := c.mutator.RemoveAll(ts.SourcePath(entry)); err != nil {
 			return err
 		}
 	}
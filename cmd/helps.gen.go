@@ -55,19 +55,81 @@ var helps = map[string]help{
 		long: "" +
 			"Description:\n" +
 			"  Ensure that *targets* are in the target state, updating them if necessary. If\n" +
-			"  no targets are specified, the state of all targets are ensured.",
+			"  no targets are specified, the state of all targets are ensured.\n" +
+			"\n" +
+			"  chezmoi records a hash of every file that it writes. If a file has been\n" +
+			"  changed since chezmoi last wrote it, for example by editing it by hand, and\n" +
+			"  does not match the target state, then the file is skipped with a warning.\n" +
+			"  Files with the `modify_` or `merge_` prefixes are never skipped.\n" +
+			"\n" +
+			"  `--force`\n" +
+			"\n" +
+			"  Overwrite files that have been changed since chezmoi last wrote them.\n" +
+			"\n" +
+			"  `-i`, `--interactive`\n" +
+			"\n" +
+			"  For each target that would change, print its diff in the format set by\n" +
+			"  `diff.format` and prompt whether to apply it (`y`), skip it (`n`), quit (`q`),\n" +
+			"  or apply it and all remaining targets without prompting (`a`). The contents\n" +
+			"  of scripts are printed before prompting whether to run them. Skipping a\n" +
+			"  directory only skips changes to the directory itself. Everything in it is\n" +
+			"  still prompted for, unless the directory does not exist.\n" +
+			"\n" +
+			"  `--merge`\n" +
+			"\n" +
+			"  Run `chezmoi merge` on files that have been changed since chezmoi last wrote\n" +
+			"  them, instead of skipping them.",
 		example: "" +
 			"  chezmoi apply\n" +
 			"  chezmoi apply --dry-run --verbose\n" +
+			"  chezmoi apply --interactive\n" +
 			"  chezmoi apply ~/.bashrc",
 	},
 	"archive": {
 		long: "" +
 			"Description:\n" +
-			"  Write a tar archive of the target state to stdout. This can be piped into\n" +
-			"  `tar` to inspect the target state.",
+			"  Write an archive of the target state to stdout or a file. This can be piped\n" +
+			"  into `tar` to inspect the target state, or used to seed containers and other\n" +
+			"  machines.\n" +
+			"\n" +
+			"  Scripts are archived as executable files. In tar archives, scripts are marked\n" +
+			"  with the PAX record `CHEZMOI.type=script` and, depending on their attributes,\n" +
+			"  the PAX records `CHEZMOI.once`, `CHEZMOI.onchange`, `CHEZMOI.before`, and\n" +
+			"  `CHEZMOI.after` set to `true`. Files containing only a block are marked with\n" +
+			"  `CHEZMOI.type=block`. Zip archives record the same PAX records in each\n" +
+			"  file's comment, one `key=value` pair per line, and store symlinks as files\n" +
+			"  containing the link target. Files with the `modify_` or `merge_` prefixes\n" +
+			"  depend on the contents of the destination, so they are skipped with a\n" +
+			"  warning.\n" +
+			"\n" +
+			"  `-x`, `--exclude` *types*\n" +
+			"\n" +
+			"  Exclude entries of type *types*. *types* is a comma-separated list of types\n" +
+			"  of entry to exclude. Valid types are `all`, `dirs`, `files`, `scripts`, and\n" +
+			"  `symlinks`, and `dirs`, `files`, and `symlinks` can be abbreviated to `d`,\n" +
+			"  `f`, and `s` respectively. Blocks are archived as files, so they are\n" +
+			"  included and excluded with `files`. Excluding directories does not exclude\n" +
+			"  the entries in them.\n" +
+			"\n" +
+			"  `--format` *format*\n" +
+			"\n" +
+			"  Write the archive in format *format*, which must be one of `tar`, `tar.gz`,\n" +
+			"  or `zip`. If not set, the format is chosen from the extension of the output\n" +
+			"  file, defaulting to `tar`.\n" +
+			"\n" +
+			"  `-i`, `--include` *types*\n" +
+			"\n" +
+			"  Only include entries of type *types*. *types* is a comma-separated list of\n" +
+			"  types of entry to include, in the same form as `--exclude`. By default,\n" +
+			"  entries of all types are included.\n" +
+			"\n" +
+			"  `-o`, `--output` *filename*\n" +
+			"\n" +
+			"  Write the archive to *filename* instead of stdout.",
 		example: "" +
-			"  chezmoi archive | tar tvf -",
+			"  chezmoi archive | tar tvf -\n" +
+			"  chezmoi archive --exclude=scripts --output=dotfiles.tar.gz\n" +
+			"  chezmoi archive --format=zip --include=files,symlinks > dotfiles.zip",
 	},
 	"cat": {
 		long: "" +
@@ -99,19 +161,32 @@ var helps = map[string]help{
 			"\n" +
 			"    ATTRIBUTE  | ABBREVIATION\n" +
 			"  -------------+---------------\n" +
+			"    after      | none\n" +
+			"    before     | none\n" +
+			"    create     | none\n" +
 			"    empty      | e\n" +
 			"    encrypted  | none\n" +
 			"    exact      | none\n" +
 			"    executable | x\n" +
+			"    link       | none\n" +
+			"    onchange   | none\n" +
 			"    private    | p\n" +
 			"    template   | t\n" +
 			"\n" +
+			"  Set the owner or group of a target with `owner=`*owner* or `group=`*group*,\n" +
+			"  and remove them with `noowner` or `nogroup`. These are stored in the\n" +
+			"  `.chezmoiattributes` file in the target's source directory, on a line of\n" +
+			"  their own. Removing the owner or group of a target does not remove any owner\n" +
+			"  or group that it inherits from its parent directories.\n" +
+			"\n" +
 			"  Multiple attributes modifications may be specified by separating them with a\n" +
 			"  comma (`,`).",
 		example: "" +
 			"  chezmoi chattr template ~/.bashrc\n" +
 			"  chezmoi chattr noempty ~/.profile\n" +
-			"  chezmoi chattr private,template ~/.netrc",
+			"  chezmoi chattr private,template ~/.netrc\n" +
+			"  chezmoi chattr before ~/install-packages.sh\n" +
+			"  chezmoi chattr owner=root,group=wheel /etc/sudoers",
 	},
 	"completion": {
 		long: "" +
@@ -129,8 +204,12 @@ var helps = map[string]help{
 	"data": {
 		long: "" +
 			"Description:\n" +
-			"  Write the computed template data in JSON format to stdout. The `data` command\n" +
-			"  accepts additional flags:\n" +
+			"  Write the computed template data in JSON format to stdout. The computed\n" +
+			"  template data includes the data from the config file and from any\n" +
+			"  `.chezmoidata.<format>` files in the source state. The `data` command does not\n" +
+			"  execute any templates, so it includes the data from `.chezmoidata.<format>`\n" +
+			"  files in all directories, including ignored ones. The `data` command accepts\n" +
+			"  additional flags:\n" +
 			"\n" +
 			"  `-f`, `--format` *format*\n" +
 			"\n" +
@@ -304,12 +383,23 @@ var helps = map[string]help{
 			"  exactly match the contents of a downloaded archive. You will generally always\n" +
 			"  want to set the `--destination`, `--exact`, and `--remove-destination` flags.\n" +
 			"\n" +
-			"  The only supported archive format is `.tar.gz`.\n" +
+			"  The archive is read from *filename*, or stdin if no *filename* is given.\n" +
+			"  Uncompressed, gzip-compressed, and bzip2-compressed tar archives and zip\n" +
+			"  archives are supported, and the format is detected from the contents of the\n" +
+			"  archive. Missing parent directories are created, hard links are imported as\n" +
+			"  copies of the files that they link to, and directories that are empty after\n" +
+			"  importing get a `.keep` file. Scripts and blocks in archives written by\n" +
+			"  `chezmoi archive` are imported as scripts and blocks with their original\n" +
+			"  attributes.\n" +
 			"\n" +
 			"  `--destination` *directory*\n" +
 			"\n" +
 			"  Set the destination (in the source state) where the archive will be imported.\n" +
 			"\n" +
+			"  `--encrypt`\n" +
+			"\n" +
+			"  Encrypt imported files and scripts.\n" +
+			"\n" +
 			"  `-x`, `--exact`\n" +
 			"\n" +
 			"  Set the `exact` attribute on all imported directories.\n" +
@@ -320,7 +410,11 @@ var helps = map[string]help{
 			"\n" +
 			"  `--strip-components` *n*\n" +
 			"\n" +
-			"  Strip *n* leading components from paths.",
+			"  Strip *n* leading components from paths.\n" +
+			"\n" +
+			"  `-T`, `--template`\n" +
+			"\n" +
+			"  Set the `template` attribute on all imported files, scripts, and blocks.",
 		example: "" +
 			"  curl -s -L -o oh-my-zsh-master.tar.gz https://github.com/robbyrussell/oh-my-\n" +
 			"zsh/archive/master.tar.gz\n" +
@@ -340,6 +434,26 @@ var helps = map[string]help{
 			"  chezmoi init https://github.com/user/dotfiles.git\n" +
 			"  chezmoi init https://github.com/user/dotfiles.git --apply",
 	},
+	"lint": {
+		long: "" +
+			"Description:\n" +
+			"  Check the source state for problems without modifying anything. `lint`\n" +
+			"  reports templates, including those in `.chezmoitemplates`, that cannot be\n" +
+			"  parsed, source names whose prefixes are in the wrong order, files and\n" +
+			"  directories that are ignored because their names begin with `.`, patterns in\n" +
+			"  `.chezmoiignore` that do not match any target, files with the `encrypted_`\n" +
+			"  prefix that are not encrypted, and `.chezmoiversion` files that do not contain\n" +
+			"  a valid version. `lint` exits with a non-zero status if any problems are\n" +
+			"  found.\n" +
+			"\n" +
+			"  `-f`, `--format` *format*\n" +
+			"\n" +
+			"  Print problems in the given format. The accepted formats are `text` (one\n" +
+			"  problem per line) and `json` (JSON).",
+		example: "" +
+			"  chezmoi lint\n" +
+			"  chezmoi lint --format=json",
+	},
 	"manage": {
 		long: "" +
 			"Description:\n" +
@@ -398,6 +512,20 @@ var helps = map[string]help{
 			"\n" +
 			"  Remove without prompting.",
 	},
+	"restore-backup": {
+		long: "" +
+			"Description:\n" +
+			"  Restore *backup*, a backup made with `--backup`, returning all of the targets\n" +
+			"  that it contains to their original contents, permissions, owner, and group.\n" +
+			"  Directories containing the targets are created if they do not exist. Files and\n" +
+			"  symlinks created since the backup was made are not removed. *backup* is either\n" +
+			"  the name of a backup or the path to a backup directory. If no *backup* is\n" +
+			"  given, list the names of the available backups, oldest first.",
+		example: "" +
+			"  chezmoi apply --backup\n" +
+			"  chezmoi restore-backup\n" +
+			"  chezmoi restore-backup 20201016T150405Z",
+	},
 	"rm": {
 		long: "" +
 			"Description:\n" +
@@ -448,6 +576,35 @@ var helps = map[string]help{
 			"    chezmoi source-path\n" +
 			"    chezmoi source-path ~/.bashrc",
 	},
+	"status": {
+		long: "" +
+			"Description:\n" +
+			"  Print the status of each of *targets* that would be changed by `chezmoi\n" +
+			"  apply`, one per line, without changing anything. If no targets are specified\n" +
+			"  then the status of all targets is printed. Each line contains a two-letter\n" +
+			"  code followed by the target's path. The first letter describes changes to the\n" +
+			"  target's existence, type, or contents and the second letter describes changes\n" +
+			"  to its mode, owner, or group:\n" +
+			"\n" +
+			"    CODE | MEANING\n" +
+			"  -------+----------------------------------------------------\n" +
+			"    A    | The target will be added\n" +
+			"    M    | The target's contents will be modified\n" +
+			"     M   | The target's mode, owner, or group will change\n" +
+			"    MM   | The target's contents and mode will change\n" +
+			"    D    | The target will be removed\n" +
+			"    T    | The target will be replaced with a different type\n" +
+			"    R    | The script will be run\n" +
+			"\n" +
+			"  `-f`, `--format` *format*\n" +
+			"\n" +
+			"  Print statuses in the given format. The accepted formats are `text` (one\n" +
+			"  target per line) and `json` (JSON).",
+		example: "" +
+			"  chezmoi status\n" +
+			"  chezmoi status ~/.bashrc\n" +
+			"  chezmoi status --format=json",
+	},
 	"unmanage": {
 		long: "" +
 			"Description:\n" +
@@ -463,7 +620,17 @@ var helps = map[string]help{
 	"update": {
 		long: "" +
 			"Description:\n" +
-			"  Pull changes from the source VCS and apply any changes.",
+			"  Pull changes from the source VCS and apply any changes. Files that have been\n" +
+			"  changed since chezmoi last wrote them are handled as by `chezmoi apply`.\n" +
+			"\n" +
+			"  `--force`\n" +
+			"\n" +
+			"  Overwrite files that have been changed since chezmoi last wrote them.\n" +
+			"\n" +
+			"  `--merge`\n" +
+			"\n" +
+			"  Run `chezmoi merge` on files that have been changed since chezmoi last wrote\n" +
+			"  them, instead of skipping them.",
 		example: "" +
 			"  chezmoi update",
 	},
@@ -493,7 +660,8 @@ var helps = map[string]help{
 			"Description:\n" +
 			"  Verify that all *targets* match their target state. chezmoi exits with code 0\n" +
 			"  (success) if all targets match their target state, or 1 (failure) otherwise.\n" +
-			"  If no targets are specified then all targets are checked.",
+			"  If no targets are specified then all targets are checked. Scripts are not\n" +
+			"  checked.",
 		example: "" +
 			"  chezmoi verify\n" +
 			"  chezmoi verify ~/.bashrc",
@@ -2,13 +2,14 @@ package cmd
 
 import (
 	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"compress/gzip"
-	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/twpayne/chezmoi/internal/chezmoi"
@@ -17,7 +18,7 @@ import (
 var _importCmd = &cobra.Command{
 	Use:     "import [filename]",
 	Args:    cobra.MaximumNArgs(1),
-	Short:   "Import a tar archive into the source state",
+	Short:   "Import an archive into the source state",
 	Long:    mustGetLongHelp("import"),
 	Example: getExample("import"),
 	PreRunE: config.ensureNoError,
@@ -34,11 +35,13 @@ func init() {
 
 	persistentFlags := _importCmd.PersistentFlags()
 	persistentFlags.StringVarP(&config._import.importTAROptions.DestinationDir, "destination", "d", "", "destination prefix")
+	persistentFlags.BoolVar(&config._import.importTAROptions.Encrypt, "encrypt", false, "encrypt files and scripts")
 	persistentFlags.BoolVarP(&config._import.importTAROptions.Exact, "exact", "x", false, "import directories exactly")
 	persistentFlags.IntVar(&config._import.importTAROptions.StripComponents, "strip-components", 0, "strip components")
 	persistentFlags.BoolVarP(&config._import.removeDestination, "remove-destination", "r", false, "remove destination before import")
+	persistentFlags.BoolVarP(&config._import.importTAROptions.Template, "template", "T", false, "import files, scripts, and blocks as templates")
 
-	panicOnError(_importCmd.MarkZshCompPositionalArgumentFile(1, "*.tar", "*.tar.bz2", "*.tar.gz", "*.tgz"))
+	panicOnError(_importCmd.MarkZshCompPositionalArgumentFile(1, "*.tar", "*.tar.bz2", "*.tar.gz", "*.tbz2", "*.tgz", "*.zip"))
 }
 
 func (c *Config) runImportCmd(cmd *cobra.Command, args []string) error {
@@ -50,32 +53,23 @@ func (c *Config) runImportCmd(cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
 		r = c.Stdin
 	} else {
-		arg := args[0]
-		f, err := c.fs.Open(arg)
+		f, err := c.fs.Open(args[0])
 		if err != nil {
 			return err
 		}
 		//nolint:gosec
 		defer f.Close()
-		switch {
-		case strings.HasSuffix(arg, ".tar.gz") || strings.HasSuffix(arg, ".tgz"):
-			r, err = gzip.NewReader(f)
-			if err != nil {
-				return err
-			}
-		case strings.HasSuffix(arg, ".tar.bz2"):
-			r = bzip2.NewReader(f)
-		case strings.HasSuffix(arg, ".tar"):
-			r = f
-		default:
-			return fmt.Errorf("%s: unknown format", arg)
-		}
+		r = f
+	}
+	ar, err := newArchiveReader(r)
+	if err != nil {
+		return err
 	}
 	if c._import.removeDestination {
 		entry, err := ts.Get(c.fs, c._import.importTAROptions.DestinationDir)
 		switch {
 		case err == nil:
-			if err := c.mutator.RemoveAll(filepath.Join(c.SourceDir, entry.SourceName())); err != nil {
+			if err := c.mutator.RemoveAll(ts.SourcePath(entry)); err != nil {
 				return err
 			}
 		case os.IsNotExist(err):
@@ -83,5 +77,39 @@ func (c *Config) runImportCmd(cmd *cobra.Command, args []string) error {
 			return err
 		}
 	}
-	return ts.ImportTAR(tar.NewReader(r), c._import.importTAROptions, c.mutator)
+	return ts.ImportTAR(ar, c._import.importTAROptions, c.mutator)
+}
+
+// newArchiveReader returns a chezmoi.ArchiveReader that reads the archive in
+// r. gzip- and bzip2-compressed tar archives and zip archives are detected by
+// their magic bytes, otherwise r is read as an uncompressed tar archive.
+func newArchiveReader(r io.Reader) (chezmoi.ArchiveReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return tar.NewReader(gr), nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return tar.NewReader(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		// zip archives must be read randomly, so read the whole archive.
+		data, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		return chezmoi.NewZipReader(zr), nil
+	default:
+		return tar.NewReader(br), nil
+	}
 }
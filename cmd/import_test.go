@@ -3,10 +3,12 @@ package cmd
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 	"github.com/twpayne/go-vfs/vfst"
 )
 
@@ -59,3 +61,164 @@ func TestImportCmd(t *testing.T) {
 		),
 	)
 }
+
+func TestImportCmdMissingParentsAndHardLinks(t *testing.T) {
+	b := &bytes.Buffer{}
+	gw := gzip.NewWriter(b)
+	w := tar.NewWriter(gw)
+	assert.NoError(t, w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "oh-my-zsh-master/plugins/file",
+		Size:     int64(len("contents")),
+		Mode:     0644,
+	}))
+	_, err := w.Write([]byte("contents"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     "oh-my-zsh-master/link",
+		Linkname: "oh-my-zsh-master/plugins/file",
+		Mode:     0644,
+	}))
+	assert.NoError(t, w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "oh-my-zsh-master/empty",
+		Mode:     0755,
+	}))
+	assert.NoError(t, w.Close())
+	assert.NoError(t, gw.Close())
+
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(
+		fs,
+		withStdin(b),
+	)
+	c._import.importTAROptions = chezmoi.ImportTAROptions{
+		DestinationDir:  "/home/user/.oh-my-zsh",
+		Exact:           true,
+		StripComponents: 1,
+		Template:        true,
+	}
+	assert.NoError(t, c.runImportCmd(nil, nil))
+
+	vfst.RunTests(t, fs, "test",
+		vfst.TestPath("/home/user/.local/share/chezmoi/exact_dot_oh-my-zsh/exact_plugins/file.tmpl",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("contents"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/exact_dot_oh-my-zsh/link.tmpl",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("contents"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/exact_dot_oh-my-zsh/exact_empty/.keep",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString(""),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/exact_dot_oh-my-zsh/exact_plugins/.keep",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestImportCmdOutsideTargetDir(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := tar.NewWriter(b)
+	assert.NoError(t, w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "dir/../../file",
+		Size:     int64(len("contents")),
+		Mode:     0644,
+	}))
+	_, err := w.Write([]byte("contents"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(
+		fs,
+		withStdin(b),
+	)
+	assert.Error(t, c.runImportCmd(nil, nil))
+
+	vfst.RunTests(t, fs, "test",
+		vfst.TestPath("/home/user/.local/share/chezmoi/file",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/file",
+			vfst.TestDoesNotExist,
+		),
+	)
+}
+
+func TestImportCmdArchiveRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+	}{
+		{format: "tar.gz"},
+		{format: "zip"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			archiveFS, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					"block_dot_profile":       "export FOO=bar\n",
+					"dir/file":                "contents",
+					"empty_dir":               &vfst.Dir{Perm: 0755},
+					"run_once_before_install": "#!/bin/sh\n",
+					"symlink_symlink":         "target",
+				},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			archive := &bytes.Buffer{}
+			c := newTestConfig(
+				archiveFS,
+				withStdout(archive),
+			)
+			c.archive.format = tc.format
+			require.NoError(t, c.runArchiveCmd(nil, nil))
+
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			c = newTestConfig(
+				fs,
+				withStdin(archive),
+			)
+			require.NoError(t, c.runImportCmd(nil, nil))
+
+			vfst.RunTests(t, fs, "",
+				vfst.TestPath("/home/user/.local/share/chezmoi/dir/file",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("contents"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/empty_dir/.keep",
+					vfst.TestModeIsRegular,
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/block_dot_profile",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("export FOO=bar\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/run_once_before_install",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("#!/bin/sh\n"),
+				),
+				vfst.TestPath("/home/user/.local/share/chezmoi/symlink_symlink",
+					vfst.TestModeIsRegular,
+					vfst.TestContentsString("target"),
+				),
+			)
+		})
+	}
+}
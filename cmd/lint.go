@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+type lintCmdConfig struct {
+	format string
+}
+
+var lintCmd = &cobra.Command{
+	Use:     "lint",
+	Args:    cobra.NoArgs,
+	Short:   "Check the source state for problems",
+	Long:    mustGetLongHelp("lint"),
+	Example: getExample("lint"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runLintCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	persistentFlags := lintCmd.PersistentFlags()
+	persistentFlags.StringVarP(&config.lint.format, "format", "f", "text", "format (text or JSON)")
+}
+
+func (c *Config) runLintCmd(cmd *cobra.Command, args []string) error {
+	ts, err := c.newTargetState()
+	if err != nil {
+		return err
+	}
+	problems, err := ts.Lint(vfs.NewReadOnlyFS(c.fs))
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(c.lint.format) {
+	case "json":
+		if problems == nil {
+			problems = []*chezmoi.LintProblem{}
+		}
+		if err := formatMap["json"](c.Stdout, problems); err != nil {
+			return err
+		}
+	case "text":
+		for _, problem := range problems {
+			if _, err := fmt.Fprintln(c.Stdout, problem); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unknown format", c.lint.format)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestLintCmd(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		root       interface{}
+		format     string
+		wantErr    string
+		wantStdout string
+	}{
+		{
+			name: "no_problems_text",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_bashrc": "",
+			},
+			format: "text",
+		},
+		{
+			name: "no_problems_json",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_bashrc": "",
+			},
+			format:     "json",
+			wantStdout: "[]\n",
+		},
+		{
+			name: "problems_text",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": map[string]interface{}{
+					".bashrc":         "",
+					".chezmoiversion": "latest\n",
+					"dot_vimrc.tmpl":  "{{ if }}",
+				},
+			},
+			format:  "text",
+			wantErr: "3 problem(s) found",
+			wantStdout: "" +
+				"/home/user/.local/share/chezmoi/.bashrc: file is ignored as it begins with .\n" +
+				"/home/user/.local/share/chezmoi/.chezmoiversion: latest is not in dotted-tri format\n" +
+				"/home/user/.local/share/chezmoi/dot_vimrc.tmpl: template: /home/user/.local/share/chezmoi/dot_vimrc.tmpl:1: missing value for if\n",
+		},
+		{
+			name: "problems_json",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/encrypted_dot_netrc": "machine example.com\n",
+			},
+			format:  "json",
+			wantErr: "1 problem(s) found",
+			wantStdout: "" +
+				"[\n" +
+				"  {\n" +
+				"    \"path\": \"/home/user/.local/share/chezmoi/encrypted_dot_netrc\",\n" +
+				"    \"check\": \"encrypted\",\n" +
+				"    \"message\": \"not encrypted\"\n" +
+				"  }\n" +
+				"]\n",
+		},
+		{
+			name: "unknown_format",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dot_bashrc": "",
+			},
+			format:  "xml",
+			wantErr: "xml: unknown format",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withLintCmdConfig(lintCmdConfig{
+					format: tc.format,
+				}),
+				withStdout(stdout),
+			)
+			err = c.runLintCmd(nil, nil)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantStdout, stdout.String())
+		})
+	}
+}
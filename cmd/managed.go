@@ -58,12 +58,15 @@ func (c *Config) runManagedCmd(cmd *cobra.Command, args []string) error {
 
 	targetNames := make([]string, 0, len(allEntries))
 	for _, entry := range allEntries {
-		if _, ok := entry.(*chezmoi.Dir); ok && !includeDirs {
+		if _, ok := entry.(*chezmoi.Dir); ok && (!includeDirs || ts.TargetIgnore.Match(chezmoi.AsDir(entry.TargetName()))) {
 			continue
 		}
 		if _, ok := entry.(*chezmoi.File); ok && !includeFiles {
 			continue
 		}
+		if _, ok := entry.(*chezmoi.Block); ok && !includeFiles {
+			continue
+		}
 		if _, ok := entry.(*chezmoi.Symlink); ok && !includeSymlinks {
 			continue
 		}
@@ -44,7 +44,7 @@ func (c *Config) runRemoveCmd(cmd *cobra.Command, args []string) error {
 	}
 	for _, entry := range entries {
 		destDirPath := filepath.Join(c.DestDir, entry.TargetName())
-		sourceDirPath := filepath.Join(c.SourceDir, entry.SourceName())
+		sourceDirPath := ts.SourcePath(entry)
 		if !c.remove.force {
 			choice, err := c.prompt(fmt.Sprintf("Remove %s and %s", destDirPath, sourceDirPath), "ynqa")
 			if err != nil {
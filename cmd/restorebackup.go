@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+var restoreBackupCmd = &cobra.Command{
+	Use:     "restore-backup [backup]",
+	Args:    cobra.MaximumNArgs(1),
+	Short:   "List backups or restore a backup to the destination directory",
+	Long:    mustGetLongHelp("restore-backup"),
+	Example: getExample("restore-backup"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runRestoreBackupCmd,
+}
+
+func init() {
+	rootCmd.AddCommand(restoreBackupCmd)
+}
+
+func (c *Config) runRestoreBackupCmd(cmd *cobra.Command, args []string) error {
+	backupsDir := c.getBackupsDir()
+
+	if len(args) == 0 {
+		infos, err := c.fs.ReadDir(backupsDir)
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		}
+		var names []string
+		for _, info := range infos {
+			if _, err := c.fs.Stat(filepath.Join(backupsDir, info.Name(), chezmoi.BackupManifestName)); err == nil {
+				names = append(names, info.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := fmt.Fprintln(c.Stdout, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	backupDir := args[0]
+	if !filepath.IsAbs(backupDir) {
+		backupDir = filepath.Join(backupsDir, backupDir)
+	}
+	return chezmoi.RestoreBackup(c.fs, c.mutator, backupDir, os.FileMode(c.Umask))
+}
@@ -0,0 +1,177 @@
+// +build !windows
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestRestoreBackupCmd(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":      &vfst.File{Perm: 0o600, Contents: []byte("# contents of .bashrc\n")},
+			".inputrc":     &vfst.File{Perm: 0o755, Contents: []byte("# contents of .inputrc\n")},
+			".link":        &vfst.Symlink{Target: ".inputrc"},
+			".profile":     "# old contents of .profile\n",
+			".unchanged":   "# contents of .unchanged\n",
+			"dir/.removed": "# contents of .removed\n",
+			"dir/subdir": map[string]interface{}{
+				".removed": "# contents of subdir/.removed\n",
+			},
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc":       "# contents of .bashrc\n",
+				"dot_inputrc":      "# contents of .inputrc\n",
+				"dot_profile":      "# new contents of .profile\n",
+				"dot_unchanged":    "# contents of .unchanged\n",
+				"dot_vimrc":        "# contents of .vimrc\n",
+				"exact_dir/.keep":  "",
+				"symlink_dot_link": ".bashrc",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(
+		fs,
+		withStdout(&bytes.Buffer{}),
+	)
+	c.mutator = chezmoi.NewBackupMutator(fs, c.mutator, c.getBackupsDir(), c.DestDir, []string{c.SourceDir})
+	assert.NoError(t, c.runApplyCmd(nil, nil))
+
+	vfst.RunTests(t, fs, "apply",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModePerm(0o644),
+		),
+		vfst.TestPath("/home/user/.profile",
+			vfst.TestContentsString("# new contents of .profile\n"),
+		),
+		vfst.TestPath("/home/user/dir/.removed",
+			vfst.TestDoesNotExist,
+		),
+		vfst.TestPath("/home/user/dir/subdir",
+			vfst.TestDoesNotExist,
+		),
+	)
+
+	stdout := &bytes.Buffer{}
+	c = newTestConfig(
+		fs,
+		withStdout(stdout),
+	)
+	assert.NoError(t, c.runRestoreBackupCmd(nil, nil))
+	backupNames := strings.Fields(stdout.String())
+	require.Len(t, backupNames, 1)
+
+	manifest, err := chezmoi.ReadBackupManifest(fs, filepath.Join("/home/user/.config/chezmoi/backups", backupNames[0]))
+	require.NoError(t, err)
+	var paths []string
+	for _, entry := range manifest.Entries {
+		paths = append(paths, entry.Path)
+	}
+	assert.Equal(t, []string{
+		"/home/user/.bashrc",
+		"/home/user/.inputrc",
+		"/home/user/.link",
+		"/home/user/.profile",
+		"/home/user/dir/.removed",
+		"/home/user/dir/subdir",
+		"/home/user/dir/subdir/.removed",
+	}, paths)
+
+	assert.NoError(t, c.runRestoreBackupCmd(nil, backupNames))
+
+	vfst.RunTests(t, fs, "restore-backup",
+		vfst.TestPath("/home/user/.bashrc",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0o600),
+			vfst.TestContentsString("# contents of .bashrc\n"),
+		),
+		vfst.TestPath("/home/user/.inputrc",
+			vfst.TestModeIsRegular,
+			vfst.TestModePerm(0o755),
+			vfst.TestContentsString("# contents of .inputrc\n"),
+		),
+		vfst.TestPath("/home/user/.link",
+			vfst.TestModeType(os.ModeSymlink),
+			vfst.TestSymlinkTarget(".inputrc"),
+		),
+		vfst.TestPath("/home/user/.profile",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# old contents of .profile\n"),
+		),
+		vfst.TestPath("/home/user/.vimrc",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .vimrc\n"),
+		),
+		vfst.TestPath("/home/user/dir/.removed",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .removed\n"),
+		),
+		vfst.TestPath("/home/user/dir/subdir/.removed",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of subdir/.removed\n"),
+		),
+	)
+}
+
+func TestRestoreBackupCmdRename(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".new":                            "# old contents of .new\n",
+			".old":                            "# contents of .old\n",
+			".local/share/chezmoi/dot_bashrc": "# contents of .bashrc\n",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(
+		fs,
+		withStdout(&bytes.Buffer{}),
+	)
+	m := chezmoi.NewBackupMutator(fs, c.mutator, c.getBackupsDir(), c.DestDir, []string{c.SourceDir})
+	require.NoError(t, m.Rename("/home/user/.old", "/home/user/.new"))
+	require.NoError(t, m.Lchown("/home/user/.new", os.Getuid(), os.Getgid()))
+	require.NoError(t, m.WriteFile("/home/user/.local/share/chezmoi/dot_bashrc", []byte("# new contents of .bashrc\n"), 0o644, nil))
+
+	manifest, err := chezmoi.ReadBackupManifest(fs, m.Dir())
+	require.NoError(t, err)
+	var paths []string
+	for _, entry := range manifest.Entries {
+		paths = append(paths, entry.Path)
+		require.NotNil(t, entry.UID)
+		require.NotNil(t, entry.GID)
+		assert.Equal(t, os.Getuid(), *entry.UID)
+		assert.Equal(t, os.Getgid(), *entry.GID)
+	}
+	assert.Equal(t, []string{
+		"/home/user/.old",
+		"/home/user/.new",
+	}, paths)
+
+	assert.NoError(t, c.runRestoreBackupCmd(nil, []string{filepath.Base(m.Dir())}))
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/.new",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# old contents of .new\n"),
+		),
+		vfst.TestPath("/home/user/.old",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("# contents of .old\n"),
+		),
+		vfst.TestPath("/home/user/.local/share/chezmoi/dot_bashrc",
+			vfst.TestContentsString("# new contents of .bashrc\n"),
+		),
+	)
+}
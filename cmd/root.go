@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/coreos/go-semver/semver"
@@ -47,6 +49,9 @@ func init() {
 
 	persistentFlags := rootCmd.PersistentFlags()
 
+	persistentFlags.BoolVar(&config.Backup.Enabled, "backup", false, "back up targets before changing them")
+	panicOnError(viper.BindPFlag("backup.enabled", persistentFlags.Lookup("backup")))
+
 	persistentFlags.StringVarP(&config.configFile, "config", "c", getDefaultConfigFile(config.bds), "config file")
 
 	persistentFlags.BoolVarP(&config.DryRun, "dry-run", "n", false, "dry run")
@@ -73,6 +78,9 @@ func init() {
 	persistentFlags.BoolVar(&config.Debug, "debug", false, "write debug logs")
 	panicOnError(viper.BindPFlag("debug", persistentFlags.Lookup("debug")))
 
+	persistentFlags.StringVar(&config.events, "events", "", "write JSON events to file or fd:N")
+	panicOnError(rootCmd.MarkPersistentFlagFilename("events"))
+
 	cobra.OnInitialize(func() {
 		_, err := os.Stat(config.configFile)
 		switch {
@@ -132,13 +140,28 @@ func Execute() {
 	}
 	rootCmd.Version = strings.Join(versionComponents, ", ")
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if config.eventsFile != nil {
+		if closeErr := config.eventsFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
 		printErrorAndExit(err)
 	}
 }
 
 //nolint:interfacer
 func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error {
+	// diff, status, and verify replace the mutator, so they would never write
+	// any events.
+	if c.events != "" {
+		switch cmd.Name() {
+		case "diff", "status", "verify":
+			return fmt.Errorf("--events is not supported by %s", cmd.Name())
+		}
+	}
+
 	switch c.Color {
 	case "on":
 		c.colored = true
@@ -164,6 +187,15 @@ func (c *Config) persistentPreRunRootE(cmd *cobra.Command, args []string) error
 	c.mutator = chezmoi.NewFSMutator(config.fs)
 	if c.DryRun {
 		c.mutator = chezmoi.NullMutator{}
+	} else if c.Backup.Enabled {
+		c.mutator = chezmoi.NewBackupMutator(c.fs, c.mutator, c.getBackupsDir(), c.DestDir, append([]string{c.SourceDir}, c.BaseSourceDirs...))
+	}
+	if c.events != "" {
+		var err error
+		if c.eventsFile, err = c.openEventsFile(); err != nil {
+			return err
+		}
+		c.mutator = chezmoi.NewEventMutator(c.fs, c.eventsFile, c.mutator, c.DryRun)
 	}
 	if c.Debug {
 		c.mutator = chezmoi.NewDebugMutator(c.mutator)
@@ -196,6 +228,19 @@ func getExample(command string) string {
 	return helps[command].example
 }
 
+// openEventsFile opens the events file c.events for writing. If c.events is of
+// the form fd:N then it returns the already-open file descriptor N.
+func (c *Config) openEventsFile() (io.WriteCloser, error) {
+	if fdStr := strings.TrimPrefix(c.events, "fd:"); fdStr != c.events {
+		fd, err := strconv.ParseUint(fdStr, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid file descriptor", c.events)
+		}
+		return os.NewFile(uintptr(fd), c.events), nil
+	}
+	return c.fs.OpenFile(c.events, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o666)
+}
+
 func markRemainingZshCompPositionalArgumentsAsFiles(cmd *cobra.Command, from int) {
 	// As far as I can tell, there is no way to mark all remaining positional
 	// arguments as files. Marking the first eight positional arguments as files
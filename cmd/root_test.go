@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestOpenEventsFile(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/events.jsonl": "# old events\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	c := newTestConfig(fs)
+	c.events = "/home/user/events.jsonl"
+	w, err := c.openEventsFile()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "{}\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/home/user/events.jsonl",
+			vfst.TestModeIsRegular,
+			vfst.TestContentsString("{}\n"),
+		),
+	)
+}
+
+func TestEventsUnsupportedCmds(t *testing.T) {
+	for _, name := range []string{"diff", "status", "verify"} {
+		t.Run(name, func(t *testing.T) {
+			c := newConfig()
+			c.events = "fd:3"
+			assert.EqualError(t, c.persistentPreRunRootE(&cobra.Command{Use: name}, nil), "--events is not supported by "+name)
+		})
+	}
+}
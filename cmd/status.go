@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+	vfs "github.com/twpayne/go-vfs"
+	bolt "go.etcd.io/bbolt"
+)
+
+var statusCmd = &cobra.Command{
+	Use:     "status [targets...]",
+	Short:   "Show the status of targets that would be changed by apply",
+	Long:    mustGetLongHelp("status"),
+	Example: getExample("status"),
+	PreRunE: config.ensureNoError,
+	RunE:    config.runStatusCmd,
+}
+
+type statusCmdConfig struct {
+	format string
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	persistentFlags := statusCmd.PersistentFlags()
+	persistentFlags.StringVarP(&config.status.format, "format", "f", "text", "format (text or JSON)")
+
+	markRemainingZshCompPositionalArgumentsAsFiles(statusCmd, 1)
+}
+
+func (c *Config) runStatusCmd(cmd *cobra.Command, args []string) error {
+	format := strings.ToLower(c.status.format)
+	if format != "json" && format != "text" {
+		return fmt.Errorf("%s: unknown format", c.status.format)
+	}
+
+	c.DryRun = true // Prevent scripts from running.
+	mutator := chezmoi.NewStatusMutator(vfs.NewReadOnlyFS(c.fs), chezmoi.NullMutator{})
+	c.mutator = mutator
+
+	persistentState, err := c.getPersistentState(&bolt.Options{
+		ReadOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer persistentState.Close()
+
+	// Discard anything written while applying, only the statuses are written.
+	stdout := c.Stdout
+	c.Stdout = ioutil.Discard
+	err = c.applyArgs(args, persistentState)
+	c.Stdout = stdout
+	if err != nil {
+		return err
+	}
+
+	statuses := mutator.Statuses()
+	switch format {
+	case "json":
+		return formatMap["json"](c.Stdout, statuses)
+	default:
+		for _, status := range statuses {
+			if _, err := fmt.Fprintf(c.Stdout, "%s %s\n", status.Code, status.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+// +build !windows
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestStatusCmd(t *testing.T) {
+	root := map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc":       "# contents of .bashrc\n",
+			".inputrc":      &vfst.File{Perm: 0755, Contents: []byte("# contents of .inputrc\n")},
+			".link":         "# a file, not a symlink\n",
+			".profile":      "# old contents of .profile\n",
+			".unchanged":    "# contents of .unchanged\n",
+			"dir/.removed":  "# contents of .removed\n",
+			"dir/.retained": "# contents of .retained\n",
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc":             "# contents of .bashrc\n",
+				"dot_inputrc":            "# contents of .inputrc\n",
+				"dot_profile":            "# new contents of .profile\n",
+				"dot_unchanged":          "# contents of .unchanged\n",
+				"dot_vimrc":              "# contents of .vimrc\n",
+				"exact_dir/dot_retained": "# contents of .retained\n",
+				"run_install":            "#!/bin/sh\n",
+				"symlink_dot_link":       ".bashrc",
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		format string
+		want   string
+	}{
+		{
+			name:   "text",
+			format: "text",
+			want: "" +
+				" M /home/user/.inputrc\n" +
+				"T  /home/user/.link\n" +
+				"M  /home/user/.profile\n" +
+				"A  /home/user/.vimrc\n" +
+				"D  /home/user/dir/.removed\n" +
+				"R  /home/user/install\n",
+		},
+		{
+			name:   "args",
+			args:   []string{"/home/user/.profile", "/home/user/.unchanged"},
+			format: "text",
+			want:   "M  /home/user/.profile\n",
+		},
+		{
+			name:   "json",
+			args:   []string{"/home/user/.profile"},
+			format: "json",
+			want: "[\n" +
+				"  {\n" +
+				"    \"path\": \"/home/user/.profile\",\n" +
+				"    \"code\": \"M \"\n" +
+				"  }\n" +
+				"]\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(root)
+			require.NoError(t, err)
+			defer cleanup()
+			stdout := &bytes.Buffer{}
+			c := newTestConfig(
+				fs,
+				withStatusCmdConfig(statusCmdConfig{
+					format: tc.format,
+				}),
+				withStdout(stdout),
+			)
+			c.Umask = 022
+			assert.NoError(t, c.runStatusCmd(nil, tc.args))
+			assert.Equal(t, tc.want, stdout.String())
+
+			_, err = fs.Stat("/home/user/.vimrc")
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
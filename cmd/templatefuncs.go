@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/twpayne/chezmoi/internal/chezmoi"
+)
+
+func init() {
+	config.addTemplateFunc("decrypt", config.decryptFunc)
+	config.addTemplateFunc("include", config.includeFunc)
+	config.addTemplateFunc("output", config.outputFunc)
+}
+
+func (c *Config) decryptFunc(name string) (string, error) {
+	path, err := c.sourceFilePath(name)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := c.fs.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := c.GPG.Decrypt(path, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return string(plaintext), nil
+}
+
+func (c *Config) includeFunc(name string) (string, error) {
+	path, err := c.sourceFilePath(name)
+	if err != nil {
+		return "", err
+	}
+	contents, err := c.fs.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+func (c *Config) outputFunc(name string, args ...string) (string, error) {
+	output, err := c.output("", name, args...)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", chezmoi.ShellQuoteArgs(append([]string{name}, args...)), err)
+	}
+	return string(output), nil
+}
+
+// sourceFilePath returns the path of the file name relative to the source
+// directory. If name does not exist in the source directory then the base
+// source directories are searched in reverse order. It returns an error if
+// name is outside the source directory.
+func (c *Config) sourceFilePath(name string) (string, error) {
+	if relPath := filepath.Clean(name); relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: not in source directory", name)
+	}
+	path := filepath.Join(c.SourceDir, name)
+	if _, err := c.fs.Lstat(path); err == nil {
+		return path, nil
+	}
+	for i := len(c.BaseSourceDirs) - 1; i >= 0; i-- {
+		baseSourcePath := filepath.Join(c.BaseSourceDirs[i], name)
+		if _, err := c.fs.Lstat(baseSourcePath); err == nil {
+			return baseSourcePath, nil
+		}
+	}
+	return path, nil
+}
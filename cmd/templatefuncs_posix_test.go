@@ -0,0 +1,111 @@
+// +build !windows
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestOutputFunc(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "simple",
+			data: `{{ output "echo" "contents" }}`,
+			want: "contents\n",
+		},
+		{
+			name: "trim",
+			data: `{{ output "sh" "-c" "echo foo; echo bar" | trim }}`,
+			want: "foo\nbar",
+		},
+		{
+			name:    "error",
+			data:    "\n\n{{ output \"false\" }}",
+			wantErr: `template: dot_file.tmpl:3:3: executing "dot_file.tmpl" at <output "false">: error calling output: false: exit status 1`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			c := newTestConfig(fs)
+			c.addTemplateFunc("output", c.outputFunc)
+			ts, err := c.getTargetState(nil)
+			require.NoError(t, err)
+			got, err := ts.ExecuteTemplateData("dot_file.tmpl", []byte(tc.data))
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestDecryptFunc(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+	for _, tc := range []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "simple",
+			data: `{{ decrypt ".secret" }}`,
+			want: "contents\n",
+		},
+		{
+			name: "base_source_dir",
+			data: `{{ decrypt ".base-secret" | upper }}`,
+			want: "BASE CONTENTS\n",
+		},
+		{
+			name:    "outside_source_dir",
+			data:    `{{ decrypt "../.base-secret" }}`,
+			wantErr: `template: dot_file.tmpl:1:3: executing "dot_file.tmpl" at <decrypt "../.base-secret">: error calling decrypt: ../.base-secret: not in source directory`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/home/user/.local/share/base/.base-secret": "-----BEGIN PGP MESSAGE-----\nbase contents\n",
+				"/home/user/.local/share/chezmoi/.secret":   "-----BEGIN PGP MESSAGE-----\ncontents\n",
+			})
+			require.NoError(t, err)
+			defer cleanup()
+			c := newTestConfig(
+				fs,
+				withBaseSourceDirs([]string{"/home/user/.local/share/base"}),
+			)
+			c.GPG.Command = newTestGPGCommand(t, tempDir)
+			c.addTemplateFunc("decrypt", c.decryptFunc)
+			ts, err := c.getTargetState(nil)
+			require.NoError(t, err)
+			got, err := ts.ExecuteTemplateData("dot_file.tmpl", []byte(tc.data))
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestIncludeFunc(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		root    interface{}
+		data    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "simple",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/.include": "contents",
+			},
+			data: `{{ include ".include" }}`,
+			want: "contents",
+		},
+		{
+			name: "subdir",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi/dir/.include": "contents",
+			},
+			data: `{{ include "dir/.include" | upper }}`,
+			want: "CONTENTS",
+		},
+		{
+			name: "missing",
+			root: map[string]interface{}{
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+			},
+			data:    "\n{{ include \".include\" }}",
+			wantErr: `template: dot_file.tmpl:2:3: executing "dot_file.tmpl" at <include ".include">: error calling include: open`,
+		},
+		{
+			name: "outside_source_dir",
+			root: map[string]interface{}{
+				"/home/user/.bashrc":              "contents",
+				"/home/user/.local/share/chezmoi": &vfst.Dir{Perm: 0700},
+			},
+			data:    `{{ include "../../../.bashrc" }}`,
+			wantErr: `error calling include: ../../../.bashrc: not in source directory`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			c := newTestConfig(fs)
+			c.addTemplateFunc("include", c.includeFunc)
+			ts, err := c.getTargetState(nil)
+			require.NoError(t, err)
+			got, err := ts.ExecuteTemplateData("dot_file.tmpl", []byte(tc.data))
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
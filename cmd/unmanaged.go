@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/twpayne/chezmoi/internal/chezmoi"
 	vfs "github.com/twpayne/go-vfs"
 )
 
@@ -38,7 +39,11 @@ func (c *Config) runUnmanagedCmd(cmd *cobra.Command, args []string) error {
 		}
 		entry, _ := ts.Get(c.fs, path)
 		managed := entry != nil
-		ignored := ts.TargetIgnore.Match(strings.TrimPrefix(path, c.DestDir+"/"))
+		targetName := strings.TrimPrefix(path, c.DestDir+"/")
+		if info.IsDir() {
+			targetName = chezmoi.AsDir(targetName)
+		}
+		ignored := ts.TargetIgnore.Match(targetName)
 		if !managed && !ignored {
 			fmt.Println(path)
 		}
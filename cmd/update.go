@@ -26,6 +26,8 @@ func init() {
 
 	persistentFlags := updateCmd.PersistentFlags()
 	persistentFlags.BoolVarP(&config.update.apply, "apply", "a", true, "apply after pulling")
+	persistentFlags.BoolVar(&config.apply.force, "force", false, "overwrite files changed since they were last written")
+	persistentFlags.BoolVar(&config.apply.merge, "merge", false, "merge files changed since they were last written")
 }
 
 func (c *Config) runUpdateCmd(cmd *cobra.Command, args []string) error {
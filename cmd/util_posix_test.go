@@ -2,6 +2,38 @@
 
 package cmd
 
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testGPGScript is a fake gpg that encrypts by adding an armor header line and
+// decrypts by removing it.
+const testGPGScript = `#!/bin/sh
+while [ $# -gt 1 ]; do
+	case "$1" in
+	--decrypt) mode=decrypt ;;
+	--encrypt|--symmetric) mode=encrypt ;;
+	--output) output="$2"; shift ;;
+	esac
+	shift
+done
+case "$mode" in
+decrypt) sed 1d "$1" >"$output" ;;
+encrypt) { echo "-----BEGIN PGP MESSAGE-----"; cat "$1"; } >"$output" ;;
+esac
+`
+
 func lines(s string) string {
 	return s
 }
+
+// newTestGPGCommand writes testGPGScript to dir and returns its path.
+func newTestGPGCommand(t *testing.T, dir string) string {
+	command := filepath.Join(dir, "gpg")
+	require.NoError(t, ioutil.WriteFile(command, []byte(testGPGScript), 0o700))
+	return command
+}
@@ -24,8 +24,9 @@ func init() {
 }
 
 func (c *Config) runVerifyCmd(cmd *cobra.Command, args []string) error {
+	c.DryRun = true // Prevent the state of scripts from being recorded.
 	mutator := chezmoi.NewAnyMutator(chezmoi.NullMutator{})
-	c.mutator = mutator
+	c.mutator = verifyMutator{mutator}
 
 	persistentState, err := c.getPersistentState(&bolt.Options{
 		ReadOnly: true,
@@ -43,3 +44,14 @@ func (c *Config) runVerifyCmd(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// A verifyMutator wraps a Mutator and ignores scripts, as scripts do not have
+// a target state to verify.
+type verifyMutator struct {
+	chezmoi.Mutator
+}
+
+// RunScript implements chezmoi.Mutator.RunScript.
+func (verifyMutator) RunScript(name, dir string, data []byte) error {
+	return nil
+}
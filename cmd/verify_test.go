@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestVerifyIgnoresScripts(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": map[string]interface{}{
+			".bashrc": &vfst.File{Perm: 0o644, Contents: []byte("# contents of .bashrc\n")},
+			".local/share/chezmoi": map[string]interface{}{
+				"dot_bashrc":  "# contents of .bashrc\n",
+				"run_install": "#!/bin/sh\n# install\n",
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	// runVerifyCmd exits the process if the destination state does not match
+	// the target state.
+	assert.NoError(t, newTestConfig(fs).runVerifyCmd(nil, nil))
+}
@@ -31,6 +31,12 @@ func (m *AnyMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	return m.m.IdempotentCmdOutput(cmd)
 }
 
+// Lchown implements Mutator.Lchown.
+func (m *AnyMutator) Lchown(name string, uid, gid int) error {
+	m.mutated = true
+	return m.m.Lchown(name, uid, gid)
+}
+
 // Mkdir implements Mutator.Mkdir.
 func (m *AnyMutator) Mkdir(name string, perm os.FileMode) error {
 	m.mutated = true
@@ -60,6 +66,12 @@ func (m *AnyMutator) RunCmd(cmd *exec.Cmd) error {
 	return m.m.RunCmd(cmd)
 }
 
+// RunScript implements Mutator.RunScript.
+func (m *AnyMutator) RunScript(name, dir string, data []byte) error {
+	m.mutated = true
+	return m.m.RunScript(name, dir, data)
+}
+
 // Stat implements Mutator.Stat.
 func (m *AnyMutator) Stat(path string) (os.FileInfo, error) {
 	return m.m.Stat(path)
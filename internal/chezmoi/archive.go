@@ -0,0 +1,271 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PAX record keys for chezmoi-specific metadata in tar archives.
+const (
+	PAXRecordAfter    = "CHEZMOI.after"
+	PAXRecordBefore   = "CHEZMOI.before"
+	PAXRecordOnChange = "CHEZMOI.onchange"
+	PAXRecordOnce     = "CHEZMOI.once"
+	PAXRecordType     = "CHEZMOI.type"
+)
+
+// paxRecordPrefix is the prefix of all chezmoi-specific PAX record keys.
+const paxRecordPrefix = "CHEZMOI."
+
+// Values of the PAXRecordType PAX record.
+const (
+	PAXRecordTypeBlock  = "block"
+	PAXRecordTypeScript = "script"
+)
+
+// An EntryTypeSet is a set of entry types.
+type EntryTypeSet int
+
+// Entry types.
+const (
+	EntryTypeDirs EntryTypeSet = 1 << iota
+	EntryTypeFiles
+	EntryTypeScripts
+	EntryTypeSymlinks
+
+	EntryTypesAll  = EntryTypeDirs | EntryTypeFiles | EntryTypeScripts | EntryTypeSymlinks
+	EntryTypesNone = EntryTypeSet(0)
+)
+
+// entryTypeNames maps entry type names to EntryTypeSets. Blocks are archived
+// as files, so they are included and excluded with files.
+var entryTypeNames = map[string]EntryTypeSet{
+	"all":      EntryTypesAll,
+	"dirs":     EntryTypeDirs,
+	"d":        EntryTypeDirs,
+	"files":    EntryTypeFiles,
+	"f":        EntryTypeFiles,
+	"scripts":  EntryTypeScripts,
+	"symlinks": EntryTypeSymlinks,
+	"s":        EntryTypeSymlinks,
+}
+
+// ParseEntryTypeSet parses names, a list of entry type names, into an
+// EntryTypeSet.
+func ParseEntryTypeSet(names []string) (EntryTypeSet, error) {
+	s := EntryTypesNone
+	for _, name := range names {
+		entryType, ok := entryTypeNames[strings.ToLower(name)]
+		if !ok {
+			return EntryTypesNone, fmt.Errorf("%s: unknown entry type", name)
+		}
+		s |= entryType
+	}
+	return s, nil
+}
+
+// Has returns true if s contains all of the entry types in other.
+func (s EntryTypeSet) Has(other EntryTypeSet) bool {
+	return s&other == other
+}
+
+// An ArchiveReader reads entries described by tar headers from an archive.
+// *tar.Reader implements ArchiveReader.
+type ArchiveReader interface {
+	io.Reader
+	Next() (*tar.Header, error)
+}
+
+// An ArchiveWriter writes entries described by tar headers to an archive.
+// *tar.Writer implements ArchiveWriter.
+type ArchiveWriter interface {
+	io.Writer
+	WriteHeader(header *tar.Header) error
+}
+
+// A ZipWriter is an ArchiveWriter that writes a zip archive. chezmoi's PAX
+// records are written in each file's comment, one key=value pair per line.
+type ZipWriter struct {
+	w  *zip.Writer
+	fw io.Writer
+}
+
+// NewZipWriter returns a new ZipWriter that writes to w.
+func NewZipWriter(w io.Writer) *ZipWriter {
+	return &ZipWriter{
+		w: zip.NewWriter(w),
+	}
+}
+
+// Close closes zw. It does not close the underlying io.Writer.
+func (zw *ZipWriter) Close() error {
+	return zw.w.Close()
+}
+
+// Write implements io.Writer.Write.
+func (zw *ZipWriter) Write(p []byte) (int, error) {
+	if zw.fw == nil {
+		return 0, errors.New("write before header")
+	}
+	return zw.fw.Write(p)
+}
+
+// WriteHeader implements ArchiveWriter.WriteHeader. Symlinks are written as
+// files containing the link target, following the convention used by Info-ZIP.
+func (zw *ZipWriter) WriteHeader(header *tar.Header) error {
+	fh, err := zip.FileInfoHeader(header.FileInfo())
+	if err != nil {
+		return err
+	}
+	fh.Name = filepath.ToSlash(header.Name)
+	fh.Comment = zipComment(header.PAXRecords)
+	switch header.Typeflag {
+	case tar.TypeDir:
+		fh.Name += "/"
+	case tar.TypeReg:
+		fh.Method = zip.Deflate
+	case tar.TypeSymlink:
+	default:
+		return fmt.Errorf("%s: unsupported type %q", header.Name, header.Typeflag)
+	}
+	zw.fw, err = zw.w.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	if header.Typeflag == tar.TypeSymlink {
+		_, err = io.WriteString(zw.fw, header.Linkname)
+	}
+	return err
+}
+
+// A ZipReader is an ArchiveReader that reads a zip archive.
+type ZipReader struct {
+	files []*zip.File
+	rc    io.ReadCloser
+}
+
+// NewZipReader returns a new ZipReader that reads from r.
+func NewZipReader(r *zip.Reader) *ZipReader {
+	return &ZipReader{
+		files: r.File,
+	}
+}
+
+// Next implements ArchiveReader.Next. Files with the symlink mode are returned
+// as symlinks to their contents, and chezmoi's PAX records are read from each
+// file's comment.
+func (zr *ZipReader) Next() (*tar.Header, error) {
+	if zr.rc != nil {
+		if err := zr.rc.Close(); err != nil {
+			return nil, err
+		}
+		zr.rc = nil
+	}
+	if len(zr.files) == 0 {
+		return nil, io.EOF
+	}
+	f := zr.files[0]
+	zr.files = zr.files[1:]
+
+	header := &tar.Header{
+		Name:       strings.TrimSuffix(f.Name, "/"),
+		Mode:       int64(f.Mode().Perm()),
+		ModTime:    f.Modified,
+		PAXRecords: parseZipComment(f.Comment),
+	}
+	switch mode := f.Mode(); {
+	case mode.IsDir():
+		header.Typeflag = tar.TypeDir
+		return header, nil
+	case mode.IsRegular():
+		header.Typeflag = tar.TypeReg
+		header.Size = int64(f.UncompressedSize64)
+	case mode&os.ModeType == os.ModeSymlink:
+		header.Typeflag = tar.TypeSymlink
+	default:
+		return nil, fmt.Errorf("%s: unsupported mode %s", f.Name, mode)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	if header.Typeflag == tar.TypeSymlink {
+		linkname, err := ioutil.ReadAll(rc)
+		if err != nil {
+			_ = rc.Close()
+			return nil, err
+		}
+		header.Linkname = string(linkname)
+		return header, rc.Close()
+	}
+	zr.rc = rc
+	return header, nil
+}
+
+// Read implements io.Reader.Read.
+func (zr *ZipReader) Read(p []byte) (int, error) {
+	if zr.rc == nil {
+		return 0, io.EOF
+	}
+	return zr.rc.Read(p)
+}
+
+// parseZipComment returns the chezmoi PAX records in the zip file comment
+// comment. Other lines are ignored.
+func parseZipComment(comment string) map[string]string {
+	var paxRecords map[string]string
+	for _, line := range strings.Split(comment, "\n") {
+		if !strings.HasPrefix(line, paxRecordPrefix) {
+			continue
+		}
+		components := strings.SplitN(line, "=", 2)
+		if len(components) != 2 {
+			continue
+		}
+		if paxRecords == nil {
+			paxRecords = make(map[string]string)
+		}
+		paxRecords[components[0]] = components[1]
+	}
+	return paxRecords
+}
+
+// scriptPAXRecords returns the PAX records describing s.
+func scriptPAXRecords(s *Script) map[string]string {
+	paxRecords := map[string]string{
+		PAXRecordType: PAXRecordTypeScript,
+	}
+	for key, value := range map[string]bool{
+		PAXRecordAfter:    s.After,
+		PAXRecordBefore:   s.Before,
+		PAXRecordOnChange: s.OnChange,
+		PAXRecordOnce:     s.Once,
+	} {
+		if value {
+			paxRecords[key] = strconv.FormatBool(value)
+		}
+	}
+	return paxRecords
+}
+
+// zipComment returns a zip file comment containing the chezmoi PAX records in
+// paxRecords, sorted by key.
+func zipComment(paxRecords map[string]string) string {
+	var lines []string
+	for key, value := range paxRecords {
+		if strings.HasPrefix(key, paxRecordPrefix) {
+			lines = append(lines, key+"="+value)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
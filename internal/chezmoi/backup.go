@@ -0,0 +1,172 @@
+package chezmoi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// BackupManifestName is the name of the manifest in a backup directory.
+const BackupManifestName = "manifest.json"
+
+// Backup entry types.
+const (
+	BackupEntryTypeDir     = "dir"
+	BackupEntryTypeFile    = "file"
+	BackupEntryTypeSymlink = "symlink"
+)
+
+// A BackupEntry describes a single backed up target. Contents is the path of
+// the backed up contents of a file, relative to the backup directory. UID and
+// GID are the owner and group of the target, if known.
+type BackupEntry struct {
+	Path     string      `json:"path"`
+	Type     string      `json:"type"`
+	Perm     os.FileMode `json:"perm,omitempty"`
+	UID      *int        `json:"uid,omitempty"`
+	GID      *int        `json:"gid,omitempty"`
+	Contents string      `json:"contents,omitempty"`
+	Linkname string      `json:"linkname,omitempty"`
+}
+
+// A BackupManifest describes the contents of a backup directory. Entries are
+// in the order in which they were backed up, so parent directories always
+// precede their children.
+type BackupManifest struct {
+	Time    time.Time      `json:"time"`
+	Entries []*BackupEntry `json:"entries"`
+}
+
+// ReadBackupManifest reads the manifest of the backup in dir.
+func ReadBackupManifest(fs vfs.FS, dir string) (*BackupManifest, error) {
+	data, err := fs.ReadFile(filepath.Join(dir, BackupManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, BackupManifestName), err)
+	}
+	return &manifest, nil
+}
+
+// RestoreBackup restores the backup in dir using mutator. Missing parent
+// directories are created with umask applied.
+func RestoreBackup(fs vfs.FS, mutator Mutator, dir string, umask os.FileMode) error {
+	manifest, err := ReadBackupManifest(fs, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range manifest.Entries {
+		if err := restoreBackupEntry(fs, mutator, dir, entry, umask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupContentsName returns the name of the backed up contents of name,
+// relative to the backup directory.
+func backupContentsName(name string) string {
+	name = strings.TrimPrefix(name, filepath.VolumeName(name))
+	return filepath.Join("files", strings.TrimLeft(name, `/\`))
+}
+
+// restoreBackupEntry restores entry, including its owner and group, from the
+// backup in dir.
+func restoreBackupEntry(fs vfs.FS, mutator Mutator, dir string, entry *BackupEntry, umask os.FileMode) error {
+	if err := restoreBackupEntryContents(fs, mutator, dir, entry, umask); err != nil {
+		return err
+	}
+	var o Ownership
+	if entry.UID != nil {
+		o.Owner = strconv.Itoa(*entry.UID)
+	}
+	if entry.GID != nil {
+		o.Group = strconv.Itoa(*entry.GID)
+	}
+	return applyOwnership(fs, mutator, entry.Path, o)
+}
+
+// restoreBackupEntryContents restores the type, permissions, and contents of
+// entry from the backup in dir.
+func restoreBackupEntryContents(fs vfs.FS, mutator Mutator, dir string, entry *BackupEntry, umask os.FileMode) error {
+	info, err := fs.Lstat(entry.Path)
+	switch {
+	case err == nil:
+	case os.IsNotExist(err):
+		if err := vfs.MkdirAll(mutator, filepath.Dir(entry.Path), 0o777&^umask); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	switch entry.Type {
+	case BackupEntryTypeDir:
+		switch {
+		case info == nil:
+			return mutator.Mkdir(entry.Path, entry.Perm)
+		case !info.IsDir():
+			if err := mutator.RemoveAll(entry.Path); err != nil {
+				return err
+			}
+			return mutator.Mkdir(entry.Path, entry.Perm)
+		case info.Mode().Perm() != entry.Perm:
+			return mutator.Chmod(entry.Path, entry.Perm)
+		default:
+			return nil
+		}
+	case BackupEntryTypeFile:
+		contents, err := fs.ReadFile(filepath.Join(dir, entry.Contents))
+		if err != nil {
+			return err
+		}
+		var currData []byte
+		switch {
+		case info == nil:
+		case info.Mode().IsRegular():
+			currData, err = fs.ReadFile(entry.Path)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(currData, contents) {
+				if info.Mode().Perm() == entry.Perm {
+					return nil
+				}
+				return mutator.Chmod(entry.Path, entry.Perm)
+			}
+		default:
+			if err := mutator.RemoveAll(entry.Path); err != nil {
+				return err
+			}
+		}
+		return mutator.WriteFile(entry.Path, contents, entry.Perm, currData)
+	case BackupEntryTypeSymlink:
+		switch {
+		case info == nil:
+		case info.Mode()&os.ModeType == os.ModeSymlink:
+			linkname, err := fs.Readlink(entry.Path)
+			if err != nil {
+				return err
+			}
+			if linkname == entry.Linkname {
+				return nil
+			}
+		case info.IsDir():
+			if err := mutator.RemoveAll(entry.Path); err != nil {
+				return err
+			}
+		}
+		return mutator.WriteSymlink(entry.Linkname, entry.Path)
+	default:
+		return fmt.Errorf("%s: unknown backup entry type %q", entry.Path, entry.Type)
+	}
+}
@@ -0,0 +1,262 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// A BackupMutator wraps a Mutator and, before a target is overwritten,
+// chmodded, chowned, renamed, or removed, copies the original into a new
+// timestamped directory in backupsDir and records it in the directory's
+// manifest. Only targets in destDir are backed up, and anything in sourceDirs
+// is never backed up. The backup directory is only created when the first
+// target is backed up.
+type BackupMutator struct {
+	fs         vfs.FS
+	m          Mutator
+	backupsDir string
+	destDir    string
+	sourceDirs []string
+	dir        string
+	manifest   *BackupManifest
+	backedUp   map[string]bool
+	now        func() time.Time
+}
+
+// NewBackupMutator returns a new BackupMutator.
+func NewBackupMutator(fs vfs.FS, m Mutator, backupsDir, destDir string, sourceDirs []string) *BackupMutator {
+	return &BackupMutator{
+		fs:         fs,
+		m:          m,
+		backupsDir: backupsDir,
+		destDir:    destDir,
+		sourceDirs: sourceDirs,
+		backedUp:   make(map[string]bool),
+		now:        time.Now,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *BackupMutator) Chmod(name string, mode os.FileMode) error {
+	if err := m.backup(name, false); err != nil {
+		return err
+	}
+	return m.m.Chmod(name, mode)
+}
+
+// Dir returns the backup directory, or the empty string if nothing has been
+// backed up.
+func (m *BackupMutator) Dir() string {
+	return m.dir
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *BackupMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Lchown implements Mutator.Lchown.
+func (m *BackupMutator) Lchown(name string, uid, gid int) error {
+	if err := m.backup(name, false); err != nil {
+		return err
+	}
+	return m.m.Lchown(name, uid, gid)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *BackupMutator) Mkdir(name string, perm os.FileMode) error {
+	return m.m.Mkdir(name, perm)
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *BackupMutator) RemoveAll(name string) error {
+	if err := m.backup(name, true); err != nil {
+		return err
+	}
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *BackupMutator) Rename(oldpath, newpath string) error {
+	if err := m.backup(oldpath, true); err != nil {
+		return err
+	}
+	if err := m.backup(newpath, true); err != nil {
+		return err
+	}
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *BackupMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// RunScript implements Mutator.RunScript.
+func (m *BackupMutator) RunScript(name, dir string, data []byte) error {
+	return m.m.RunScript(name, dir, data)
+}
+
+// Stat implements Mutator.Stat.
+func (m *BackupMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *BackupMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	if err := m.backup(name, false); err != nil {
+		return err
+	}
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *BackupMutator) WriteSymlink(oldname, newname string) error {
+	if err := m.backup(newname, false); err != nil {
+		return err
+	}
+	return m.m.WriteSymlink(oldname, newname)
+}
+
+// backup backs up name, if it exists and has not already been backed up. If
+// recursive is true then the contents of directories are also backed up.
+func (m *BackupMutator) backup(name string, recursive bool) error {
+	if m.backedUp[name] && !recursive || !m.isTarget(name) {
+		return nil
+	}
+	info, err := m.fs.Lstat(name)
+	switch {
+	case err == nil:
+	case os.IsNotExist(err):
+		return nil
+	default:
+		return err
+	}
+
+	if !m.backedUp[name] {
+		entry := &BackupEntry{
+			Path: name,
+			Perm: info.Mode().Perm(),
+		}
+		if uid, gid, ok := fileOwnership(info); ok {
+			entry.UID, entry.GID = &uid, &gid
+		}
+		switch {
+		case info.IsDir():
+			entry.Type = BackupEntryTypeDir
+		case info.Mode().IsRegular():
+			entry.Type = BackupEntryTypeFile
+			entry.Contents = backupContentsName(name)
+		case info.Mode()&os.ModeType == os.ModeSymlink:
+			entry.Type = BackupEntryTypeSymlink
+			entry.Perm = 0
+			entry.Linkname, err = m.fs.Readlink(name)
+			if err != nil {
+				return err
+			}
+		default:
+			// Other types, e.g. named pipes and devices, cannot be restored.
+			return nil
+		}
+		if err := m.addEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	if !recursive || !info.IsDir() {
+		return nil
+	}
+	infos, err := m.fs.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := m.backup(filepath.Join(name, info.Name()), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEntry copies the contents of entry into the backup directory, creating
+// it if needed, adds entry to the manifest, and rewrites the manifest so that
+// it remains valid if chezmoi is interrupted.
+func (m *BackupMutator) addEntry(entry *BackupEntry) error {
+	if m.dir == "" {
+		if err := m.makeDir(); err != nil {
+			return err
+		}
+	}
+	if entry.Contents != "" {
+		data, err := m.fs.ReadFile(entry.Path)
+		if err != nil {
+			return err
+		}
+		contentsPath := filepath.Join(m.dir, entry.Contents)
+		if err := vfs.MkdirAll(m.fs, filepath.Dir(contentsPath), 0o700); err != nil {
+			return err
+		}
+		if err := m.fs.WriteFile(contentsPath, data, 0o600); err != nil {
+			return err
+		}
+	}
+	m.manifest.Entries = append(m.manifest.Entries, entry)
+	m.backedUp[entry.Path] = true
+	data, err := json.MarshalIndent(m.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return m.fs.WriteFile(filepath.Join(m.dir, BackupManifestName), append(data, '\n'), 0o600)
+}
+
+// isTarget returns true if name is in m.destDir and not in any of
+// m.sourceDirs.
+func (m *BackupMutator) isTarget(name string) bool {
+	if !isInDir(name, m.destDir) {
+		return false
+	}
+	for _, sourceDir := range m.sourceDirs {
+		if isInDir(name, sourceDir) {
+			return false
+		}
+	}
+	return true
+}
+
+// makeDir creates a new, uniquely named, backup directory.
+func (m *BackupMutator) makeDir() error {
+	if err := vfs.MkdirAll(m.fs, m.backupsDir, 0o700); err != nil {
+		return err
+	}
+	now := m.now().UTC()
+	name := now.Format("20060102T150405Z")
+	for i := 1; ; i++ {
+		dir := filepath.Join(m.backupsDir, name)
+		err := m.fs.Mkdir(dir, 0o700)
+		if err == nil {
+			m.dir = dir
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		name = now.Format("20060102T150405Z") + "-" + strconv.Itoa(i)
+	}
+	m.manifest = &BackupManifest{
+		Time: now,
+	}
+	return nil
+}
+
+// isInDir returns true if name is dir or is in dir.
+func isInDir(name, dir string) bool {
+	relPath, err := filepath.Rel(dir, name)
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,268 @@
+package chezmoi
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Default block markers.
+const (
+	DefaultBlockBegin = "# BEGIN chezmoi managed block"
+	DefaultBlockEnd   = "# END chezmoi managed block"
+)
+
+// A BlockAttributes holds attributes parsed from a source block name.
+type BlockAttributes struct {
+	Name     string
+	Template bool
+}
+
+// A Block represents the target state of a block of lines, delimited by begin
+// and end marker lines, in a file whose other contents are not managed.
+type Block struct {
+	sourceName       string
+	targetName       string
+	Begin            string
+	End              string
+	Template         bool
+	contents         []byte
+	contentsErr      error
+	evaluateContents func() ([]byte, error)
+}
+
+type blockConcreteValue struct {
+	Type       string   `json:"type" yaml:"type"`
+	SourcePath string   `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath string   `json:"targetPath" yaml:"targetPath"`
+	Begin      string   `json:"begin" yaml:"begin"`
+	End        string   `json:"end" yaml:"end"`
+	Template   bool     `json:"template" yaml:"template"`
+	Contents   string   `json:"contents" yaml:"contents"`
+	Overrides  []string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// ParseBlockAttributes parses a source block name.
+func ParseBlockAttributes(sourceName string) BlockAttributes {
+	name := strings.TrimPrefix(sourceName, blockPrefix)
+	template := false
+	if strings.HasPrefix(name, dotPrefix) {
+		name = "." + strings.TrimPrefix(name, dotPrefix)
+	}
+	if strings.HasSuffix(name, TemplateSuffix) {
+		name = strings.TrimSuffix(name, TemplateSuffix)
+		template = true
+	}
+	return BlockAttributes{
+		Name:     name,
+		Template: template,
+	}
+}
+
+// SourceName returns ba's source name.
+func (ba BlockAttributes) SourceName() string {
+	sourceName := blockPrefix
+	if strings.HasPrefix(ba.Name, ".") {
+		sourceName += dotPrefix + strings.TrimPrefix(ba.Name, ".")
+	} else {
+		sourceName += ba.Name
+	}
+	if ba.Template {
+		sourceName += TemplateSuffix
+	}
+	return sourceName
+}
+
+// AppendAllEntries appends b to allEntries.
+func (b *Block) AppendAllEntries(allEntries []Entry) []Entry {
+	return append(allEntries, b)
+}
+
+// Apply ensures that the block in b's target in fs matches b, leaving the rest
+// of the target unchanged.
+func (b *Block) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
+	if applyOptions.Ignore(b.targetName) {
+		return nil
+	}
+	if ok, err := applyOptions.confirm(fs, b, filepath.Join(applyOptions.DestDir, b.targetName), follow); err != nil || !ok {
+		return err
+	}
+	contents, err := b.Contents()
+	if err != nil {
+		return err
+	}
+	targetPath := filepath.Join(applyOptions.DestDir, b.targetName)
+	var info os.FileInfo
+	if follow {
+		info, err = fs.Stat(targetPath)
+	} else {
+		info, err = fs.Lstat(targetPath)
+	}
+	var currData []byte
+	perm := 0666 &^ applyOptions.Umask
+	switch {
+	case err == nil && info.Mode().IsRegular():
+		currData, err = fs.ReadFile(targetPath)
+		if err != nil {
+			return err
+		}
+		perm = info.Mode().Perm()
+	case err == nil:
+		return fmt.Errorf("%s: not a regular file", targetPath)
+	case os.IsNotExist(err):
+	default:
+		return err
+	}
+	newData, err := replaceBlock(currData, b.Begin, b.End, contents)
+	if err != nil {
+		return fmt.Errorf("%s: %w", targetPath, err)
+	}
+	if !bytes.Equal(currData, newData) {
+		if err := mutator.WriteFile(targetPath, newData, perm, currData); err != nil {
+			return err
+		}
+	}
+	return applyOptions.recordFileState(targetPath, newData)
+}
+
+// ConcreteValue implements Entry.ConcreteValue.
+func (b *Block) ConcreteValue(ignore func(string) bool, sourcePaths func(Entry) []string, umask os.FileMode, recursive bool) (interface{}, error) {
+	if ignore(b.targetName) {
+		return nil, nil
+	}
+	contents, err := b.Contents()
+	if err != nil {
+		return nil, err
+	}
+	paths := sourcePaths(b)
+	return &blockConcreteValue{
+		Type:       "block",
+		SourcePath: paths[0],
+		TargetPath: b.TargetName(),
+		Begin:      b.Begin,
+		End:        b.End,
+		Template:   b.Template,
+		Contents:   string(contents),
+		Overrides:  paths[1:],
+	}, nil
+}
+
+// Contents returns b's contents.
+func (b *Block) Contents() ([]byte, error) {
+	if b.evaluateContents != nil {
+		b.contents, b.contentsErr = b.evaluateContents()
+		b.evaluateContents = nil
+	}
+	return b.contents, b.contentsErr
+}
+
+// Evaluate evaluates b's contents.
+func (b *Block) Evaluate(ignore func(string) bool) error {
+	if ignore(b.targetName) {
+		return nil
+	}
+	_, err := b.Contents()
+	return err
+}
+
+// SourceName implements Entry.SourceName.
+func (b *Block) SourceName() string {
+	return b.sourceName
+}
+
+// TargetName implements Entry.TargetName.
+func (b *Block) TargetName() string {
+	return b.targetName
+}
+
+// archive writes b to w as a file containing only the block.
+func (b *Block) archive(w ArchiveWriter, ignore func(string) bool, include EntryTypeSet, headerTemplate *tar.Header, umask os.FileMode, warn func(string)) error {
+	if !include.Has(EntryTypeFiles) || ignore(b.targetName) {
+		return nil
+	}
+	contents, err := b.Contents()
+	if err != nil {
+		return err
+	}
+	data, err := replaceBlock(nil, b.Begin, b.End, contents)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	header := *headerTemplate
+	header.Typeflag = tar.TypeReg
+	header.Name = b.targetName
+	header.Size = int64(len(data))
+	header.Mode = int64(0666 &^ umask)
+	header.PAXRecords = map[string]string{
+		PAXRecordType: PAXRecordTypeBlock,
+	}
+	if err := w.WriteHeader(&header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// replaceBlock returns data with the lines between the begin and end marker
+// lines, inclusive, replaced by a block containing contents. If data does not
+// contain the begin marker line then the block is appended. If contents is
+// empty then the block is removed. Lines added to data end with the same line
+// ending as the first line of data.
+func replaceBlock(data []byte, begin, end string, contents []byte) ([]byte, error) {
+	eol := "\n"
+	if index := bytes.IndexByte(data, '\n'); index > 0 && data[index-1] == '\r' {
+		eol = "\r\n"
+	}
+
+	beginOffset, endOffset := -1, -1
+	for offset := 0; offset < len(data) && endOffset == -1; {
+		nextOffset := len(data)
+		if index := bytes.IndexByte(data[offset:], '\n'); index != -1 {
+			nextOffset = offset + index + 1
+		}
+		line := string(bytes.TrimRight(data[offset:nextOffset], "\r\n"))
+		switch {
+		case beginOffset == -1 && line == begin:
+			beginOffset = offset
+		case beginOffset != -1 && line == end:
+			endOffset = nextOffset
+		}
+		offset = nextOffset
+	}
+
+	var block []byte
+	if !isEmpty(contents) {
+		block = append(block, begin+eol...)
+		block = append(block, contents...)
+		if !bytes.HasSuffix(contents, []byte("\n")) {
+			block = append(block, eol...)
+		}
+		block = append(block, end+eol...)
+	}
+
+	switch {
+	case beginOffset == -1:
+		if block == nil {
+			return data, nil
+		}
+		result := append([]byte{}, data...)
+		if len(result) > 0 && result[len(result)-1] != '\n' {
+			result = append(result, eol...)
+		}
+		return append(result, block...), nil
+	case endOffset == -1:
+		return nil, fmt.Errorf("%q: end marker %q not found", begin, end)
+	default:
+		result := append([]byte{}, data[:beginOffset]...)
+		result = append(result, block...)
+		return append(result, data[endOffset:]...), nil
+	}
+}
@@ -0,0 +1,156 @@
+package chezmoi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestBlockAttributes(t *testing.T) {
+	for _, tc := range []struct {
+		sourceName string
+		ba         BlockAttributes
+	}{
+		{
+			sourceName: "block_foo",
+			ba: BlockAttributes{
+				Name: "foo",
+			},
+		},
+		{
+			sourceName: "block_dot_foo",
+			ba: BlockAttributes{
+				Name: ".foo",
+			},
+		},
+		{
+			sourceName: "block_dot_foo.tmpl",
+			ba: BlockAttributes{
+				Name:     ".foo",
+				Template: true,
+			},
+		},
+	} {
+		t.Run(tc.sourceName, func(t *testing.T) {
+			assert.Equal(t, tc.ba, ParseBlockAttributes(tc.sourceName))
+			assert.Equal(t, tc.sourceName, tc.ba.SourceName())
+		})
+	}
+}
+
+func TestReplaceBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		contents string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "empty",
+			data:     "",
+			contents: "foo\n",
+			want:     "# begin\nfoo\n# end\n",
+		},
+		{
+			name:     "append",
+			data:     "bar\n",
+			contents: "foo\n",
+			want:     "bar\n# begin\nfoo\n# end\n",
+		},
+		{
+			name:     "append_missing_newline",
+			data:     "bar",
+			contents: "foo",
+			want:     "bar\n# begin\nfoo\n# end\n",
+		},
+		{
+			name:     "replace",
+			data:     "bar\n# begin\nbaz\n# end\nqux\n",
+			contents: "foo\n",
+			want:     "bar\n# begin\nfoo\n# end\nqux\n",
+		},
+		{
+			name:     "replace_crlf",
+			data:     "bar\r\n# begin\r\nbaz\r\n# end\r\nqux\r\n",
+			contents: "foo\r\n",
+			want:     "bar\r\n# begin\r\nfoo\r\n# end\r\nqux\r\n",
+		},
+		{
+			name:     "append_crlf",
+			data:     "bar\r\nbaz",
+			contents: "foo",
+			want:     "bar\r\nbaz\r\n# begin\r\nfoo\r\n# end\r\n",
+		},
+		{
+			name:     "unchanged",
+			data:     "bar\n# begin\nfoo\n# end\nqux\n",
+			contents: "foo\n",
+			want:     "bar\n# begin\nfoo\n# end\nqux\n",
+		},
+		{
+			name:     "remove",
+			data:     "bar\n# begin\nfoo\n# end\nqux\n",
+			contents: "",
+			want:     "bar\nqux\n",
+		},
+		{
+			name:     "remove_missing",
+			data:     "bar\n",
+			contents: "",
+			want:     "bar\n",
+		},
+		{
+			name:     "missing_end",
+			data:     "bar\n# begin\nfoo\n",
+			contents: "foo\n",
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := replaceBlock([]byte(tc.data), "# begin", "# end", []byte(tc.contents))
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, string(got))
+			}
+		})
+	}
+}
+
+func TestBlockApplyRecordsState(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi": &vfst.Dir{Perm: 0o755},
+		"/home/user/.bashrc":         "# contents of .bashrc\n",
+		"/src/block_dot_bashrc":      "# block\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithSourceDir("/src"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", vfst.DefaultUmask, nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+	require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, &ApplyOptions{
+		DestDir:         ts.DestDir,
+		FileStateBucket: []byte("file"),
+		Ignore:          ts.TargetIgnore.Match,
+		PersistentState: persistentState,
+	}))
+
+	contents, err := fs.ReadFile("/home/user/.bashrc")
+	require.NoError(t, err)
+	fileStateData, err := persistentState.Get([]byte("file"), []byte("/home/user/.bashrc"))
+	require.NoError(t, err)
+	var fileState FileState
+	require.NoError(t, json.Unmarshal(fileStateData, &fileState))
+	assert.Equal(t, hashString(contents), fileState.ContentsSHA256)
+}
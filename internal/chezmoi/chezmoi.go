@@ -14,12 +14,20 @@ import (
 
 // Suffixes and prefixes.
 const (
+	afterPrefix      = "after_"
+	beforePrefix     = "before_"
+	blockPrefix      = "block_"
+	createPrefix     = "create_"
 	dotPrefix        = "dot_"
 	emptyPrefix      = "empty_"
 	encryptedPrefix  = "encrypted_"
 	exactPrefix      = "exact_"
 	executablePrefix = "executable_"
+	linkPrefix       = "link_"
+	mergePrefix      = "merge_"
+	modifyPrefix     = "modify_"
 	oncePrefix       = "once_"
+	onChangePrefix   = "onchange_"
 	privatePrefix    = "private_"
 	runPrefix        = "run_"
 	symlinkPrefix    = "symlink_"
@@ -34,32 +42,70 @@ type PersistentState interface {
 	Set(bucket, key, value []byte) error
 }
 
+// A ConfirmFunc is called before the target at targetPath is changed. preview
+// makes the change using mutator, in dry run mode, so that it can be shown
+// before the target is changed. It returns true if the change should be made.
+type ConfirmFunc func(targetPath string, preview func(mutator Mutator) error) (bool, error)
+
+// A ConflictFunc is called when targetPath, the target of file, has been
+// changed since chezmoi last wrote it and does not match the target state. It
+// returns true if targetPath should be overwritten.
+type ConflictFunc func(file *File, targetPath string) (bool, error)
+
 // An ApplyOptions is a big ball of mud for things that affect Entry.Apply.
 type ApplyOptions struct {
+	Confirm           ConfirmFunc
+	Conflict          ConflictFunc
 	DestDir           string
 	DryRun            bool
+	FileStateBucket   []byte
 	Ignore            func(string) bool
 	PersistentState   PersistentState
 	Remove            bool
 	ScriptStateBucket []byte
+	SourcePath        func(Entry) string
 	Stdout            io.Writer
 	Umask             os.FileMode
 	Verbose           bool
+	previewEntry      Entry
+	skipPhaseScripts  bool
 }
 
-// An Entry is either a Dir, a File, or a Symlink.
+// confirm returns true if entry, whose target is targetPath, should be
+// applied. The preview passed to o.Confirm only applies entry itself and not
+// any entries that it contains, as they are confirmed separately.
+func (o *ApplyOptions) confirm(fs vfs.FS, entry Entry, targetPath string, follow bool) (bool, error) {
+	switch {
+	case o.previewEntry != nil:
+		return entry == o.previewEntry, nil
+	case o.Confirm == nil:
+		return true, nil
+	}
+	return o.Confirm(targetPath, func(mutator Mutator) error {
+		previewOptions := *o
+		previewOptions.Confirm = nil
+		previewOptions.Conflict = nil
+		previewOptions.DryRun = true
+		previewOptions.Verbose = true // Print the contents of scripts.
+		previewOptions.previewEntry = entry
+		return entry.Apply(fs, mutator, follow, &previewOptions)
+	})
+}
+
+// An Entry is either a Block, a Dir, a File, a Script, or a Symlink.
 type Entry interface {
 	AppendAllEntries(allEntries []Entry) []Entry
 	Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error
-	ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error)
+	ConcreteValue(ignore func(string) bool, sourcePaths func(Entry) []string, umask os.FileMode, recursive bool) (interface{}, error)
 	Evaluate(ignore func(string) bool) error
 	SourceName() string
 	TargetName() string
-	archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error
+	archive(w ArchiveWriter, ignore func(string) bool, include EntryTypeSet, headerTemplate *tar.Header, umask os.FileMode, warn func(string)) error
 }
 
 type parsedSourceFilePath struct {
 	dirAttributes    []DirAttributes
+	blockAttributes  *BlockAttributes
 	fileAttributes   *FileAttributes
 	scriptAttributes *ScriptAttributes
 }
@@ -100,6 +146,13 @@ func parseSourceFilePath(path string) parsedSourceFilePath {
 			scriptAttributes: &sa,
 		}
 	}
+	if strings.HasPrefix(sourceName, blockPrefix) {
+		ba := ParseBlockAttributes(sourceName)
+		return parsedSourceFilePath{
+			dirAttributes:   das,
+			blockAttributes: &ba,
+		}
+	}
 	fa := ParseFileAttributes(components[len(components)-1])
 	return parsedSourceFilePath{
 		dirAttributes:  das,
@@ -123,3 +176,27 @@ func splitPathList(path string) []string {
 	}
 	return strings.Split(path, string(filepath.Separator))
 }
+
+// walk is like vfs.Walk, except that it also stops at and returns errors
+// returned by walkFn for directories, which vfs.Walk ignores.
+func walk(fs vfs.FS, root string, walkFn filepath.WalkFunc) error {
+	var dirErr error
+	if err := vfs.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		isDir := info != nil && info.IsDir()
+		if dirErr != nil {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return dirErr
+		}
+		err = walkFn(path, info, err)
+		if err != nil && err != filepath.SkipDir && isDir {
+			dirErr = err
+			return filepath.SkipDir
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+	return dirErr
+}
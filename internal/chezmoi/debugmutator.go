@@ -38,6 +38,13 @@ func (m *DebugMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	return output, err
 }
 
+// Lchown implements Mutator.Lchown.
+func (m *DebugMutator) Lchown(name string, uid, gid int) error {
+	return Debugf("Lchown(%q, %d, %d)", []interface{}{name, uid, gid}, func() error {
+		return m.m.Lchown(name, uid, gid)
+	})
+}
+
 // Mkdir implements Mutator.Mkdir.
 func (m *DebugMutator) Mkdir(name string, perm os.FileMode) error {
 	return Debugf("Mkdir(%q, 0%o)", []interface{}{name, perm}, func() error {
@@ -67,6 +74,13 @@ func (m *DebugMutator) RunCmd(cmd *exec.Cmd) error {
 	})
 }
 
+// RunScript implements Mutator.RunScript.
+func (m *DebugMutator) RunScript(name, dir string, data []byte) error {
+	return Debugf("RunScript(%q, %q, _)", []interface{}{name, dir}, func() error {
+		return m.m.RunScript(name, dir, data)
+	})
+}
+
 // Stat implements Mutator.Stat.
 func (m *DebugMutator) Stat(name string) (os.FileInfo, error) {
 	var fi os.FileInfo
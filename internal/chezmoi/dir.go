@@ -22,6 +22,7 @@ type Dir struct {
 	targetName string
 	Exact      bool
 	Perm       os.FileMode
+	Ownership  Ownership
 	Entries    map[string]Entry
 }
 
@@ -32,6 +33,9 @@ type dirConcreteValue struct {
 	Exact      bool          `json:"exact" yaml:"exact"`
 	Perm       int           `json:"perm" yaml:"perm"`
 	Entries    []interface{} `json:"entries" yaml:"entries"`
+	Owner      string        `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group      string        `json:"group,omitempty" yaml:"group,omitempty"`
+	Overrides  []string      `json:"overrides,omitempty" yaml:"overrides,omitempty"`
 }
 
 // ParseDirAttributes parses a single directory name.
@@ -96,18 +100,30 @@ func (d *Dir) AppendAllEntries(allEntries []Entry) []Entry {
 
 // Apply ensures that destDir in fs matches d.
 func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
-	if applyOptions.Ignore(d.targetName) {
+	if applyOptions.Ignore(AsDir(d.targetName)) {
 		return nil
 	}
 	targetPath := filepath.Join(applyOptions.DestDir, d.targetName)
+	ok, err := applyOptions.confirm(fs, d, targetPath, follow)
+	if err != nil {
+		return err
+	}
 	var info os.FileInfo
-	var err error
 	if follow {
 		info, err = fs.Stat(targetPath)
 	} else {
 		info, err = fs.Lstat(targetPath)
 	}
 	switch {
+	case !ok:
+		// If d is not confirmed then its entries are still applied, but only
+		// if the directory that contains them already exists.
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err != nil || !info.IsDir() {
+			return nil
+		}
 	case err == nil && info.IsDir():
 		if info.Mode().Perm() != d.Perm&^applyOptions.Umask {
 			if err := mutator.Chmod(targetPath, d.Perm&^applyOptions.Umask); err != nil {
@@ -115,7 +131,7 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 			}
 		}
 	case err == nil:
-		if err := mutator.RemoveAll(targetPath); err != nil {
+		if err := removeTarget(fs, mutator, targetPath, applyOptions); err != nil {
 			return err
 		}
 		fallthrough
@@ -126,23 +142,36 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 	default:
 		return err
 	}
+	if ok {
+		if err := applyOwnership(fs, mutator, targetPath, d.Ownership); err != nil {
+			return err
+		}
+	}
 	for _, entryName := range sortedEntryNames(d.Entries) {
 		if err := d.Entries[entryName].Apply(fs, mutator, follow, applyOptions); err != nil {
 			return err
 		}
 	}
-	if d.Exact {
+	if ok && d.Exact {
 		infos, err := fs.ReadDir(targetPath)
-		if err != nil {
+		switch {
+		case os.IsNotExist(err) && applyOptions.DryRun:
+			// targetPath was not created because this is a dry run, so there
+			// is nothing to remove.
+		case err != nil:
 			return err
 		}
 		for _, info := range infos {
 			name := info.Name()
 			if _, ok := d.Entries[name]; !ok {
-				if applyOptions.Ignore(filepath.Join(d.targetName, name)) {
+				targetName := filepath.Join(d.targetName, name)
+				if info.IsDir() {
+					targetName = AsDir(targetName)
+				}
+				if applyOptions.Ignore(targetName) {
 					continue
 				}
-				if err := mutator.RemoveAll(filepath.Join(targetPath, name)); err != nil {
+				if err := removeTarget(fs, mutator, filepath.Join(targetPath, name), applyOptions); err != nil {
 					return err
 				}
 			}
@@ -152,14 +181,14 @@ func (d *Dir) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Apply
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
-	if ignore(d.targetName) {
+func (d *Dir) ConcreteValue(ignore func(string) bool, sourcePaths func(Entry) []string, umask os.FileMode, recursive bool) (interface{}, error) {
+	if ignore(AsDir(d.targetName)) {
 		return nil, nil
 	}
 	var entryConcreteValues []interface{}
 	if recursive {
 		for _, entryName := range sortedEntryNames(d.Entries) {
-			entryConcreteValue, err := d.Entries[entryName].ConcreteValue(ignore, sourceDir, umask, recursive)
+			entryConcreteValue, err := d.Entries[entryName].ConcreteValue(ignore, sourcePaths, umask, recursive)
 			if err != nil {
 				return nil, err
 			}
@@ -168,19 +197,23 @@ func (d *Dir) ConcreteValue(ignore func(string) bool, sourceDir string, umask os
 			}
 		}
 	}
+	paths := sourcePaths(d)
 	return &dirConcreteValue{
 		Type:       "dir",
-		SourcePath: filepath.Join(sourceDir, d.SourceName()),
+		SourcePath: paths[0],
 		TargetPath: d.TargetName(),
 		Exact:      d.Exact,
 		Perm:       int(d.Perm &^ umask),
 		Entries:    entryConcreteValues,
+		Owner:      d.Ownership.Owner,
+		Group:      d.Ownership.Group,
+		Overrides:  paths[1:],
 	}, nil
 }
 
 // Evaluate evaluates all entries in d.
 func (d *Dir) Evaluate(ignore func(string) bool) error {
-	if ignore(d.targetName) {
+	if ignore(AsDir(d.targetName)) {
 		return nil
 	}
 	for _, entryName := range sortedEntryNames(d.Entries) {
@@ -206,20 +239,23 @@ func (d *Dir) TargetName() string {
 	return d.targetName
 }
 
-// archive writes d to w.
-func (d *Dir) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
-	if ignore(d.targetName) {
+// archive writes d to w. The entries in d are written even if dirs are not
+// included.
+func (d *Dir) archive(w ArchiveWriter, ignore func(string) bool, include EntryTypeSet, headerTemplate *tar.Header, umask os.FileMode, warn func(string)) error {
+	if ignore(AsDir(d.targetName)) {
 		return nil
 	}
-	header := *headerTemplate
-	header.Typeflag = tar.TypeDir
-	header.Name = d.targetName
-	header.Mode = int64(d.Perm &^ umask)
-	if err := w.WriteHeader(&header); err != nil {
-		return err
+	if include.Has(EntryTypeDirs) {
+		header := *headerTemplate
+		header.Typeflag = tar.TypeDir
+		header.Name = d.targetName
+		header.Mode = int64(d.Perm &^ umask)
+		if err := w.WriteHeader(&header); err != nil {
+			return err
+		}
 	}
 	for _, entryName := range sortedEntryNames(d.Entries) {
-		if err := d.Entries[entryName].archive(w, ignore, headerTemplate, umask); err != nil {
+		if err := d.Entries[entryName].archive(w, ignore, include, headerTemplate, umask, warn); err != nil {
 			return err
 		}
 	}
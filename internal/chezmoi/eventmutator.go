@@ -0,0 +1,299 @@
+package chezmoi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Event operations.
+const (
+	EventOpChmod               = "chmod"
+	EventOpIdempotentCmdOutput = "idempotentCmdOutput"
+	EventOpLchown              = "lchown"
+	EventOpMkdir               = "mkdir"
+	EventOpRemoveAll           = "removeAll"
+	EventOpRename              = "rename"
+	EventOpRunCmd              = "runCmd"
+	EventOpScriptFinish        = "scriptFinish"
+	EventOpScriptStart         = "scriptStart"
+	EventOpWriteFile           = "writeFile"
+	EventOpWriteSymlink        = "writeSymlink"
+)
+
+// An Event records a single call to a Mutator. Modes are formatted with
+// os.FileMode.String and hashes are the hex-encoded SHA256 sums of the
+// contents of files or the targets of symlinks. Fields that do not apply to Op
+// are omitted. DryRun is set if the call was not performed, in which case
+// ExitStatus is omitted.
+type Event struct {
+	Op         string        `json:"op"`
+	Path       string        `json:"path,omitempty"`
+	NewPath    string        `json:"newPath,omitempty"`
+	Cmd        string        `json:"cmd,omitempty"`
+	OldMode    string        `json:"oldMode,omitempty"`
+	NewMode    string        `json:"newMode,omitempty"`
+	OldSHA256  string        `json:"oldSHA256,omitempty"`
+	NewSHA256  string        `json:"newSHA256,omitempty"`
+	UID        *int          `json:"uid,omitempty"`
+	GID        *int          `json:"gid,omitempty"`
+	ExitStatus *int          `json:"exitStatus,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+	DryRun     bool          `json:"dryRun,omitempty"`
+}
+
+// An EventMutator wraps a Mutator and writes an Event for each call to its
+// methods, except Stat, to w as a line of JSON. fs is used to determine the
+// modes and hashes of targets before and after each call. If dryRun is true
+// then m does not perform calls and each Event is marked as such.
+type EventMutator struct {
+	dryRun bool
+	fs     vfs.FS
+	m      Mutator
+	w      io.Writer
+}
+
+// NewEventMutator returns a new EventMutator.
+func NewEventMutator(fs vfs.FS, w io.Writer, m Mutator, dryRun bool) *EventMutator {
+	return &EventMutator{
+		dryRun: dryRun,
+		fs:     fs,
+		m:      m,
+		w:      w,
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *EventMutator) Chmod(name string, mode os.FileMode) error {
+	event := &Event{
+		Op:   EventOpChmod,
+		Path: name,
+	}
+	event.OldMode, event.OldSHA256 = m.modeAndHash(name)
+	return m.record(event, func() error {
+		err := m.m.Chmod(name, mode)
+		event.NewMode, event.NewSHA256 = m.modeAndHash(name)
+		return err
+	})
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *EventMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	event := &Event{
+		Op:  EventOpIdempotentCmdOutput,
+		Cmd: cmdString(cmd),
+	}
+	var output []byte
+	err := m.record(event, func() error {
+		var err error
+		output, err = m.m.IdempotentCmdOutput(cmd)
+		event.ExitStatus = exitStatus(err)
+		return err
+	})
+	return output, err
+}
+
+// Lchown implements Mutator.Lchown.
+func (m *EventMutator) Lchown(name string, uid, gid int) error {
+	event := &Event{
+		Op:   EventOpLchown,
+		Path: name,
+		UID:  &uid,
+		GID:  &gid,
+	}
+	return m.record(event, func() error {
+		return m.m.Lchown(name, uid, gid)
+	})
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *EventMutator) Mkdir(name string, perm os.FileMode) error {
+	event := &Event{
+		Op:   EventOpMkdir,
+		Path: name,
+	}
+	event.OldMode, event.OldSHA256 = m.modeAndHash(name)
+	return m.record(event, func() error {
+		err := m.m.Mkdir(name, perm)
+		event.NewMode, _ = m.modeAndHash(name)
+		return err
+	})
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *EventMutator) RemoveAll(name string) error {
+	event := &Event{
+		Op:   EventOpRemoveAll,
+		Path: name,
+	}
+	event.OldMode, event.OldSHA256 = m.modeAndHash(name)
+	return m.record(event, func() error {
+		return m.m.RemoveAll(name)
+	})
+}
+
+// Rename implements Mutator.Rename.
+func (m *EventMutator) Rename(oldpath, newpath string) error {
+	event := &Event{
+		Op:      EventOpRename,
+		Path:    oldpath,
+		NewPath: newpath,
+	}
+	event.OldMode, event.OldSHA256 = m.modeAndHash(oldpath)
+	return m.record(event, func() error {
+		err := m.m.Rename(oldpath, newpath)
+		event.NewMode, event.NewSHA256 = m.modeAndHash(newpath)
+		return err
+	})
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *EventMutator) RunCmd(cmd *exec.Cmd) error {
+	event := &Event{
+		Op:  EventOpRunCmd,
+		Cmd: cmdString(cmd),
+	}
+	return m.record(event, func() error {
+		err := m.m.RunCmd(cmd)
+		if !m.dryRun {
+			event.ExitStatus = exitStatus(err)
+		}
+		return err
+	})
+}
+
+// RunScript implements Mutator.RunScript. An event is written both when the
+// script starts and when it finishes.
+func (m *EventMutator) RunScript(name, dir string, data []byte) error {
+	newSHA256 := hashString(data)
+	if err := m.write(&Event{
+		Op:        EventOpScriptStart,
+		Path:      name,
+		NewSHA256: newSHA256,
+	}); err != nil {
+		return err
+	}
+	event := &Event{
+		Op:        EventOpScriptFinish,
+		Path:      name,
+		NewSHA256: newSHA256,
+	}
+	return m.record(event, func() error {
+		err := m.m.RunScript(name, dir, data)
+		if !m.dryRun {
+			event.ExitStatus = exitStatus(err)
+		}
+		return err
+	})
+}
+
+// Stat implements Mutator.Stat.
+func (m *EventMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *EventMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	event := &Event{
+		Op:        EventOpWriteFile,
+		Path:      name,
+		NewMode:   perm.String(),
+		NewSHA256: hashString(data),
+	}
+	event.OldMode, event.OldSHA256 = m.modeAndHash(name)
+	return m.record(event, func() error {
+		return m.m.WriteFile(name, data, perm, currData)
+	})
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *EventMutator) WriteSymlink(oldname, newname string) error {
+	event := &Event{
+		Op:        EventOpWriteSymlink,
+		Path:      newname,
+		NewMode:   (os.ModeSymlink | 0o777).String(),
+		NewSHA256: hashString([]byte(oldname)),
+	}
+	event.OldMode, event.OldSHA256 = m.modeAndHash(newname)
+	return m.record(event, func() error {
+		return m.m.WriteSymlink(oldname, newname)
+	})
+}
+
+// modeAndHash returns the mode and hash of name, or empty strings if they
+// cannot be determined.
+func (m *EventMutator) modeAndHash(name string) (string, string) {
+	info, err := m.fs.Lstat(name)
+	if err != nil {
+		return "", ""
+	}
+	switch {
+	case info.Mode().IsRegular():
+		data, err := m.fs.ReadFile(name)
+		if err != nil {
+			return info.Mode().String(), ""
+		}
+		return info.Mode().String(), hashString(data)
+	case info.Mode()&os.ModeType == os.ModeSymlink:
+		linkname, err := m.fs.Readlink(name)
+		if err != nil {
+			return info.Mode().String(), ""
+		}
+		return info.Mode().String(), hashString([]byte(linkname))
+	default:
+		return info.Mode().String(), ""
+	}
+}
+
+// record calls f, sets the duration and error of event, and writes event. The
+// error returned by f takes precedence over any error writing event.
+func (m *EventMutator) record(event *Event, f func() error) error {
+	start := time.Now()
+	err := f()
+	event.Duration = time.Since(start)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if writeErr := m.write(event); err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+// write writes event to m.w as a single line of JSON.
+func (m *EventMutator) write(event *Event) error {
+	event.DryRun = m.dryRun
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = m.w.Write(append(data, '\n'))
+	return err
+}
+
+// exitStatus returns the exit status corresponding to err, or nil if it is
+// not known.
+func exitStatus(err error) *int {
+	var exitStatus int
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil
+		}
+		exitStatus = exitErr.ExitCode()
+	}
+	return &exitStatus
+}
+
+// hashString returns the hex-encoded SHA256 sum of data.
+func hashString(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
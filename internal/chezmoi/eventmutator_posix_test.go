@@ -0,0 +1,143 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var _ Mutator = &EventMutator{}
+
+func TestEventMutator(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.bashrc": "# contents of .bashrc\n",
+		"/home/user/.profile": &vfst.File{
+			Perm:     0o644,
+			Contents: []byte("# contents of .profile\n"),
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	intPtr := func(i int) *int {
+		return &i
+	}
+
+	w := &bytes.Buffer{}
+	m := NewEventMutator(fs, w, NewFSMutator(fs), false)
+	assert.NoError(t, m.Chmod("/home/user/.profile", 0o600))
+	assert.NoError(t, m.WriteFile("/home/user/.profile", []byte("# new contents of .profile\n"), 0o600, []byte("# contents of .profile\n")))
+	assert.NoError(t, m.WriteSymlink(".profile", "/home/user/.link"))
+	assert.NoError(t, m.RemoveAll("/home/user/.bashrc"))
+	assert.NoError(t, m.RunScript("/home/user/success", "/", []byte("#!/bin/sh\n")))
+	assert.Error(t, m.RunScript("/home/user/failure", "/", []byte("#!/bin/sh\nexit 3\n")))
+
+	var events []*Event
+	s := bufio.NewScanner(w)
+	for s.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(s.Bytes(), &event))
+		assert.True(t, event.Duration >= 0)
+		event.Duration = 0
+		events = append(events, &event)
+	}
+	require.NoError(t, s.Err())
+
+	assert.Equal(t, []*Event{
+		{
+			Op:        EventOpChmod,
+			Path:      "/home/user/.profile",
+			OldMode:   "-rw-r--r--",
+			NewMode:   "-rw-------",
+			OldSHA256: hashString([]byte("# contents of .profile\n")),
+			NewSHA256: hashString([]byte("# contents of .profile\n")),
+		},
+		{
+			Op:        EventOpWriteFile,
+			Path:      "/home/user/.profile",
+			OldMode:   "-rw-------",
+			NewMode:   "-rw-------",
+			OldSHA256: hashString([]byte("# contents of .profile\n")),
+			NewSHA256: hashString([]byte("# new contents of .profile\n")),
+		},
+		{
+			Op:        EventOpWriteSymlink,
+			Path:      "/home/user/.link",
+			NewMode:   "Lrwxrwxrwx",
+			NewSHA256: hashString([]byte(".profile")),
+		},
+		{
+			Op:        EventOpRemoveAll,
+			Path:      "/home/user/.bashrc",
+			OldMode:   "-rw-r--r--",
+			OldSHA256: hashString([]byte("# contents of .bashrc\n")),
+		},
+		{
+			Op:        EventOpScriptStart,
+			Path:      "/home/user/success",
+			NewSHA256: hashString([]byte("#!/bin/sh\n")),
+		},
+		{
+			Op:         EventOpScriptFinish,
+			Path:       "/home/user/success",
+			NewSHA256:  hashString([]byte("#!/bin/sh\n")),
+			ExitStatus: intPtr(0),
+		},
+		{
+			Op:        EventOpScriptStart,
+			Path:      "/home/user/failure",
+			NewSHA256: hashString([]byte("#!/bin/sh\nexit 3\n")),
+		},
+		{
+			Op:         EventOpScriptFinish,
+			Path:       "/home/user/failure",
+			NewSHA256:  hashString([]byte("#!/bin/sh\nexit 3\n")),
+			ExitStatus: intPtr(3),
+			Error:      "exit status 3",
+		},
+	}, events)
+}
+
+func TestEventMutatorDryRun(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user": &vfst.Dir{Perm: 0o755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	w := &bytes.Buffer{}
+	m := NewEventMutator(fs, w, NullMutator{}, true)
+	assert.NoError(t, m.RunScript("/home/user/failure", "/", []byte("#!/bin/sh\nexit 3\n")))
+
+	var events []*Event
+	s := bufio.NewScanner(w)
+	for s.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(s.Bytes(), &event))
+		event.Duration = 0
+		events = append(events, &event)
+	}
+	require.NoError(t, s.Err())
+
+	assert.Equal(t, []*Event{
+		{
+			Op:        EventOpScriptStart,
+			Path:      "/home/user/failure",
+			NewSHA256: hashString([]byte("#!/bin/sh\nexit 3\n")),
+			DryRun:    true,
+		},
+		{
+			Op:        EventOpScriptFinish,
+			Path:      "/home/user/failure",
+			NewSHA256: hashString([]byte("#!/bin/sh\nexit 3\n")),
+			DryRun:    true,
+		},
+	}, events)
+}
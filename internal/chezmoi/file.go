@@ -3,9 +3,12 @@ package chezmoi
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	vfs "github.com/twpayne/go-vfs"
@@ -15,39 +18,66 @@ import (
 type FileAttributes struct {
 	Name      string
 	Mode      os.FileMode
+	Create    bool
+	Modify    bool
+	Merge     bool
+	Link      bool
 	Empty     bool
 	Encrypted bool
 	Template  bool
 }
 
+// A FileState represents the state of a file last written by chezmoi.
+type FileState struct {
+	ContentsSHA256 string `json:"contentsSHA256"`
+}
+
 // A File represents the target state of a file.
 type File struct {
 	sourceName       string
 	targetName       string
+	Create           bool
+	Modify           bool
+	Merge            bool
+	Link             bool
 	Empty            bool
 	Encrypted        bool
 	Perm             os.FileMode
 	Template         bool
+	Ownership        Ownership
 	contents         []byte
 	contentsErr      error
 	evaluateContents func() ([]byte, error)
+	format           *Format
+	modifyOutputs    map[string][]byte // modifyOutputs maps target paths to the output of f's modify script.
 }
 
 type fileConcreteValue struct {
-	Type       string `json:"type" yaml:"type"`
-	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
-	TargetPath string `json:"targetPath" yaml:"targetPath"`
-	Empty      bool   `json:"empty" yaml:"empty"`
-	Encrypted  bool   `json:"encrypted" yaml:"encrypted"`
-	Perm       int    `json:"perm" yaml:"perm"`
-	Template   bool   `json:"template" yaml:"template"`
-	Contents   string `json:"contents" yaml:"contents"`
+	Type       string   `json:"type" yaml:"type"`
+	SourcePath string   `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath string   `json:"targetPath" yaml:"targetPath"`
+	Create     bool     `json:"create" yaml:"create"`
+	Modify     bool     `json:"modify" yaml:"modify"`
+	Merge      bool     `json:"merge" yaml:"merge"`
+	Link       bool     `json:"link" yaml:"link"`
+	Empty      bool     `json:"empty" yaml:"empty"`
+	Encrypted  bool     `json:"encrypted" yaml:"encrypted"`
+	Perm       int      `json:"perm" yaml:"perm"`
+	Template   bool     `json:"template" yaml:"template"`
+	Contents   string   `json:"contents" yaml:"contents"`
+	Owner      string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group      string   `json:"group,omitempty" yaml:"group,omitempty"`
+	Overrides  []string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
 }
 
 // ParseFileAttributes parses a source file name.
 func ParseFileAttributes(sourceName string) FileAttributes {
 	name := sourceName
 	mode := os.FileMode(0666)
+	create := false
+	modify := false
+	merge := false
+	link := false
 	empty := false
 	encrypted := false
 	template := false
@@ -56,6 +86,20 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 		mode |= os.ModeSymlink
 	} else {
 		private := false
+		switch {
+		case strings.HasPrefix(name, createPrefix):
+			name = strings.TrimPrefix(name, createPrefix)
+			create = true
+		case strings.HasPrefix(name, modifyPrefix):
+			name = strings.TrimPrefix(name, modifyPrefix)
+			modify = true
+		case strings.HasPrefix(name, mergePrefix):
+			name = strings.TrimPrefix(name, mergePrefix)
+			merge = true
+		case strings.HasPrefix(name, linkPrefix):
+			name = strings.TrimPrefix(name, linkPrefix)
+			link = true
+		}
 		if strings.HasPrefix(name, encryptedPrefix) {
 			name = strings.TrimPrefix(name, encryptedPrefix)
 			encrypted = true
@@ -86,6 +130,10 @@ func ParseFileAttributes(sourceName string) FileAttributes {
 	return FileAttributes{
 		Name:      name,
 		Mode:      mode,
+		Create:    create,
+		Modify:    modify,
+		Merge:     merge,
+		Link:      link,
 		Empty:     empty,
 		Encrypted: encrypted,
 		Template:  template,
@@ -97,6 +145,16 @@ func (fa FileAttributes) SourceName() string {
 	sourceName := ""
 	switch fa.Mode & os.ModeType {
 	case 0:
+		switch {
+		case fa.Create:
+			sourceName += createPrefix
+		case fa.Modify:
+			sourceName += modifyPrefix
+		case fa.Merge:
+			sourceName += mergePrefix
+		case fa.Link:
+			sourceName += linkPrefix
+		}
 		if fa.Encrypted {
 			sourceName += encryptedPrefix
 		}
@@ -135,11 +193,17 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	if applyOptions.Ignore(f.targetName) {
 		return nil
 	}
-	contents, err := f.Contents()
-	if err != nil {
+	if ok, err := applyOptions.confirm(fs, f, filepath.Join(applyOptions.DestDir, f.targetName), follow); err != nil || !ok {
 		return err
 	}
 	targetPath := filepath.Join(applyOptions.DestDir, f.targetName)
+	contents, err := f.TargetContents(fs, mutator, targetPath)
+	if err != nil {
+		return err
+	}
+	if f.linkable() && !isEmpty(contents) {
+		return f.applyLink(fs, mutator, targetPath, follow, applyOptions)
+	}
 	var info os.FileInfo
 	if follow {
 		info, err = fs.Stat(targetPath)
@@ -148,14 +212,23 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	}
 	var currData []byte
 	switch {
+	case err == nil && f.Create:
+		// Files with the create attribute are only written if they do not
+		// already exist.
+		return applyOwnership(fs, mutator, targetPath, f.Ownership)
 	case err == nil && info.Mode().IsRegular():
-		if isEmpty(contents) && !f.Empty {
-			return mutator.RemoveAll(targetPath)
-		}
 		currData, err = fs.ReadFile(targetPath)
 		if err != nil {
 			return err
 		}
+		if !bytes.Equal(currData, contents) {
+			if ok, err := f.checkConflict(targetPath, currData, applyOptions); err != nil || !ok {
+				return err
+			}
+		}
+		if isEmpty(contents) && !f.Empty {
+			return removeTarget(fs, mutator, targetPath, applyOptions)
+		}
 		if !bytes.Equal(currData, contents) {
 			break
 		}
@@ -164,9 +237,12 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 				return err
 			}
 		}
-		return nil
+		if err := applyOptions.recordFileState(targetPath, contents); err != nil {
+			return err
+		}
+		return applyOwnership(fs, mutator, targetPath, f.Ownership)
 	case err == nil:
-		if err := mutator.RemoveAll(targetPath); err != nil {
+		if err := removeTarget(fs, mutator, targetPath, applyOptions); err != nil {
 			return err
 		}
 	case os.IsNotExist(err):
@@ -176,11 +252,17 @@ func (f *File) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Appl
 	if isEmpty(contents) && !f.Empty {
 		return nil
 	}
-	return mutator.WriteFile(targetPath, contents, f.Perm&^applyOptions.Umask, currData)
+	if err := mutator.WriteFile(targetPath, contents, f.Perm&^applyOptions.Umask, currData); err != nil {
+		return err
+	}
+	if err := applyOptions.recordFileState(targetPath, contents); err != nil {
+		return err
+	}
+	return applyOwnership(fs, mutator, targetPath, f.Ownership)
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (f *File) ConcreteValue(ignore func(string) bool, sourcePaths func(Entry) []string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(f.targetName) {
 		return nil, nil
 	}
@@ -188,15 +270,23 @@ func (f *File) ConcreteValue(ignore func(string) bool, sourceDir string, umask o
 	if err != nil {
 		return nil, err
 	}
+	paths := sourcePaths(f)
 	return &fileConcreteValue{
 		Type:       "file",
-		SourcePath: filepath.Join(sourceDir, f.SourceName()),
+		SourcePath: paths[0],
 		TargetPath: f.TargetName(),
+		Create:     f.Create,
+		Modify:     f.Modify,
+		Merge:      f.Merge,
+		Link:       f.Link,
 		Empty:      f.Empty,
 		Encrypted:  f.Encrypted,
 		Perm:       int(f.Perm &^ umask),
 		Template:   f.Template,
 		Contents:   string(contents),
+		Owner:      f.Ownership.Owner,
+		Group:      f.Ownership.Group,
+		Overrides:  paths[1:],
 	}, nil
 }
 
@@ -209,6 +299,52 @@ func (f *File) Contents() ([]byte, error) {
 	return f.contents, f.contentsErr
 }
 
+// TargetContents returns the contents that f should have at targetPath in fs.
+// If f has the modify attribute then f's contents are run by mutator as a
+// script with the current contents of targetPath on stdin, and its stdout is
+// returned. The output is cached so that the script is run at most once for
+// each targetPath. If f has the merge attribute then f's contents are
+// deep-merged into the current contents of targetPath.
+func (f *File) TargetContents(fs vfs.FS, mutator Mutator, targetPath string) ([]byte, error) {
+	contents, err := f.Contents()
+	if err != nil || !f.Modify && !f.Merge {
+		return contents, err
+	}
+	if output, ok := f.modifyOutputs[targetPath]; ok {
+		return output, nil
+	}
+	currContents, err := fs.ReadFile(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// As with scripts, an empty modify script or merge document does nothing.
+	if isEmpty(contents) {
+		return currContents, nil
+	}
+	if f.Merge {
+		return f.merge(currContents, contents)
+	}
+	// A modify script only computes the new contents of targetPath, so it is
+	// run as an idempotent command, which mutators run even in a dry run.
+	cmd, cleanup, err := scriptCmd(fs, f.targetName, contents, filepath.Dir(targetPath))
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cmd.Stdin = bytes.NewReader(currContents)
+	output, err := mutator.IdempotentCmdOutput(cmd)
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) != 0 {
+		return nil, fmt.Errorf("%s: %w: %s", f.targetName, err, bytes.TrimSpace(exitErr.Stderr))
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.targetName, err)
+	}
+	if f.modifyOutputs == nil {
+		f.modifyOutputs = make(map[string][]byte)
+	}
+	f.modifyOutputs[targetPath] = output
+	return output, nil
+}
+
 // Evaluate evaluates f's contents.
 func (f *File) Evaluate(ignore func(string) bool) error {
 	if ignore(f.targetName) {
@@ -223,6 +359,12 @@ func (f *File) Executable() bool {
 	return f.Perm&0111 != 0
 }
 
+// Linkname returns the linkname of the symlink from targetPath to f's source
+// file at sourcePath.
+func (f *File) Linkname(sourcePath, targetPath string) (string, error) {
+	return filepath.Rel(filepath.Dir(targetPath), sourcePath)
+}
+
 // Private returns true if f is private.
 func (f *File) Private() bool {
 	return f.Perm&077 == 0
@@ -238,9 +380,193 @@ func (f *File) TargetName() string {
 	return f.targetName
 }
 
+// applyLink ensures that targetPath in fs is a symlink to f's source file. If
+// follow is true and targetPath is a symlink to a regular file then, as in
+// Apply, the symlink is followed and the file that it points to is linked
+// instead.
+func (f *File) applyLink(fs vfs.FS, mutator Mutator, targetPath string, follow bool, applyOptions *ApplyOptions) error {
+	sourcePath := applyOptions.SourcePath(f)
+	linkname, err := f.Linkname(sourcePath, targetPath)
+	if err != nil {
+		return err
+	}
+	info, err := fs.Lstat(targetPath)
+	switch {
+	case err == nil && info.Mode()&os.ModeType == os.ModeSymlink:
+		currLinkname, err := fs.Readlink(targetPath)
+		if err != nil {
+			return err
+		}
+		if currLinkname == linkname {
+			return applyOwnership(fs, mutator, targetPath, f.Ownership)
+		}
+		if !follow {
+			break
+		}
+		if info, err := fs.Stat(targetPath); err != nil || !info.Mode().IsRegular() {
+			break
+		}
+		if !filepath.IsAbs(currLinkname) {
+			currLinkname = filepath.Join(filepath.Dir(targetPath), currLinkname)
+		}
+		// Never replace the source file with a symlink to itself.
+		if filepath.Clean(currLinkname) == filepath.Clean(sourcePath) {
+			return applyOwnership(fs, mutator, targetPath, f.Ownership)
+		}
+		return f.applyLink(fs, mutator, currLinkname, follow, applyOptions)
+	case err == nil:
+	case os.IsNotExist(err):
+	default:
+		return err
+	}
+	if err := mutator.WriteSymlink(linkname, targetPath); err != nil {
+		return err
+	}
+	return applyOwnership(fs, mutator, targetPath, f.Ownership)
+}
+
+// linkable returns true if f should be applied as a symlink to its source
+// file. Only files whose target contents and permissions are exactly those of
+// their source file can be linked.
+func (f *File) linkable() bool {
+	return f.Link && !f.Create && !f.Modify && !f.Merge && !f.Empty && !f.Encrypted && !f.Template && !f.Executable() && !f.Private()
+}
+
+// checkConflict returns true if targetPath, whose current contents are
+// currData, can be overwritten. If the contents of targetPath have changed
+// since chezmoi last wrote it then applyOptions.Conflict decides. Files whose
+// target contents are computed from their current contents never conflict.
+func (f *File) checkConflict(targetPath string, currData []byte, applyOptions *ApplyOptions) (bool, error) {
+	if applyOptions.Conflict == nil || applyOptions.PersistentState == nil || applyOptions.FileStateBucket == nil || f.Modify || f.Merge {
+		return true, nil
+	}
+	fileStateData, err := applyOptions.PersistentState.Get(applyOptions.FileStateBucket, []byte(targetPath))
+	if err != nil {
+		return false, err
+	}
+	if fileStateData == nil {
+		return true, nil
+	}
+	var fileState FileState
+	if err := json.Unmarshal(fileStateData, &fileState); err != nil {
+		return false, err
+	}
+	if fileState.ContentsSHA256 == hashString(currData) {
+		return true, nil
+	}
+	return applyOptions.Conflict(f, targetPath)
+}
+
+// recordFileState records that targetPath has contents, if it is not already
+// recorded.
+func (o *ApplyOptions) recordFileState(targetPath string, contents []byte) error {
+	if o.DryRun || o.PersistentState == nil || o.FileStateBucket == nil {
+		return nil
+	}
+	fileStateData, err := json.Marshal(&FileState{
+		ContentsSHA256: hashString(contents),
+	})
+	if err != nil {
+		return err
+	}
+	// Avoid writing to the persistent state if nothing has changed.
+	prevFileStateData, err := o.PersistentState.Get(o.FileStateBucket, []byte(targetPath))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(prevFileStateData, fileStateData) {
+		return nil
+	}
+	return o.PersistentState.Set(o.FileStateBucket, []byte(targetPath), fileStateData)
+}
+
+// fileStatePaths returns the paths of the regular files in fs at or below
+// targetPath, whose recorded states must be deleted when targetPath is
+// replaced or removed.
+func (o *ApplyOptions) fileStatePaths(fs vfs.FS, targetPath string) ([]string, error) {
+	if o.DryRun || o.PersistentState == nil || o.FileStateBucket == nil {
+		return nil, nil
+	}
+	var paths []string
+	if err := walk(fs, targetPath, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		case info.Mode().IsRegular():
+			paths = append(paths, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// deleteFileStates deletes the recorded states of paths.
+func (o *ApplyOptions) deleteFileStates(paths []string) error {
+	for _, path := range paths {
+		if err := o.PersistentState.Delete(o.FileStateBucket, []byte(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTarget removes targetPath using mutator and deletes the recorded
+// states of the files in it.
+func removeTarget(fs vfs.FS, mutator Mutator, targetPath string, applyOptions *ApplyOptions) error {
+	paths, err := applyOptions.fileStatePaths(fs, targetPath)
+	if err != nil {
+		return err
+	}
+	if err := mutator.RemoveAll(targetPath); err != nil {
+		return err
+	}
+	return applyOptions.deleteFileStates(paths)
+}
+
+// merge returns the result of deep-merging the document contents into the
+// document currContents. If the merge does not change currContents then
+// currContents is returned unchanged.
+func (f *File) merge(currContents, contents []byte) ([]byte, error) {
+	var src map[string]interface{}
+	if err := f.format.Decode(contents, &src); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.sourceName, err)
+	}
+	var orig, dst map[string]interface{}
+	if !isEmpty(currContents) {
+		if err := f.format.Decode(currContents, &orig); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.targetName, err)
+		}
+		if err := f.format.Decode(currContents, &dst); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.targetName, err)
+		}
+	}
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	merged := deepMerge(dst, src)
+	if orig != nil && reflect.DeepEqual(orig, merged) {
+		return currContents, nil
+	}
+	b := &bytes.Buffer{}
+	if err := f.format.Encode(b, merged); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.targetName, err)
+	}
+	return b.Bytes(), nil
+}
+
 // archive writes f to w.
-func (f *File) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
-	if ignore(f.targetName) {
+func (f *File) archive(w ArchiveWriter, ignore func(string) bool, include EntryTypeSet, headerTemplate *tar.Header, umask os.FileMode, warn func(string)) error {
+	if !include.Has(EntryTypeFiles) || ignore(f.targetName) {
+		return nil
+	}
+	// The contents of files with the modify or merge attributes depend on the
+	// destination, so they cannot be archived.
+	if f.Modify || f.Merge {
+		warn(fmt.Sprintf("%s: skipping file whose contents depend on the destination", f.targetName))
 		return nil
 	}
 	contents, err := f.Contents()
@@ -256,7 +582,7 @@ func (f *File) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *
 	header.Size = int64(len(contents))
 	header.Mode = int64(f.Perm &^ umask)
 	if err := w.WriteHeader(&header); err != nil {
-		return nil
+		return err
 	}
 	_, err = w.Write(contents)
 	return err
@@ -106,6 +106,49 @@ func TestFileAttributes(t *testing.T) {
 				Template: true,
 			},
 		},
+		{
+			sourceName: "create_dot_foo",
+			fa: FileAttributes{
+				Name:   ".foo",
+				Mode:   0666,
+				Create: true,
+			},
+		},
+		{
+			sourceName: "create_encrypted_private_dot_foo",
+			fa: FileAttributes{
+				Name:      ".foo",
+				Mode:      0600,
+				Create:    true,
+				Encrypted: true,
+			},
+		},
+		{
+			sourceName: "modify_executable_dot_foo.tmpl",
+			fa: FileAttributes{
+				Name:     ".foo",
+				Mode:     0777,
+				Modify:   true,
+				Template: true,
+			},
+		},
+		{
+			sourceName: "link_dot_vimrc",
+			fa: FileAttributes{
+				Name: ".vimrc",
+				Mode: 0666,
+				Link: true,
+			},
+		},
+		{
+			sourceName: "merge_settings.json.tmpl",
+			fa: FileAttributes{
+				Name:     "settings.json",
+				Mode:     0666,
+				Merge:    true,
+				Template: true,
+			},
+		},
 		{
 			sourceName: "encrypted_private_dot_secret_file",
 			fa: FileAttributes{
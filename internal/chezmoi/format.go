@@ -0,0 +1,97 @@
+package chezmoi
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A Format is a serialization format.
+type Format struct {
+	Decode func([]byte, interface{}) error
+	Encode func(io.Writer, interface{}) error
+}
+
+// formatName returns the name of the format of the file name, determined by
+// its extension.
+func formatName(name string) string {
+	formatName := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if formatName == "yml" {
+		formatName = "yaml"
+	}
+	return formatName
+}
+
+// deepMerge returns the result of recursively merging src into dst. Maps are
+// merged key by key and all other values in src replace the values in dst.
+// dst may be modified.
+func deepMerge(dst, src interface{}) interface{} {
+	switch src := src.(type) {
+	case map[string]interface{}:
+		dstMap, ok := dst.(map[string]interface{})
+		if !ok {
+			return src
+		}
+		for key, value := range src {
+			dstMap[key] = deepMerge(dstMap[key], value)
+		}
+		return dstMap
+	case map[interface{}]interface{}:
+		dstMap, ok := dst.(map[interface{}]interface{})
+		if !ok {
+			return src
+		}
+		for key, value := range src {
+			dstMap[key] = deepMerge(dstMap[key], value)
+		}
+		return dstMap
+	default:
+		return src
+	}
+}
+
+// copyData returns a deep copy of the maps in value.
+func copyData(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, elem := range value {
+			result[key] = copyData(elem)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(value))
+		for key, elem := range value {
+			result[key] = copyData(elem)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// normalizeKeys returns value with all maps with interface{} keys, as decoded
+// by some YAML decoders, recursively converted to maps with string keys.
+func normalizeKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range value {
+			value[key] = normalizeKeys(elem)
+		}
+		return value
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, elem := range value {
+			result[fmt.Sprint(key)] = normalizeKeys(elem)
+		}
+		return result
+	case []interface{}:
+		for i, elem := range value {
+			value[i] = normalizeKeys(elem)
+		}
+		return value
+	default:
+		return value
+	}
+}
@@ -0,0 +1,71 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		dst  interface{}
+		src  interface{}
+		want interface{}
+	}{
+		{
+			name: "nil",
+			dst:  nil,
+			src:  map[string]interface{}{"a": 1},
+			want: map[string]interface{}{"a": 1},
+		},
+		{
+			name: "scalar",
+			dst:  map[string]interface{}{"a": 1, "b": 2},
+			src:  map[string]interface{}{"a": 3},
+			want: map[string]interface{}{"a": 3, "b": 2},
+		},
+		{
+			name: "nested",
+			dst: map[string]interface{}{
+				"a": map[string]interface{}{"b": 1, "c": 2},
+				"d": 3,
+			},
+			src: map[string]interface{}{
+				"a": map[string]interface{}{"b": 4, "e": 5},
+			},
+			want: map[string]interface{}{
+				"a": map[string]interface{}{"b": 4, "c": 2, "e": 5},
+				"d": 3,
+			},
+		},
+		{
+			name: "nested_yaml",
+			dst: map[string]interface{}{
+				"a": map[interface{}]interface{}{"b": 1, "c": 2},
+			},
+			src: map[string]interface{}{
+				"a": map[interface{}]interface{}{"b": 4},
+			},
+			want: map[string]interface{}{
+				"a": map[interface{}]interface{}{"b": 4, "c": 2},
+			},
+		},
+		{
+			name: "replace_map_with_scalar",
+			dst:  map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			src:  map[string]interface{}{"a": 2},
+			want: map[string]interface{}{"a": 2},
+		},
+		{
+			name: "replace_list",
+			dst:  map[string]interface{}{"a": []interface{}{1, 2}},
+			src:  map[string]interface{}{"a": []interface{}{3}},
+			want: map[string]interface{}{"a": []interface{}{3}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, deepMerge(tc.dst, tc.src))
+		})
+	}
+}
@@ -34,6 +34,11 @@ func (m *FSMutator) RunCmd(cmd *exec.Cmd) error {
 	return cmd.Run()
 }
 
+// RunScript implements Mutator.RunScript.
+func (m *FSMutator) RunScript(name, dir string, data []byte) error {
+	return runScript(m.FS, name, data, dir, os.Stdin, os.Stdout, os.Stderr)
+}
+
 // WriteSymlink implements Mutator.WriteSymlink.
 func (m *FSMutator) WriteSymlink(oldname, newname string) error {
 	// Special case: if writing to the real filesystem, use github.com/google/renameio
@@ -1,6 +1,7 @@
 package chezmoi
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -64,6 +65,14 @@ func (m *GitDiffMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	return m.m.IdempotentCmdOutput(cmd)
 }
 
+// Lchown implements Mutator.Lchown. git diffs cannot represent ownership, so
+// the change is written as the message of an otherwise empty patch.
+func (m *GitDiffMutator) Lchown(name string, uid, gid int) error {
+	return m.unifiedEncoder.Encode(&gitDiffPatch{
+		message: fmt.Sprintf("chown -h %s %s", ownerGroupString(uid, gid), m.trimPrefix(name)),
+	})
+}
+
 // Mkdir implements Mutator.Mkdir.
 func (m *GitDiffMutator) Mkdir(name string, perm os.FileMode) error {
 	toFileMode, err := filemode.NewFromOSFileMode(os.ModeDir | perm)
@@ -108,6 +117,12 @@ func (m *GitDiffMutator) RunCmd(cmd *exec.Cmd) error {
 	return nil
 }
 
+// RunScript implements Mutator.RunScript.
+func (m *GitDiffMutator) RunScript(name, dir string, data []byte) error {
+	// FIXME write scripts to diff
+	return nil
+}
+
 // Stat implements Mutator.Stat.
 func (m *GitDiffMutator) Stat(name string) (os.FileInfo, error) {
 	return m.m.Stat(name)
@@ -0,0 +1,348 @@
+package chezmoi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/coreos/go-semver/semver"
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Lint checks.
+const (
+	LintCheckDotfile   = "dotfile"
+	LintCheckEncrypted = "encrypted"
+	LintCheckIgnore    = "ignore"
+	LintCheckPrefix    = "prefix"
+	LintCheckTemplate  = "template"
+	LintCheckVersion   = "version"
+)
+
+// The prefixes of each type of entry, in the order in which they are parsed.
+// Each group contains mutually exclusive prefixes, of which at most one is
+// parsed.
+var (
+	blockPrefixes = [][]string{
+		{blockPrefix},
+		{dotPrefix},
+	}
+	dirPrefixes = [][]string{
+		{exactPrefix},
+		{privatePrefix},
+		{dotPrefix},
+	}
+	filePrefixes = [][]string{
+		{createPrefix, modifyPrefix, mergePrefix, linkPrefix},
+		{encryptedPrefix},
+		{privatePrefix},
+		{emptyPrefix},
+		{executablePrefix},
+		{dotPrefix},
+	}
+	scriptPrefixes = [][]string{
+		{runPrefix},
+		{encryptedPrefix},
+		{oncePrefix, onChangePrefix},
+		{beforePrefix, afterPrefix},
+	}
+	symlinkPrefixes = [][]string{
+		{symlinkPrefix},
+		{dotPrefix},
+	}
+)
+
+// vcsNames are the names of version control files and directories that are
+// expected in the source directory.
+var vcsNames = map[string]bool{
+	".git":           true,
+	".gitattributes": true,
+	".github":        true,
+	".gitignore":     true,
+	".gitmodules":    true,
+	".hg":            true,
+	".hgignore":      true,
+}
+
+// A LintProblem is a problem in the source state found by TargetState.Lint.
+type LintProblem struct {
+	Path    string `json:"path" yaml:"path"`
+	Check   string `json:"check" yaml:"check"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (p *LintProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// Lint returns the problems in the source state in fs without populating ts.
+// Unlike Populate, it continues after finding a problem so that all problems
+// are reported.
+func (ts *TargetState) Lint(fs vfs.FS) ([]*LintProblem, error) {
+	if err := ts.PopulateTemplateData(fs); err != nil {
+		return nil, err
+	}
+
+	l := &linter{
+		ts:            ts,
+		fs:            fs,
+		targetIgnores: make(map[string]*PatternSet),
+	}
+	for _, sourceDir := range ts.sourceDirs() {
+		if err := l.lintSourceDir(sourceDir); err != nil {
+			return nil, err
+		}
+	}
+
+	ignorePaths := make([]string, 0, len(l.targetIgnores))
+	for path := range l.targetIgnores {
+		ignorePaths = append(ignorePaths, path)
+	}
+	sort.Strings(ignorePaths)
+	for _, path := range ignorePaths {
+		for _, p := range l.targetIgnores[path].unmatched(l.targetNames) {
+			l.addProblem(path, LintCheckIgnore, "%q matches no targets", p.text)
+		}
+	}
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Path < l.problems[j].Path
+	})
+	return l.problems, nil
+}
+
+// A linter accumulates the problems found by TargetState.Lint.
+type linter struct {
+	ts            *TargetState
+	fs            vfs.FS
+	problems      []*LintProblem
+	targetNames   []string
+	targetIgnores map[string]*PatternSet
+}
+
+// addProblem adds a problem.
+func (l *linter) addProblem(path, check, format string, args ...interface{}) {
+	l.problems = append(l.problems, &LintProblem{
+		Path:    path,
+		Check:   check,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lintName adds a problem if name, the target name parsed from the last
+// component of path, still starts with one of prefixes that would have been
+// parsed had it come before the last prefix that was parsed, which indicates
+// that the prefixes in the source name are in the wrong order. Other prefixes,
+// for example script prefixes in the name of a file or a second prefix from a
+// group that has already been parsed, are part of the target name.
+func (l *linter) lintName(path, name string, prefixes [][]string) {
+	sourceName := filepath.Base(path)
+	parsed := make([]bool, len(prefixes))
+	lastGroup := 0
+	for i, group := range prefixes {
+		for _, prefix := range group {
+			if strings.HasPrefix(sourceName, prefix) {
+				sourceName = strings.TrimPrefix(sourceName, prefix)
+				parsed[i] = true
+				lastGroup = i
+				break
+			}
+		}
+	}
+	for i, group := range prefixes[:lastGroup] {
+		if parsed[i] {
+			continue
+		}
+		for _, prefix := range group {
+			if strings.HasPrefix(sourceName, prefix) {
+				l.addProblem(path, LintCheckPrefix, "target name %q starts with %q, prefixes may be in the wrong order", name, prefix)
+				return
+			}
+		}
+	}
+}
+
+// lintSourceDir adds the problems in the source directory sourceDir.
+func (l *linter) lintSourceDir(sourceDir string) error {
+	return vfs.Walk(l.fs, sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if name := info.Name(); strings.HasPrefix(name, ".") {
+			switch {
+			case name == attributesName:
+				l.lintTemplate(path, path)
+			case name == ignoreName:
+				if l.lintTemplate(path, path) == nil {
+					return nil
+				}
+				ps := NewPatternSet()
+				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+				if err := l.ts.addPatterns(l.fs, ps, path, filepath.Join(dns...)); err != nil {
+					l.addProblem(path, LintCheckIgnore, "%v", err)
+					return nil
+				}
+				l.targetIgnores[path] = ps
+			case name == removeName:
+				l.lintTemplate(path, path)
+			case name == templatesDirName:
+				prefix := filepath.ToSlash(path) + "/"
+				if err := vfs.Walk(l.fs, path, func(templatePath string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if !info.Mode().IsRegular() {
+						return nil
+					}
+					templateName := strings.TrimPrefix(filepath.ToSlash(templatePath), prefix)
+					if tmpl := l.lintTemplate(templateName, templatePath); tmpl != nil {
+						if l.ts.Templates == nil {
+							l.ts.Templates = make(map[string]*template.Template)
+						}
+						l.ts.Templates[templateName] = tmpl
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			case name == versionName:
+				data, err := l.fs.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if _, err := semver.NewVersion(strings.TrimSpace(string(data))); err != nil {
+					l.addProblem(path, LintCheckVersion, "%v", err)
+				}
+			case strings.HasPrefix(name, dataName+"."):
+			case name == keepName && !info.IsDir():
+			case vcsNames[name] && info.IsDir():
+				return filepath.SkipDir
+			case vcsNames[name]:
+			case info.IsDir():
+				l.addProblem(path, LintCheckDotfile, "directory is ignored as it begins with .")
+				return filepath.SkipDir
+			default:
+				l.addProblem(path, LintCheckDotfile, "file is ignored as it begins with .")
+			}
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			das := parseDirNameComponents(splitPathList(relPath))
+			l.lintName(path, das[len(das)-1].Name, dirPrefixes)
+			l.targetNames = append(l.targetNames, AsDir(filepath.Join(dirNames(das)...)))
+		case info.Mode().IsRegular():
+			psfp := parseSourceFilePath(relPath)
+			var name string
+			var prefixes [][]string
+			var encrypted, isTemplate bool
+			switch {
+			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeSymlink != 0:
+				name = psfp.fileAttributes.Name
+				prefixes = symlinkPrefixes
+				isTemplate = psfp.fileAttributes.Template
+			case psfp.fileAttributes != nil:
+				name = psfp.fileAttributes.Name
+				prefixes = filePrefixes
+				encrypted = psfp.fileAttributes.Encrypted
+				isTemplate = psfp.fileAttributes.Template
+			case psfp.scriptAttributes != nil:
+				name = psfp.scriptAttributes.Name
+				prefixes = scriptPrefixes
+				encrypted = psfp.scriptAttributes.Encrypted
+				isTemplate = psfp.scriptAttributes.Template
+			case psfp.blockAttributes != nil:
+				name = psfp.blockAttributes.Name
+				prefixes = blockPrefixes
+				isTemplate = psfp.blockAttributes.Template
+			}
+			l.lintName(path, name, prefixes)
+			l.targetNames = append(l.targetNames, filepath.Join(append(dirNames(psfp.dirAttributes), name)...))
+			switch {
+			case encrypted:
+				data, err := l.fs.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if !isCiphertext(data) {
+					l.addProblem(path, LintCheckEncrypted, "not encrypted")
+				}
+			case isTemplate:
+				l.lintTemplate(path, path)
+			}
+		}
+		return nil
+	})
+}
+
+// lintTemplate adds a problem if the template at path cannot be parsed and
+// returns the parsed template.
+func (l *linter) lintTemplate(name, path string) *template.Template {
+	data, err := l.fs.ReadFile(path)
+	if err != nil {
+		l.addProblem(path, LintCheckTemplate, "%v", err)
+		return nil
+	}
+	tmpl, err := l.ts.parseTemplate(name, data)
+	if err != nil {
+		l.addProblem(path, LintCheckTemplate, "%v", err)
+		return nil
+	}
+	return tmpl
+}
+
+// OpenPGP packet types that can start an encrypted message and the versions of
+// their bodies, see https://tools.ietf.org/html/rfc4880#section-4.3.
+var openPGPEncryptedPacketVersions = map[byte]byte{
+	1:  3, // Public-Key Encrypted Session Key
+	3:  4, // Symmetric-Key Encrypted Session Key
+	18: 1, // Symmetrically Encrypted Integrity Protected Data
+}
+
+// isCiphertext returns true if data looks like an OpenPGP message, either
+// ASCII armored or binary.
+func isCiphertext(data []byte) bool {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP MESSAGE-----")) {
+		return true
+	}
+	// A binary OpenPGP message starts with the header of an encrypted session
+	// key or encrypted data packet. The first octet of the header has its most
+	// significant bit set. In the old format, it contains the packet type in
+	// bits 5-2 and the number of length octets that follow in bits 1-0. In the
+	// new format, which sets bit 6, it contains the packet type in bits 5-0 and
+	// the first length octet determines the number of length octets. The body
+	// of the packet starts with its version.
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return false
+	}
+	var packetType byte
+	var bodyOffset int
+	if data[0]&0x40 == 0 {
+		packetType = (data[0] >> 2) & 0x0f
+		bodyOffset = 1 + []int{1, 2, 4, 0}[data[0]&0x03]
+	} else {
+		packetType = data[0] & 0x3f
+		switch length := data[1]; {
+		case length < 192 || length >= 224 && length != 255:
+			bodyOffset = 2
+		case length < 224:
+			bodyOffset = 3
+		default:
+			bodyOffset = 6
+		}
+	}
+	version, ok := openPGPEncryptedPacketVersions[packetType]
+	return ok && len(data) > bodyOffset && data[bodyOffset] == version
+}
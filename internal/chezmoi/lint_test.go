@@ -0,0 +1,297 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestTargetStateLint(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		root interface{}
+		want []*LintProblem
+	}{
+		{
+			name: "empty",
+			root: map[string]interface{}{
+				"/src": &vfst.Dir{Perm: 0755},
+			},
+		},
+		{
+			name: "valid",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					".chezmoiattributes":            "foo owner=root\n",
+					".chezmoiignore":                "foo\n",
+					".chezmoitemplates/foo":         "{{ .foo }}",
+					".chezmoiversion":               "1.2.3\n",
+					".git/config":                   "",
+					".gitignore":                    "",
+					"dot_bashrc.tmpl":               "{{ .foo }}",
+					"encrypted_private_dot_netrc":   "-----BEGIN PGP MESSAGE-----\n",
+					"exact_private_dot_ssh/id_rsa":  "",
+					"foo":                           "",
+					"run_once_before_install.tmpl":  "{{ .foo }}",
+					"symlink_dot_vimrc.tmpl":        "{{ .foo }}",
+					"encrypted_private_dot_binary":  "\x85\x01\x0c\x03",
+					"block_dot_profile.tmpl":        "{{ .foo }}",
+					"private_dot_config/.keep":      "",
+					"private_dot_config/dot_keep":   "",
+					"private_dot_config/empty_file": "",
+				},
+			},
+		},
+		{
+			name: "unparseable_templates",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					".chezmoiignore":        "{{ if }}\n",
+					".chezmoitemplates/foo": "{{ .foo ",
+					"dot_bashrc.tmpl":       "{{ end }}",
+				},
+			},
+			want: []*LintProblem{
+				{
+					Path:    "/src/.chezmoiignore",
+					Check:   LintCheckTemplate,
+					Message: "template: /src/.chezmoiignore:1: missing value for if",
+				},
+				{
+					Path:    "/src/.chezmoitemplates/foo",
+					Check:   LintCheckTemplate,
+					Message: "template: foo:1: unclosed action",
+				},
+				{
+					Path:    "/src/dot_bashrc.tmpl",
+					Check:   LintCheckTemplate,
+					Message: "template: /src/dot_bashrc.tmpl:1: unexpected {{end}}",
+				},
+			},
+		},
+		{
+			name: "misordered_prefixes",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					"dot_private_foo":          "",
+					"encrypted_private_bar":    "-----BEGIN PGP MESSAGE-----\n",
+					"private_encrypted_baz":    "",
+					"private_exact_dir/file":   "",
+					"run_before_once_install":  "",
+					"executable_private_empty": "",
+				},
+			},
+			want: []*LintProblem{
+				{
+					Path:    "/src/dot_private_foo",
+					Check:   LintCheckPrefix,
+					Message: `target name ".private_foo" starts with "private_", prefixes may be in the wrong order`,
+				},
+				{
+					Path:    "/src/executable_private_empty",
+					Check:   LintCheckPrefix,
+					Message: `target name "private_empty" starts with "private_", prefixes may be in the wrong order`,
+				},
+				{
+					Path:    "/src/private_encrypted_baz",
+					Check:   LintCheckPrefix,
+					Message: `target name "encrypted_baz" starts with "encrypted_", prefixes may be in the wrong order`,
+				},
+				{
+					Path:    "/src/private_exact_dir",
+					Check:   LintCheckPrefix,
+					Message: `target name "exact_dir" starts with "exact_", prefixes may be in the wrong order`,
+				},
+				{
+					Path:    "/src/run_before_once_install",
+					Check:   LintCheckPrefix,
+					Message: `target name "once_install" starts with "once_", prefixes may be in the wrong order`,
+				},
+			},
+		},
+		{
+			name: "prefixes_in_target_names",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					"block_dot_block_list":      "",
+					"create_merge_pdfs":         "",
+					"dot_dot_foo":               "",
+					"exact_run_dir/file":        "",
+					"executable_before_install": "",
+					"private_block_list":        "",
+					"run_once_once_more":        "",
+					"symlink_merge_pdfs":        "",
+				},
+			},
+		},
+		{
+			name: "dotfiles",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					".bashrc":         "",
+					".chezmoiignroe":  "",
+					".config/file":    "",
+					"dir/.hidden":     "",
+					"dir/.hidden_dir": &vfst.Dir{Perm: 0755},
+				},
+			},
+			want: []*LintProblem{
+				{
+					Path:    "/src/.bashrc",
+					Check:   LintCheckDotfile,
+					Message: "file is ignored as it begins with .",
+				},
+				{
+					Path:    "/src/.chezmoiignroe",
+					Check:   LintCheckDotfile,
+					Message: "file is ignored as it begins with .",
+				},
+				{
+					Path:    "/src/.config",
+					Check:   LintCheckDotfile,
+					Message: "directory is ignored as it begins with .",
+				},
+				{
+					Path:    "/src/dir/.hidden",
+					Check:   LintCheckDotfile,
+					Message: "file is ignored as it begins with .",
+				},
+				{
+					Path:    "/src/dir/.hidden_dir",
+					Check:   LintCheckDotfile,
+					Message: "directory is ignored as it begins with .",
+				},
+			},
+		},
+		{
+			name: "unmatched_ignore_patterns",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					".chezmoiignore": "# comment\n" +
+						"foo\n" +
+						"bar\n" +
+						"!baz\n" +
+						"dir/\n" +
+						"dot_qux\n",
+					"foo":            "",
+					"dot_qux":        "",
+					"exact_dir/file": "",
+					"sub/.chezmoiignore": "file\n" +
+						"missing\n",
+					"sub/file": "",
+				},
+			},
+			want: []*LintProblem{
+				{
+					Path:    "/src/.chezmoiignore",
+					Check:   LintCheckIgnore,
+					Message: `"bar" matches no targets`,
+				},
+				{
+					Path:    "/src/.chezmoiignore",
+					Check:   LintCheckIgnore,
+					Message: `"dot_qux" matches no targets`,
+				},
+				{
+					Path:    "/src/sub/.chezmoiignore",
+					Check:   LintCheckIgnore,
+					Message: `"missing" matches no targets`,
+				},
+			},
+		},
+		{
+			name: "not_encrypted",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					"encrypted_dot_netrc":     "machine example.com\n",
+					"encrypted_dot_utf16":     "\xff\xfe",
+					"encrypted_empty_dot_foo": "",
+					"run_encrypted_install":   "#!/bin/sh\n",
+				},
+			},
+			want: []*LintProblem{
+				{
+					Path:    "/src/encrypted_dot_netrc",
+					Check:   LintCheckEncrypted,
+					Message: "not encrypted",
+				},
+				{
+					Path:    "/src/encrypted_dot_utf16",
+					Check:   LintCheckEncrypted,
+					Message: "not encrypted",
+				},
+				{
+					Path:    "/src/encrypted_empty_dot_foo",
+					Check:   LintCheckEncrypted,
+					Message: "not encrypted",
+				},
+				{
+					Path:    "/src/run_encrypted_install",
+					Check:   LintCheckEncrypted,
+					Message: "not encrypted",
+				},
+			},
+		},
+		{
+			name: "invalid_version",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					".chezmoiversion": "1.2\n",
+				},
+			},
+			want: []*LintProblem{
+				{
+					Path:    "/src/.chezmoiversion",
+					Check:   LintCheckVersion,
+					Message: `1.2 is not in dotted-tri format`,
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			ts := NewTargetState(
+				WithSourceDir("/src"),
+				WithTemplateData(map[string]interface{}{
+					"foo": "bar",
+				}),
+			)
+			got, err := ts.Lint(fs)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestIsCiphertext(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "empty", data: "", want: false},
+		{name: "armored", data: "\n-----BEGIN PGP MESSAGE-----\n", want: true},
+		{name: "old_format_pkesk", data: "\x85\x01\x0c\x03", want: true},
+		{name: "old_format_skesk", data: "\x8c\x0d\x04", want: true},
+		{name: "old_format_indeterminate_length", data: "\x8f\x04", want: true},
+		{name: "new_format_pkesk", data: "\xc1\xc0\x4c\x03", want: true},
+		{name: "new_format_skesk", data: "\xc3\x0d\x04", want: true},
+		{name: "new_format_seipd", data: "\xd2\xff\x00\x00\x10\x00\x01", want: true},
+		{name: "new_format_seipd_partial_length", data: "\xd2\xe9\x01", want: true},
+		{name: "wrong_version", data: "\xc3\x0d\x03", want: false},
+		{name: "old_format_literal_data", data: "\xac\x10\x62", want: false},
+		{name: "new_format_signature", data: "\xc2\x10\x04", want: false},
+		{name: "truncated", data: "\x85\x01", want: false},
+		{name: "no_tag_bit", data: "\x05\x01\x0c\x03", want: false},
+		{name: "utf16_bom", data: "\xff\xfe", want: false},
+		{name: "utf8", data: "\xc3\xa9t\xc3\xa9\n", want: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isCiphertext([]byte(tc.data)))
+		})
+	}
+}
@@ -9,10 +9,12 @@ import (
 type Mutator interface {
 	Chmod(name string, mode os.FileMode) error
 	IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error)
+	Lchown(name string, uid, gid int) error
 	Mkdir(name string, perm os.FileMode) error
 	RemoveAll(name string) error
 	Rename(oldpath, newpath string) error
 	RunCmd(cmd *exec.Cmd) error
+	RunScript(name, dir string, data []byte) error
 	Stat(name string) (os.FileInfo, error)
 	WriteFile(filename string, data []byte, perm os.FileMode, currData []byte) error
 	WriteSymlink(oldname, newname string) error
@@ -18,6 +18,11 @@ func (NullMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	return cmd.Output()
 }
 
+// Lchown implements Mutator.Lchown.
+func (NullMutator) Lchown(string, int, int) error {
+	return nil
+}
+
 // Mkdir implements Mutator.Mkdir.
 func (NullMutator) Mkdir(string, os.FileMode) error {
 	return nil
@@ -38,6 +43,11 @@ func (NullMutator) RunCmd(cmd *exec.Cmd) error {
 	return nil
 }
 
+// RunScript implements Mutator.RunScript.
+func (NullMutator) RunScript(string, string, []byte) error {
+	return nil
+}
+
 // Stat implements Mutator.Stat.
 func (NullMutator) Stat(path string) (os.FileInfo, error) {
 	return nil, &os.PathError{
@@ -0,0 +1,189 @@
+package chezmoi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// An Ownership is the owner and group of a target. Each of Owner and Group is
+// either a name, a numeric id, or empty to leave it unchanged.
+type Ownership struct {
+	Owner string
+	Group string
+}
+
+// An ownershipPattern sets the ownership of the targets matched by a pattern.
+type ownershipPattern struct {
+	ps        *PatternSet
+	ownership Ownership
+}
+
+// ids returns the numeric user and group ids of o, or -1 for each of the owner
+// and group that is not set.
+func (o Ownership) ids() (int, int, error) {
+	uid, gid := -1, -1
+	if o.Owner != "" {
+		var err error
+		if uid, err = strconv.Atoi(o.Owner); err != nil {
+			u, err := user.Lookup(o.Owner)
+			if err != nil {
+				return 0, 0, err
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, fmt.Errorf("%s: %w", o.Owner, err)
+			}
+		}
+	}
+	if o.Group != "" {
+		var err error
+		if gid, err = strconv.Atoi(o.Group); err != nil {
+			g, err := user.LookupGroup(o.Group)
+			if err != nil {
+				return 0, 0, err
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("%s: %w", o.Group, err)
+			}
+		}
+	}
+	return uid, gid, nil
+}
+
+// addOwnershipPatterns adds the ownership patterns in the attributes file at
+// path, relative to relPath, to ts. Each line contains a pattern followed by
+// owner=value and group=value attributes.
+func (ts *TargetState) addOwnershipPatterns(fs vfs.FS, path, relPath string) error {
+	data, err := ts.executeTemplate(fs, path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(relPath)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for s.Scan() {
+		lineNumber++
+		text := s.Text()
+		if index := strings.IndexRune(text, '#'); index != -1 {
+			text = text[:index]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		op := &ownershipPattern{
+			ps: NewPatternSet(),
+		}
+		if err := op.ps.Add(dir, fields[0]); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		if len(fields) == 1 {
+			return fmt.Errorf("%s:%d: %s: no attributes", path, lineNumber, fields[0])
+		}
+		for _, field := range fields[1:] {
+			index := strings.IndexRune(field, '=')
+			if index == -1 {
+				return fmt.Errorf("%s:%d: %s: invalid attribute", path, lineNumber, field)
+			}
+			switch key, value := field[:index], field[index+1:]; key {
+			case "owner":
+				op.ownership.Owner = value
+			case "group":
+				op.ownership.Group = value
+			default:
+				return fmt.Errorf("%s:%d: %s: unknown attribute", path, lineNumber, key)
+			}
+		}
+		ts.ownershipPatterns = append(ts.ownershipPatterns, op)
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ownership returns the ownership of name. Later patterns that match name
+// override the owner and group set by earlier ones.
+func (ts *TargetState) ownership(name string) Ownership {
+	var o Ownership
+	for _, op := range ts.ownershipPatterns {
+		if !op.ps.Match(name) {
+			continue
+		}
+		if op.ownership.Owner != "" {
+			o.Owner = op.ownership.Owner
+		}
+		if op.ownership.Group != "" {
+			o.Group = op.ownership.Group
+		}
+	}
+	return o
+}
+
+// ownerGroupString returns uid and gid in the form accepted by chown, omitting
+// either if it is -1.
+func ownerGroupString(uid, gid int) string {
+	switch {
+	case gid == -1:
+		return strconv.Itoa(uid)
+	case uid == -1:
+		return ":" + strconv.Itoa(gid)
+	default:
+		return strconv.Itoa(uid) + ":" + strconv.Itoa(gid)
+	}
+}
+
+// SetOwnershipPattern returns data, the contents of an attributes file, with
+// the ownership set by pattern replaced by the result of calling modify with
+// its current ownership. Existing lines for pattern are removed, keeping their
+// comments, and the new ownership, if any, is appended on a line of its own.
+func SetOwnershipPattern(data []byte, pattern string, modify func(Ownership) Ownership) []byte {
+	var o Ownership
+	var lines []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		text, comment := line, ""
+		if index := strings.IndexRune(line, '#'); index != -1 {
+			text, comment = line[:index], line[index:]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 || fields[0] != pattern {
+			lines = append(lines, line)
+			continue
+		}
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "owner="):
+				o.Owner = strings.TrimPrefix(field, "owner=")
+			case strings.HasPrefix(field, "group="):
+				o.Group = strings.TrimPrefix(field, "group=")
+			}
+		}
+		if comment != "" {
+			lines = append(lines, comment)
+		}
+	}
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	o = modify(o)
+	fields := []string{pattern}
+	if o.Owner != "" {
+		fields = append(fields, "owner="+o.Owner)
+	}
+	if o.Group != "" {
+		fields = append(fields, "group="+o.Group)
+	}
+	if len(fields) > 1 {
+		lines = append(lines, strings.Join(fields, " ")+"\n")
+	}
+	return []byte(strings.Join(lines, ""))
+}
@@ -0,0 +1,49 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"os"
+	"syscall"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// applyOwnership ensures that the owner and group of targetPath in fs match o.
+func applyOwnership(fs vfs.FS, mutator Mutator, targetPath string, o Ownership) error {
+	if o == (Ownership{}) {
+		return nil
+	}
+	uid, gid, err := o.ids()
+	if err != nil {
+		return err
+	}
+	info, err := fs.Lstat(targetPath)
+	switch {
+	case err == nil:
+		if statT, ok := info.Sys().(*syscall.Stat_t); ok {
+			if uid == int(statT.Uid) {
+				uid = -1
+			}
+			if gid == int(statT.Gid) {
+				gid = -1
+			}
+		}
+		if uid == -1 && gid == -1 {
+			return nil
+		}
+	case os.IsNotExist(err):
+	default:
+		return err
+	}
+	return mutator.Lchown(targetPath, uid, gid)
+}
+
+// fileOwnership returns the owner and group of the file described by info.
+func fileOwnership(info os.FileInfo) (int, int, bool) {
+	statT, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(statT.Uid), int(statT.Gid), true
+}
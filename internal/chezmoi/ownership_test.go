@@ -0,0 +1,56 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOwnershipPattern(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		data      string
+		pattern   string
+		ownership Ownership
+		wantOld   Ownership
+		want      string
+	}{
+		{
+			name:      "empty",
+			pattern:   "/foo",
+			ownership: Ownership{Owner: "root"},
+			want:      "/foo owner=root\n",
+		},
+		{
+			name:      "append",
+			data:      "/bar group=wheel",
+			pattern:   "/foo",
+			ownership: Ownership{Owner: "root"},
+			want:      "/bar group=wheel\n/foo owner=root\n",
+		},
+		{
+			name:      "replace",
+			data:      "/foo owner=root\n/bar group=wheel\n",
+			pattern:   "/foo",
+			ownership: Ownership{Group: "wheel"},
+			wantOld:   Ownership{Owner: "root"},
+			want:      "/bar group=wheel\n/foo group=wheel\n",
+		},
+		{
+			name:    "remove",
+			data:    "/foo owner=root group=wheel # comment\n/foo/bar owner=root\n",
+			pattern: "/foo",
+			wantOld: Ownership{Owner: "root", Group: "wheel"},
+			want:    "# comment\n/foo/bar owner=root\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotOld Ownership
+			assert.Equal(t, tc.want, string(SetOwnershipPattern([]byte(tc.data), tc.pattern, func(o Ownership) Ownership {
+				gotOld = o
+				return tc.ownership
+			})))
+			assert.Equal(t, tc.wantOld, gotOld)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// +build windows
+
+package chezmoi
+
+import (
+	"fmt"
+	"os"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// applyOwnership returns an error if o is set, as Windows does not support
+// setting the owner and group of files.
+func applyOwnership(fs vfs.FS, mutator Mutator, targetPath string, o Ownership) error {
+	if o == (Ownership{}) {
+		return nil
+	}
+	return fmt.Errorf("%s: setting owner and group is not supported on Windows", targetPath)
+}
+
+// fileOwnership returns false, as Windows does not support the owner and group
+// of files.
+func fileOwnership(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -1,47 +1,134 @@
 package chezmoi
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
 	"github.com/bmatcuk/doublestar"
 )
 
-// An PatternSet is a set of patterns.
+// An PatternSet is an ordered list of patterns with gitignore semantics.
 type PatternSet struct {
-	includes map[string]struct{}
-	excludes map[string]struct{}
+	patterns []*pattern
+}
+
+// A pattern is a single pattern in a PatternSet.
+type pattern struct {
+	text     string
+	pattern  string
+	include  bool
+	dirOnly  bool
+	anchored bool
 }
 
 // NewPatternSet returns a new PatternSet.
 func NewPatternSet() *PatternSet {
-	return &PatternSet{
-		includes: make(map[string]struct{}),
-		excludes: make(map[string]struct{}),
-	}
+	return &PatternSet{}
+}
+
+// AsDir returns name marked as a directory for PatternSet.Match.
+func AsDir(name string) string {
+	return name + string(filepath.Separator)
 }
 
-// Add adds a pattern to ps.
-func (ps *PatternSet) Add(pattern string, include bool) error {
-	if _, err := doublestar.PathMatch(pattern, ""); err != nil {
-		return nil
+// Add adds text, a pattern relative to dir, to ps. As in gitignore, a leading
+// ! negates the pattern, a pattern with a leading or middle / is anchored to
+// dir while all other patterns match at any depth below dir, and a pattern with
+// a trailing / only matches directories.
+func (ps *PatternSet) Add(dir, text string) error {
+	original := text
+	include := true
+	if strings.HasPrefix(text, "!") {
+		include = false
+		text = strings.TrimPrefix(text, "!")
 	}
-	if include {
-		ps.includes[pattern] = struct{}{}
-	} else {
-		ps.excludes[pattern] = struct{}{}
+	dirOnly := false
+	if strings.HasSuffix(text, "/") {
+		dirOnly = true
+		text = strings.TrimSuffix(text, "/")
 	}
+	anchored := strings.Contains(text, "/")
+	text = strings.TrimPrefix(text, "/")
+	if text == "" {
+		return fmt.Errorf("%q: empty pattern", original)
+	}
+	// Check each component against itself, as doublestar only reports syntax
+	// errors in the parts of a pattern that it evaluates.
+	for _, component := range strings.Split(text, "/") {
+		if _, err := doublestar.Match(component, component); err != nil {
+			return fmt.Errorf("%q: %w", original, err)
+		}
+	}
+	p := filepath.FromSlash(text)
+	if !anchored {
+		p = filepath.Join("**", p)
+	}
+	p = filepath.Join(dir, p)
+	ps.patterns = append(ps.patterns, &pattern{
+		text:     original,
+		pattern:  p,
+		include:  include,
+		dirOnly:  dirOnly,
+		anchored: anchored,
+	})
 	return nil
 }
 
-// Match returns if name matches any pattern in ps.
+// Match returns if name is included by ps. name is treated as a directory if
+// it ends with a path separator, see AsDir. As in gitignore, the last pattern
+// that matches name determines whether name is included, and name is included
+// if any of its parent directories are included.
 func (ps *PatternSet) Match(name string) bool {
-	for pattern := range ps.excludes {
-		if ok, _ := doublestar.PathMatch(pattern, name); ok {
-			return false
+	isDir := strings.HasSuffix(name, string(filepath.Separator))
+	components := splitPathList(strings.TrimSuffix(name, string(filepath.Separator)))
+	for i := 1; i < len(components); i++ {
+		if ps.match(filepath.Join(components[:i]...), true) {
+			return true
 		}
 	}
-	for pattern := range ps.includes {
-		if ok, _ := doublestar.PathMatch(pattern, name); ok {
-			return true
+	return ps.match(filepath.Join(components...), isDir)
+}
+
+// includes returns all patterns in ps that include names.
+func (ps *PatternSet) includes() []*pattern {
+	var includes []*pattern
+	for _, p := range ps.patterns {
+		if p.include {
+			includes = append(includes, p)
+		}
+	}
+	return includes
+}
+
+// match returns if name is included by the last pattern in ps that matches
+// it.
+func (ps *PatternSet) match(name string, isDir bool) bool {
+	for i := len(ps.patterns) - 1; i >= 0; i-- {
+		p := ps.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if ok, _ := doublestar.PathMatch(p.pattern, name); ok {
+			return p.include
 		}
 	}
 	return false
 }
+
+// unmatched returns the patterns in ps that include names but that do not match
+// any of names.
+func (ps *PatternSet) unmatched(names []string) []*pattern {
+	var unmatched []*pattern
+FOR:
+	for _, p := range ps.includes() {
+		single := &PatternSet{patterns: []*pattern{p}}
+		for _, name := range names {
+			if single.Match(name) {
+				continue FOR
+			}
+		}
+		unmatched = append(unmatched, p)
+	}
+	return unmatched
+}
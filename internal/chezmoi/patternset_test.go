@@ -23,9 +23,9 @@ func TestPatternSet(t *testing.T) {
 		},
 		{
 			name: "exact",
-			ps: mustNewPatternSet(t, map[string]bool{
-				"foo": true,
-			}),
+			ps: mustNewPatternSet(t, ".",
+				"foo",
+			),
 			expectMatches: map[string]bool{
 				"foo": true,
 				"bar": false,
@@ -33,9 +33,9 @@ func TestPatternSet(t *testing.T) {
 		},
 		{
 			name: "wildcard",
-			ps: mustNewPatternSet(t, map[string]bool{
-				"b*": true,
-			}),
+			ps: mustNewPatternSet(t, ".",
+				"b*",
+			),
 			expectMatches: map[string]bool{
 				"foo": false,
 				"bar": true,
@@ -44,40 +44,152 @@ func TestPatternSet(t *testing.T) {
 		},
 		{
 			name: "exclude",
-			ps: mustNewPatternSet(t, map[string]bool{
-				"b*":  true,
-				"baz": false,
-			}),
+			ps: mustNewPatternSet(t, ".",
+				"b*",
+				"!baz",
+			),
 			expectMatches: map[string]bool{
 				"foo": false,
 				"bar": true,
 				"baz": false,
 			},
 		},
+		{
+			name: "last_match_wins",
+			ps: mustNewPatternSet(t, ".",
+				"!baz",
+				"b*",
+			),
+			expectMatches: map[string]bool{
+				"bar": true,
+				"baz": true,
+			},
+		},
+		{
+			name: "reinclude",
+			ps: mustNewPatternSet(t, ".",
+				"*.txt",
+				"!important.txt",
+				"important.txt",
+			),
+			expectMatches: map[string]bool{
+				"foo.txt":       true,
+				"important.txt": true,
+			},
+		},
 		{
 			name: "doublestar",
-			ps: mustNewPatternSet(t, map[string]bool{
-				"**/foo": true,
-			}),
+			ps: mustNewPatternSet(t, ".",
+				"**/foo",
+			),
 			expectMatches: map[string]bool{
 				"foo":                              true,
 				filepath.Join("bar", "foo"):        true,
 				filepath.Join("baz", "bar", "foo"): true,
 			},
 		},
+		{
+			name: "unanchored",
+			ps: mustNewPatternSet(t, ".",
+				"*.txt",
+			),
+			expectMatches: map[string]bool{
+				"foo.txt":                          true,
+				filepath.Join("bar", "foo.txt"):    true,
+				filepath.Join("bar", "foo.txt.gz"): false,
+			},
+		},
+		{
+			name: "anchored",
+			ps: mustNewPatternSet(t, ".",
+				"/foo",
+				"bar/baz",
+			),
+			expectMatches: map[string]bool{
+				"foo":                               true,
+				filepath.Join("qux", "foo"):         false,
+				filepath.Join("bar", "baz"):         true,
+				filepath.Join("bar", "baz", "quux"): true,
+				filepath.Join("qux", "bar", "baz"):  false,
+			},
+		},
+		{
+			name: "anchored_in_subdir",
+			ps: mustNewPatternSet(t, "dir",
+				"/foo",
+				"bar",
+			),
+			expectMatches: map[string]bool{
+				"foo":                              false,
+				"bar":                              false,
+				filepath.Join("dir", "foo"):        true,
+				filepath.Join("dir", "bar"):        true,
+				filepath.Join("dir", "qux", "foo"): false,
+				filepath.Join("dir", "qux", "bar"): true,
+			},
+		},
+		{
+			name: "dir_only",
+			ps: mustNewPatternSet(t, ".",
+				"foo/",
+			),
+			expectMatches: map[string]bool{
+				"foo":                              false,
+				AsDir("foo"):                       true,
+				filepath.Join("foo", "bar"):        true,
+				filepath.Join("bar", "foo"):        false,
+				AsDir(filepath.Join("bar", "foo")): true,
+				filepath.Join("bar", "foo", "baz"): true,
+			},
+		},
+		{
+			name: "parent_dir_included",
+			ps: mustNewPatternSet(t, ".",
+				"foo",
+				"!foo/bar",
+			),
+			expectMatches: map[string]bool{
+				"foo":                       true,
+				filepath.Join("foo", "bar"): true,
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			for s, expectMatch := range tc.expectMatches {
-				assert.Equal(t, expectMatch, tc.ps.Match(s))
+				assert.Equal(t, expectMatch, tc.ps.Match(s), s)
 			}
 		})
 	}
 }
 
-func mustNewPatternSet(t *testing.T, patterns map[string]bool) *PatternSet {
+func TestPatternSetAddError(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		wantErr string
+	}{
+		{
+			text:    "[",
+			wantErr: `"[": syntax error in pattern`,
+		},
+		{
+			text:    "foo/[a-/bar",
+			wantErr: `"foo/[a-/bar": syntax error in pattern`,
+		},
+		{
+			text:    "!/",
+			wantErr: `"!/": empty pattern`,
+		},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			assert.EqualError(t, NewPatternSet().Add(".", tc.text), tc.wantErr)
+		})
+	}
+}
+
+func mustNewPatternSet(t *testing.T, dir string, texts ...string) *PatternSet {
 	ps := NewPatternSet()
-	for pattern, exclude := range patterns {
-		require.NoError(t, ps.Add(pattern, exclude))
+	for _, text := range texts {
+		require.NoError(t, ps.Add(dir, text))
 	}
 	return ps
 }
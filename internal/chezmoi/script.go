@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -16,27 +17,33 @@ import (
 	vfs "github.com/twpayne/go-vfs"
 )
 
-// FIXME allow encrypted scripts
-// FIXME add pre- and post- attributes
-
 // A ScriptAttributes holds attributes parsed from a source script name.
 type ScriptAttributes struct {
-	Name     string
-	Once     bool
-	Template bool
+	Name      string
+	Encrypted bool
+	Once      bool
+	OnChange  bool
+	Before    bool
+	After     bool
+	Template  bool
 }
 
 // A ScriptState represents the state of a script.
 type ScriptState struct {
-	Name       string    `json:"name"`
-	ExecutedAt time.Time `json:"executedAt"`
+	Name           string    `json:"name"`
+	ExecutedAt     time.Time `json:"executedAt"`
+	ContentsSHA256 string    `json:"contentsSHA256,omitempty"`
 }
 
 // A Script represents a script to run.
 type Script struct {
 	sourceName       string
 	targetName       string
+	Encrypted        bool
 	Once             bool
+	OnChange         bool
+	Before           bool
+	After            bool
 	Template         bool
 	contents         []byte
 	contentsErr      error
@@ -44,39 +51,80 @@ type Script struct {
 }
 
 type scriptConcreteValue struct {
-	Type       string `json:"type" yaml:"type"`
-	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
-	TargetPath string `json:"targetPath" yaml:"targetPath"`
-	Once       bool   `json:"once" yaml:"once"`
-	Template   bool   `json:"template" yaml:"template"`
-	Contents   string `json:"contents" yaml:"contents"`
+	Type       string   `json:"type" yaml:"type"`
+	SourcePath string   `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath string   `json:"targetPath" yaml:"targetPath"`
+	Encrypted  bool     `json:"encrypted" yaml:"encrypted"`
+	Once       bool     `json:"once" yaml:"once"`
+	OnChange   bool     `json:"onChange" yaml:"onChange"`
+	Before     bool     `json:"before" yaml:"before"`
+	After      bool     `json:"after" yaml:"after"`
+	Template   bool     `json:"template" yaml:"template"`
+	Contents   string   `json:"contents" yaml:"contents"`
+	Overrides  []string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
 }
 
 // ParseScriptAttributes parses a source script file name.
 func ParseScriptAttributes(sourceName string) ScriptAttributes {
 	name := strings.TrimPrefix(sourceName, runPrefix)
+	encrypted := false
 	once := false
+	onChange := false
+	before := false
+	after := false
 	template := false
-	if strings.HasPrefix(name, oncePrefix) {
+	if strings.HasPrefix(name, encryptedPrefix) {
+		encrypted = true
+		name = strings.TrimPrefix(name, encryptedPrefix)
+	}
+	switch {
+	case strings.HasPrefix(name, oncePrefix):
 		once = true
 		name = strings.TrimPrefix(name, oncePrefix)
+	case strings.HasPrefix(name, onChangePrefix):
+		onChange = true
+		name = strings.TrimPrefix(name, onChangePrefix)
+	}
+	switch {
+	case strings.HasPrefix(name, beforePrefix):
+		before = true
+		name = strings.TrimPrefix(name, beforePrefix)
+	case strings.HasPrefix(name, afterPrefix):
+		after = true
+		name = strings.TrimPrefix(name, afterPrefix)
 	}
 	if strings.HasSuffix(name, TemplateSuffix) {
 		template = true
 		name = strings.TrimSuffix(name, TemplateSuffix)
 	}
 	return ScriptAttributes{
-		Name:     name,
-		Once:     once,
-		Template: template,
+		Name:      name,
+		Encrypted: encrypted,
+		Once:      once,
+		OnChange:  onChange,
+		Before:    before,
+		After:     after,
+		Template:  template,
 	}
 }
 
 // SourceName returns sa's source name.
 func (sa ScriptAttributes) SourceName() string {
 	sourceName := runPrefix
-	if sa.Once {
+	if sa.Encrypted {
+		sourceName += encryptedPrefix
+	}
+	switch {
+	case sa.Once:
 		sourceName += oncePrefix
+	case sa.OnChange:
+		sourceName += onChangePrefix
+	}
+	switch {
+	case sa.Before:
+		sourceName += beforePrefix
+	case sa.After:
+		sourceName += afterPrefix
 	}
 	sourceName += sa.Name
 	if sa.Template {
@@ -95,6 +143,13 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 	if applyOptions.Ignore(s.targetName) {
 		return nil
 	}
+	// Scripts with a phase are run separately by TargetState.Apply.
+	if applyOptions.skipPhaseScripts && (s.Before || s.After) {
+		return nil
+	}
+	if ok, err := applyOptions.confirm(fs, s, filepath.Join(applyOptions.DestDir, s.targetName), follow); err != nil || !ok {
+		return err
+	}
 	contents, err := s.Contents()
 	if err != nil {
 		return err
@@ -103,10 +158,14 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 		return nil
 	}
 
+	contentsSHA256Arr := sha256.Sum256(contents)
+	contentsSHA256 := hex.EncodeToString(contentsSHA256Arr[:])
 	var key []byte
-	if s.Once {
-		contentsKeyArr := sha256.Sum256(contents)
-		key = []byte(s.targetName + ":" + hex.EncodeToString(contentsKeyArr[:]))
+	switch {
+	case s.Once:
+		// Once scripts have one key per contents, so they are never run again
+		// with contents that they have already been run with.
+		key = []byte(s.targetName + ":" + contentsSHA256)
 		scriptStateData, err := applyOptions.PersistentState.Get(applyOptions.ScriptStateBucket, key)
 		if err != nil {
 			return err
@@ -114,6 +173,23 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 		if scriptStateData != nil {
 			return nil
 		}
+	case s.OnChange:
+		// OnChange scripts have a single key that records the contents they
+		// were last run with, so they are run whenever their contents change.
+		key = []byte(s.targetName)
+		scriptStateData, err := applyOptions.PersistentState.Get(applyOptions.ScriptStateBucket, key)
+		if err != nil {
+			return err
+		}
+		if scriptStateData != nil {
+			var scriptState ScriptState
+			if err := json.Unmarshal(scriptStateData, &scriptState); err != nil {
+				return err
+			}
+			if scriptState.ContentsSHA256 == contentsSHA256 {
+				return nil
+			}
+		}
 	}
 
 	if applyOptions.Verbose {
@@ -121,45 +197,21 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 			return err
 		}
 	}
-	if applyOptions.DryRun {
-		return nil
-	}
-
-	// Write the temporary script file. Put the randomness on the front of the
-	// filename to preserve any file extension for Windows scripts.
-	f, err := ioutil.TempFile("", "*."+filepath.Base(s.targetName))
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		_ = os.RemoveAll(f.Name())
-	}()
-	if err := os.Chmod(f.Name(), 0700); err != nil {
+	// Scripts are run by the mutator, which does nothing in a dry run, so
+	// that mutators can record that the script would be run.
+	targetPath := filepath.Join(applyOptions.DestDir, s.targetName)
+	if err := mutator.RunScript(targetPath, filepath.Dir(targetPath), contents); err != nil {
 		return err
 	}
-	if _, err := f.Write(contents); err != nil {
-		return err
-	}
-	if err := f.Close(); err != nil {
-		return err
-	}
-
-	// Run the temporary script file.
-	//nolint:gosec
-	c := exec.Command(f.Name())
-	c.Dir = filepath.Join(applyOptions.DestDir, filepath.Dir(s.targetName))
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	c.Stdin = os.Stdin
-	if err := c.Run(); err != nil {
-		return err
+	if applyOptions.DryRun {
+		return nil
 	}
 
-	if s.Once {
+	if key != nil {
 		scriptState := &ScriptState{
-			Name:       s.sourceName,
-			ExecutedAt: time.Now(),
+			Name:           s.sourceName,
+			ExecutedAt:     time.Now(),
+			ContentsSHA256: contentsSHA256,
 		}
 		scriptStateData, err := json.Marshal(&scriptState)
 		if err != nil {
@@ -174,7 +226,7 @@ func (s *Script) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *Ap
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (s *Script) ConcreteValue(ignore func(string) bool, sourcePaths func(Entry) []string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(s.targetName) {
 		return nil, nil
 	}
@@ -182,13 +234,19 @@ func (s *Script) ConcreteValue(ignore func(string) bool, sourceDir string, umask
 	if err != nil {
 		return nil, err
 	}
+	paths := sourcePaths(s)
 	return &scriptConcreteValue{
 		Type:       "script",
-		SourcePath: filepath.Join(sourceDir, s.SourceName()),
+		SourcePath: paths[0],
 		TargetPath: s.TargetName(),
+		Encrypted:  s.Encrypted,
 		Once:       s.Once,
+		OnChange:   s.OnChange,
+		Before:     s.Before,
+		After:      s.After,
 		Template:   s.Template,
 		Contents:   string(contents),
+		Overrides:  paths[1:],
 	}, nil
 }
 
@@ -220,9 +278,10 @@ func (s *Script) TargetName() string {
 	return s.targetName
 }
 
-// archive writes s to w.
-func (s *Script) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
-	if ignore(s.targetName) {
+// archive writes s to w as an executable file with PAX records describing
+// when it is run.
+func (s *Script) archive(w ArchiveWriter, ignore func(string) bool, include EntryTypeSet, headerTemplate *tar.Header, umask os.FileMode, warn func(string)) error {
+	if !include.Has(EntryTypeScripts) || ignore(s.targetName) {
 		return nil
 	}
 	contents, err := s.Contents()
@@ -234,9 +293,73 @@ func (s *Script) archive(w *tar.Writer, ignore func(string) bool, headerTemplate
 	header.Name = s.targetName
 	header.Size = int64(len(contents))
 	header.Mode = int64(0777 &^ umask)
+	header.PAXRecords = scriptPAXRecords(s)
 	if err := w.WriteHeader(&header); err != nil {
-		return nil
+		return err
 	}
 	_, err = w.Write(contents)
 	return err
 }
+
+// runScript writes contents to a temporary file and executes it in dir in fs,
+// or the closest existing parent of dir, with the given stdin, stdout, and
+// stderr. name is used to choose the temporary file's extension.
+func runScript(fs vfs.FS, name string, contents []byte, dir string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c, cleanup, err := scriptCmd(fs, name, contents, dir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
+// scriptCmd writes contents to a temporary file and returns a command that
+// executes it in dir in fs, or the closest existing parent of dir, and a
+// function that removes the temporary file. name is used to choose the
+// temporary file's extension.
+func scriptCmd(fs vfs.FS, name string, contents []byte, dir string) (*exec.Cmd, func(), error) {
+	// dir may not exist yet, for example when a script with the before
+	// attribute is run, so fall back to its closest existing parent.
+	for {
+		if _, err := fs.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	rawDir, err := fs.RawPath(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Write the temporary script file. Put the randomness on the front of the
+	// filename to preserve any file extension for Windows scripts.
+	f, err := ioutil.TempFile("", "*."+filepath.Base(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(f.Name())
+	}
+	if err := os.Chmod(f.Name(), 0700); err != nil {
+		_ = f.Close()
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := f.Write(contents); err != nil {
+		_ = f.Close()
+		cleanup()
+		return nil, nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	//nolint:gosec
+	c := exec.Command(f.Name())
+	c.Dir = rawDir
+	return c, cleanup, nil
+}
@@ -0,0 +1,81 @@
+// +build !windows
+
+package chezmoi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vfs "github.com/twpayne/go-vfs"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func TestScriptApplyEncrypted(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "chezmoi")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	// gpg is a fake gpg that decrypts by removing the first line.
+	gpg := filepath.Join(tempDir, "gpg")
+	require.NoError(t, ioutil.WriteFile(gpg, []byte(`#!/bin/sh
+while [ $# -gt 1 ]; do
+	case "$1" in
+	--output) output="$2"; shift ;;
+	esac
+	shift
+done
+sed 1d "$1" >"$output"
+`), 0o700))
+
+	evidence := filepath.Join(tempDir, "evidence")
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.config/chezmoi":      &vfst.Dir{Perm: 0o755},
+		"/src/run_encrypted_once_install": "-----BEGIN PGP MESSAGE-----\n#!/bin/sh\necho install >>" + evidence + "\n",
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithDestDir("/home/user"),
+		WithGPG(&GPG{
+			Command: gpg,
+		}),
+		WithSourceDir("/src"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	script, ok := ts.Entries["install"].(*Script)
+	require.True(t, ok)
+	assert.True(t, script.Encrypted)
+	assert.True(t, script.Once)
+
+	contents, err := script.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho install >>"+evidence+"\n", string(contents))
+
+	persistentState, err := NewBoltPersistentState(fs, "/home/user/.config/chezmoi/chezmoistate.boltdb", vfst.DefaultUmask, nil)
+	require.NoError(t, err)
+	defer persistentState.Close()
+	applyOptions := &ApplyOptions{
+		DestDir:           ts.DestDir,
+		Ignore:            ts.TargetIgnore.Match,
+		PersistentState:   persistentState,
+		ScriptStateBucket: []byte("script"),
+		Stdout:            &bytes.Buffer{},
+	}
+	// Apply twice to check that the decrypted script is only run once.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, ts.Apply(fs, NewFSMutator(fs), false, applyOptions))
+	}
+	vfst.RunTests(t, vfs.OSFS, "",
+		vfst.TestPath(evidence,
+			vfst.TestContentsString("install\n"),
+		),
+	)
+}
@@ -0,0 +1,154 @@
+package chezmoi
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+
+	vfs "github.com/twpayne/go-vfs"
+)
+
+// Status codes. The first letter describes changes to the target's existence,
+// type, or contents and the second letter describes changes to its mode or
+// ownership.
+const (
+	StatusAdded       = "A "
+	StatusModeChanged = " M"
+	StatusModified    = "M "
+	StatusRemoved     = "D "
+	StatusScriptRun   = "R "
+	StatusTypeChanged = "T "
+)
+
+// A TargetStatus is the status of a target.
+type TargetStatus struct {
+	Path string `json:"path" yaml:"path"`
+	Code string `json:"code" yaml:"code"`
+}
+
+// A StatusMutator wraps another Mutator and records the status of each target
+// that its mutating methods are called with. fs is used to determine whether
+// targets already exist.
+type StatusMutator struct {
+	fs    vfs.FS
+	m     Mutator
+	codes map[string][2]byte
+}
+
+// NewStatusMutator returns a new StatusMutator.
+func NewStatusMutator(fs vfs.FS, m Mutator) *StatusMutator {
+	return &StatusMutator{
+		fs:    fs,
+		m:     m,
+		codes: make(map[string][2]byte),
+	}
+}
+
+// Chmod implements Mutator.Chmod.
+func (m *StatusMutator) Chmod(name string, mode os.FileMode) error {
+	m.setMetadataChanged(name)
+	return m.m.Chmod(name, mode)
+}
+
+// IdempotentCmdOutput implements Mutator.IdempotentCmdOutput.
+func (m *StatusMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return m.m.IdempotentCmdOutput(cmd)
+}
+
+// Lchown implements Mutator.Lchown.
+func (m *StatusMutator) Lchown(name string, uid, gid int) error {
+	m.setMetadataChanged(name)
+	return m.m.Lchown(name, uid, gid)
+}
+
+// Mkdir implements Mutator.Mkdir.
+func (m *StatusMutator) Mkdir(name string, perm os.FileMode) error {
+	m.setWritten(name, os.ModeDir|perm)
+	return m.m.Mkdir(name, perm)
+}
+
+// RemoveAll implements Mutator.RemoveAll.
+func (m *StatusMutator) RemoveAll(name string) error {
+	m.codes[name] = [2]byte{StatusRemoved[0], ' '}
+	return m.m.RemoveAll(name)
+}
+
+// Rename implements Mutator.Rename.
+func (m *StatusMutator) Rename(oldpath, newpath string) error {
+	return m.m.Rename(oldpath, newpath)
+}
+
+// RunCmd implements Mutator.RunCmd.
+func (m *StatusMutator) RunCmd(cmd *exec.Cmd) error {
+	return m.m.RunCmd(cmd)
+}
+
+// RunScript implements Mutator.RunScript.
+func (m *StatusMutator) RunScript(name, dir string, data []byte) error {
+	m.codes[name] = [2]byte{StatusScriptRun[0], ' '}
+	return m.m.RunScript(name, dir, data)
+}
+
+// Stat implements Mutator.Stat.
+func (m *StatusMutator) Stat(name string) (os.FileInfo, error) {
+	return m.m.Stat(name)
+}
+
+// Statuses returns the statuses of all targets with changes, sorted by path.
+func (m *StatusMutator) Statuses() []*TargetStatus {
+	statuses := make([]*TargetStatus, 0, len(m.codes))
+	for name, code := range m.codes {
+		statuses = append(statuses, &TargetStatus{
+			Path: name,
+			Code: string(code[:]),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Path < statuses[j].Path
+	})
+	return statuses
+}
+
+// WriteFile implements Mutator.WriteFile.
+func (m *StatusMutator) WriteFile(name string, data []byte, perm os.FileMode, currData []byte) error {
+	m.setWritten(name, perm)
+	return m.m.WriteFile(name, data, perm, currData)
+}
+
+// WriteSymlink implements Mutator.WriteSymlink.
+func (m *StatusMutator) WriteSymlink(oldname, newname string) error {
+	m.setWritten(newname, os.ModeSymlink)
+	return m.m.WriteSymlink(oldname, newname)
+}
+
+// setMetadataChanged records that the mode or ownership of name changed,
+// unless name is otherwise added, removed, or replaced.
+func (m *StatusMutator) setMetadataChanged(name string) {
+	code, ok := m.codes[name]
+	if !ok {
+		code = [2]byte{' ', ' '}
+	}
+	if code[0] == ' ' || code[0] == StatusModified[0] {
+		code[1] = StatusModeChanged[1]
+	}
+	m.codes[name] = code
+}
+
+// setWritten records that name is written with mode, which includes its type.
+func (m *StatusMutator) setWritten(name string, mode os.FileMode) {
+	if code, ok := m.codes[name]; ok && code[0] == StatusRemoved[0] {
+		m.codes[name] = [2]byte{StatusTypeChanged[0], ' '}
+		return
+	}
+	info, err := m.fs.Lstat(name)
+	switch {
+	case err != nil:
+		m.codes[name] = [2]byte{StatusAdded[0], ' '}
+	case info.Mode()&os.ModeType != mode&os.ModeType:
+		m.codes[name] = [2]byte{StatusTypeChanged[0], ' '}
+	case mode&os.ModeType == 0 && info.Mode().Perm() != mode.Perm():
+		m.codes[name] = [2]byte{StatusModified[0], StatusModeChanged[1]}
+	default:
+		m.codes[name] = [2]byte{StatusModified[0], ' '}
+	}
+}
@@ -14,17 +14,21 @@ type Symlink struct {
 	sourceName       string
 	targetName       string
 	Template         bool
+	Ownership        Ownership
 	linkname         string
 	linknameErr      error
 	evaluateLinkname func() (string, error)
 }
 
 type symlinkConcreteValue struct {
-	Type       string `json:"type" yaml:"type"`
-	SourcePath string `json:"sourcePath" yaml:"sourcePath"`
-	TargetPath string `json:"targetPath" yaml:"targetPath"`
-	Template   bool   `json:"template" yaml:"template"`
-	Linkname   string `json:"linkname" yaml:"linkname"`
+	Type       string   `json:"type" yaml:"type"`
+	SourcePath string   `json:"sourcePath" yaml:"sourcePath"`
+	TargetPath string   `json:"targetPath" yaml:"targetPath"`
+	Template   bool     `json:"template" yaml:"template"`
+	Linkname   string   `json:"linkname" yaml:"linkname"`
+	Owner      string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Group      string   `json:"group,omitempty" yaml:"group,omitempty"`
+	Overrides  []string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
 }
 
 // AppendAllEntries appends all f to allEntries.
@@ -37,6 +41,9 @@ func (s *Symlink) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *A
 	if applyOptions.Ignore(s.targetName) {
 		return nil
 	}
+	if ok, err := applyOptions.confirm(fs, s, filepath.Join(applyOptions.DestDir, s.targetName), follow); err != nil || !ok {
+		return err
+	}
 	target, err := s.Linkname()
 	if err != nil {
 		return err
@@ -50,7 +57,7 @@ func (s *Symlink) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *A
 	}
 	switch {
 	case err == nil && target == "":
-		return mutator.RemoveAll(targetPath)
+		return removeTarget(fs, mutator, targetPath, applyOptions)
 	case os.IsNotExist(err) && target == "":
 		return nil
 	case err == nil && info.Mode()&os.ModeType == os.ModeSymlink:
@@ -59,18 +66,29 @@ func (s *Symlink) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *A
 			return err
 		}
 		if currentTarget == target {
-			return nil
+			return applyOwnership(fs, mutator, targetPath, s.Ownership)
 		}
 	case err == nil:
 	case os.IsNotExist(err):
 	default:
 		return err
 	}
-	return mutator.WriteSymlink(target, targetPath)
+	// Writing the symlink replaces whatever is at targetPath.
+	paths, err := applyOptions.fileStatePaths(fs, targetPath)
+	if err != nil {
+		return err
+	}
+	if err := mutator.WriteSymlink(target, targetPath); err != nil {
+		return err
+	}
+	if err := applyOptions.deleteFileStates(paths); err != nil {
+		return err
+	}
+	return applyOwnership(fs, mutator, targetPath, s.Ownership)
 }
 
 // ConcreteValue implements Entry.ConcreteValue.
-func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir string, umask os.FileMode, recursive bool) (interface{}, error) {
+func (s *Symlink) ConcreteValue(ignore func(string) bool, sourcePaths func(Entry) []string, umask os.FileMode, recursive bool) (interface{}, error) {
 	if ignore(s.targetName) {
 		return nil, nil
 	}
@@ -78,12 +96,16 @@ func (s *Symlink) ConcreteValue(ignore func(string) bool, sourceDir string, umas
 	if err != nil {
 		return nil, err
 	}
+	paths := sourcePaths(s)
 	return &symlinkConcreteValue{
 		Type:       "symlink",
-		SourcePath: filepath.Join(sourceDir, s.SourceName()),
+		SourcePath: paths[0],
 		TargetPath: s.TargetName(),
 		Template:   s.Template,
 		Linkname:   linkname,
+		Owner:      s.Ownership.Owner,
+		Group:      s.Ownership.Group,
+		Overrides:  paths[1:],
 	}, nil
 }
 
@@ -116,8 +138,8 @@ func (s *Symlink) TargetName() string {
 }
 
 // archive writes s to w.
-func (s *Symlink) archive(w *tar.Writer, ignore func(string) bool, headerTemplate *tar.Header, umask os.FileMode) error {
-	if ignore(s.targetName) {
+func (s *Symlink) archive(w ArchiveWriter, ignore func(string) bool, include EntryTypeSet, headerTemplate *tar.Header, umask os.FileMode, warn func(string)) error {
+	if !include.Has(EntryTypeSymlinks) || ignore(s.targetName) {
 		return nil
 	}
 	linkname, err := s.Linkname()
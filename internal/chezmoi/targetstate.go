@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -21,8 +22,23 @@ import (
 // DefaultTemplateOptions are the default template options.
 var DefaultTemplateOptions = []string{"missingkey=error"}
 
+// A Mode is a mode of applying regular files.
+type Mode string
+
+// Modes.
+const (
+	// ModeFile writes regular files to the destination directory.
+	ModeFile Mode = "file"
+	// ModeSymlink creates symlinks from the destination directory to regular
+	// files in the source directory, where possible.
+	ModeSymlink Mode = "symlink"
+)
+
 const (
+	attributesName   = ".chezmoiattributes"
+	dataName         = ".chezmoidata"
 	ignoreName       = ".chezmoiignore"
+	keepName         = ".keep"
 	removeName       = ".chezmoiremove"
 	templatesDirName = ".chezmoitemplates"
 	versionName      = ".chezmoiversion"
@@ -41,8 +57,10 @@ type AddOptions struct {
 // An ImportTAROptions contains options for TargetState.ImportTAR.
 type ImportTAROptions struct {
 	DestinationDir  string
+	Encrypt         bool
 	Exact           bool
 	StripComponents int
+	Template        bool
 }
 
 // A PopulateOptions contains options for TargetState.Populate.
@@ -52,23 +70,50 @@ type PopulateOptions struct {
 
 // A TargetState represents the root target state.
 type TargetState struct {
-	DestDir         string
-	Entries         map[string]Entry
-	GPG             *GPG
-	MinVersion      *semver.Version
-	SourceDir       string
-	TargetIgnore    *PatternSet
-	TargetRemove    *PatternSet
-	TemplateData    map[string]interface{}
-	TemplateFuncs   template.FuncMap
-	TemplateOptions []string
-	Templates       map[string]*template.Template
-	Umask           os.FileMode
+	BaseSourceDirs    []string
+	BlockBegin        string
+	BlockEnd          string
+	DestDir           string
+	Entries           map[string]Entry
+	Formats           map[string]Format
+	GPG               *GPG
+	MinVersion        *semver.Version
+	Mode              Mode
+	SourceDir         string
+	TargetIgnore      *PatternSet
+	TargetRemove      *PatternSet
+	TemplateData      map[string]interface{}
+	TemplateFuncs     template.FuncMap
+	TemplateOptions   []string
+	Templates         map[string]*template.Template
+	Umask             os.FileMode
+	configData        map[string]interface{}
+	sourceData        interface{}
+	includeStack      []string // includeStack is the names of the templates being executed by includeTemplate.
+	layerDirs         map[Entry]string
+	overrides         map[Entry][]string
+	ownershipPatterns []*ownershipPattern
 }
 
 // A TargetStateOption sets an option on a TargeState.
 type TargetStateOption func(*TargetState)
 
+// WithBaseSourceDirs sets the source directories that are populated, in order,
+// before the source directory.
+func WithBaseSourceDirs(baseSourceDirs []string) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.BaseSourceDirs = baseSourceDirs
+	}
+}
+
+// WithBlockMarkers sets the begin and end marker lines of managed blocks.
+func WithBlockMarkers(begin, end string) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.BlockBegin = begin
+		ts.BlockEnd = end
+	}
+}
+
 // WithDestDir sets DestDir.
 func WithDestDir(destDir string) TargetStateOption {
 	return func(ts *TargetState) {
@@ -83,6 +128,14 @@ func WithEntries(entries map[string]Entry) TargetStateOption {
 	}
 }
 
+// WithFormats sets the formats used by files with the merge attribute, keyed
+// by name.
+func WithFormats(formats map[string]Format) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.Formats = formats
+	}
+}
+
 // WithGPG sets the GPG options.
 func WithGPG(gpg *GPG) TargetStateOption {
 	return func(ts *TargetState) {
@@ -97,6 +150,13 @@ func WithMinVersion(minVersion *semver.Version) TargetStateOption {
 	}
 }
 
+// WithMode sets the mode.
+func WithMode(mode Mode) TargetStateOption {
+	return func(ts *TargetState) {
+		ts.Mode = mode
+	}
+}
+
 // WithSourceDir sets the source directory.
 func WithSourceDir(sourceDir string) TargetStateOption {
 	return func(ts *TargetState) {
@@ -156,7 +216,10 @@ func WithUmask(umask os.FileMode) TargetStateOption {
 // NewTargetState creates a new TargetState with the given options.
 func NewTargetState(options ...TargetStateOption) *TargetState {
 	ts := &TargetState{
+		BlockBegin:      DefaultBlockBegin,
+		BlockEnd:        DefaultBlockEnd,
 		Entries:         make(map[string]Entry),
+		Mode:            ModeFile,
 		TargetIgnore:    NewPatternSet(),
 		TargetRemove:    NewPatternSet(),
 		TemplateOptions: DefaultTemplateOptions,
@@ -219,6 +282,20 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 		parentDir := parentEntry.(*Dir)
 		parentDirSourceName = parentDir.sourceName
 		entries = parentDir.Entries
+		// New entries are always added to ts.SourceDir, so create the parent
+		// directory there if it is only in a base source directory. This also
+		// creates any of its own parent directories that are only in a base
+		// source directory, so they are now in ts.SourceDir too.
+		if !ts.inSourceDir(parentDir) {
+			if err := vfs.MkdirAll(mutator, filepath.Join(ts.SourceDir, parentDirSourceName), 0777&^ts.Umask); err != nil {
+				return err
+			}
+			for dirName := parentDirName; dirName != "."; dirName = filepath.Dir(dirName) {
+				if dir, err := ts.findEntry(dirName); err == nil {
+					delete(ts.layerDirs, dir)
+				}
+			}
+		}
 	}
 
 	switch {
@@ -246,6 +323,8 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 			switch {
 			case os.IsNotExist(err):
 				return nil
+			case err == nil && !ts.inSourceDir(entry):
+				return nil
 			case err == nil:
 				return mutator.RemoveAll(filepath.Join(ts.SourceDir, entry.SourceName()))
 			default:
@@ -279,6 +358,16 @@ func (ts *TargetState) Add(fs vfs.FS, addOptions AddOptions, targetPath string,
 		if err != nil {
 			return err
 		}
+		// A symlink to the source file of a linked file is already managed.
+		if file, ok := entries[filepath.Base(targetName)].(*File); ok && file.linkable() {
+			fileLinkname, err := file.Linkname(ts.SourcePath(file), targetPath)
+			if err != nil {
+				return err
+			}
+			if linkname == fileLinkname {
+				return nil
+			}
+		}
 		return ts.addSymlink(targetName, entries, parentDirSourceName, linkname, mutator)
 	default:
 		return fmt.Errorf("%s: not a regular file, directory, or symlink", targetName)
@@ -294,68 +383,87 @@ func (ts *TargetState) AllEntries() []Entry {
 	return allEntries
 }
 
-// Apply ensures that ts.DestDir in fs matches ts.
+// Apply ensures that ts.DestDir in fs matches ts. Scripts with the before
+// attribute are run before any other entry is applied and scripts with the
+// after attribute are run after all other entries have been applied.
 func (ts *TargetState) Apply(fs vfs.FS, mutator Mutator, follow bool, applyOptions *ApplyOptions) error {
-	if applyOptions.Remove {
-		// Build a set of targets to remove.
-		targetsToRemove := make(map[string]struct{})
-		includes := make([]string, 0, len(ts.TargetRemove.includes))
-		for include := range ts.TargetRemove.includes {
-			includes = append(includes, include)
-		}
-		for _, include := range includes {
-			matches, err := doublestar.GlobOS(fs, filepath.Join(ts.DestDir, include))
-			if err != nil {
-				return err
-			}
-			for _, match := range matches {
-				relPath := strings.TrimPrefix(match, ts.DestDir+string(filepath.Separator))
-				// Don't remove targets that are ignored.
-				if ts.TargetIgnore.Match(relPath) {
-					continue
-				}
-				// Don't remove targets that are excluded from remove.
-				if !ts.TargetRemove.Match(relPath) {
-					continue
-				}
-				targetsToRemove[match] = struct{}{}
-			}
+	var beforeScripts, afterScripts []*Script
+	for _, script := range ts.allScripts(applyOptions.Ignore) {
+		switch {
+		case script.Before:
+			beforeScripts = append(beforeScripts, script)
+		case script.After:
+			afterScripts = append(afterScripts, script)
 		}
+	}
 
-		// FIXME check that the set of targets to remove does not intersect wth
-		// the list of all entries.
+	// Evaluate all entries and find the targets to remove before running any
+	// scripts, so that an error does not leave the scripts with the before
+	// attribute run but nothing else applied.
+	for _, entryName := range sortedEntryNames(ts.Entries) {
+		if err := ts.Entries[entryName].Evaluate(applyOptions.Ignore); err != nil {
+			return err
+		}
+	}
+	var targetsToRemove []string
+	if applyOptions.Remove {
+		var err error
+		targetsToRemove, err = ts.TargetsToRemove(fs)
+		if err != nil {
+			return err
+		}
+	}
 
-		// Remove targets in reverse order so we remove children before their
-		// parents.
-		sortedTargetsToRemove := make([]string, 0, len(targetsToRemove))
-		for target := range targetsToRemove {
-			sortedTargetsToRemove = append(sortedTargetsToRemove, target)
+	for _, script := range beforeScripts {
+		if err := script.Apply(fs, mutator, follow, applyOptions); err != nil {
+			return err
 		}
-		sort.Sort(sort.Reverse(sort.StringSlice(sortedTargetsToRemove)))
-		for _, target := range sortedTargetsToRemove {
-			if err := mutator.RemoveAll(target); err != nil {
+	}
+
+	for _, target := range targetsToRemove {
+		if applyOptions.Confirm != nil {
+			target := target
+			ok, err := applyOptions.Confirm(target, func(mutator Mutator) error {
+				return mutator.RemoveAll(target)
+			})
+			if err != nil {
 				return err
 			}
+			if !ok {
+				continue
+			}
+		}
+		if err := removeTarget(fs, mutator, target, applyOptions); err != nil {
+			return err
 		}
 	}
 
+	entryApplyOptions := *applyOptions
+	entryApplyOptions.skipPhaseScripts = true
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		if err := ts.Entries[entryName].Apply(fs, mutator, follow, applyOptions); err != nil {
+		if err := ts.Entries[entryName].Apply(fs, mutator, follow, &entryApplyOptions); err != nil {
+			return err
+		}
+	}
+
+	for _, script := range afterScripts {
+		if err := script.Apply(fs, mutator, follow, applyOptions); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Archive writes ts to w.
-func (ts *TargetState) Archive(w *tar.Writer, umask os.FileMode) error {
+// Archive writes the entries in ts of the types in include to w. warn is
+// called with a message for each entry that cannot be archived.
+func (ts *TargetState) Archive(w ArchiveWriter, include EntryTypeSet, umask os.FileMode, warn func(string)) error {
 	headerTemplate, err := ts.getTarHeaderTemplate()
 	if err != nil {
 		return err
 	}
 
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		if err := ts.Entries[entryName].archive(w, ts.TargetIgnore.Match, headerTemplate, umask); err != nil {
+		if err := ts.Entries[entryName].archive(w, ts.TargetIgnore.Match, include, headerTemplate, umask, warn); err != nil {
 			return err
 		}
 	}
@@ -366,7 +474,7 @@ func (ts *TargetState) Archive(w *tar.Writer, umask os.FileMode) error {
 func (ts *TargetState) ConcreteValue(recursive bool) (interface{}, error) {
 	var entryConcreteValues []interface{}
 	for _, entryName := range sortedEntryNames(ts.Entries) {
-		entryConcreteValue, err := ts.Entries[entryName].ConcreteValue(ts.TargetIgnore.Match, ts.SourceDir, ts.Umask, recursive)
+		entryConcreteValue, err := ts.Entries[entryName].ConcreteValue(ts.TargetIgnore.Match, ts.SourcePaths, ts.Umask, recursive)
 		if err != nil {
 			return nil, err
 		}
@@ -389,15 +497,12 @@ func (ts *TargetState) Evaluate() error {
 
 // ExecuteTemplateData returns the result of executing template data.
 func (ts *TargetState) ExecuteTemplateData(name string, data []byte) ([]byte, error) {
-	tmpl, err := template.New(name).Option(ts.TemplateOptions...).Funcs(ts.TemplateFuncs).Parse(string(data))
+	tmpl, err := ts.parseTemplate(name, data)
 	if err != nil {
 		return nil, err
 	}
-	for name, t := range ts.Templates {
-		tmpl, err = tmpl.AddParseTree(name, t.Tree)
-		if err != nil {
-			return nil, err
-		}
+	if err := ts.addTemplates(tmpl); err != nil {
+		return nil, err
 	}
 	output := &bytes.Buffer{}
 	if err = tmpl.ExecuteTemplate(output, name, ts.TemplateData); err != nil {
@@ -422,8 +527,14 @@ func (ts *TargetState) Get(fs vfs.Stater, target string) (Entry, error) {
 	return ts.findEntry(targetName)
 }
 
-// ImportTAR imports a tar archive.
-func (ts *TargetState) ImportTAR(r *tar.Reader, importTAROptions ImportTAROptions, mutator Mutator) error {
+// ImportTAR imports an archive. Missing parent directories are created, hard
+// links are imported as copies of the files that they link to, and the PAX
+// records written by Archive are imported as source attributes.
+func (ts *TargetState) ImportTAR(r ArchiveReader, importTAROptions ImportTAROptions, mutator Mutator) error {
+	// Record the contents of all regular files so that hard links to them
+	// can be imported as copies.
+	fileContents := make(map[string][]byte)
+	var dirTargetNames []string
 	for {
 		header, err := r.Next()
 		if err == io.EOF {
@@ -432,208 +543,357 @@ func (ts *TargetState) ImportTAR(r *tar.Reader, importTAROptions ImportTAROption
 			return err
 		}
 		switch header.Typeflag {
-		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink:
-			if err := ts.importHeader(r, importTAROptions, header, mutator); err != nil {
-				return err
-			}
+		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
 		case tar.TypeXGlobalHeader:
+			continue
 		default:
 			return fmt.Errorf("%s: unspported typeflag '%c'", header.Name, header.Typeflag)
 		}
-	}
-	return nil
-}
-
-// Populate walks fs from ts.SourceDir to populate ts.
-func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
-	return vfs.Walk(fs, ts.SourceDir, func(path string, info os.FileInfo, _ error) error {
-		relPath, err := filepath.Rel(ts.SourceDir, path)
+		targetName, err := ts.importTargetName(header.Name, importTAROptions)
 		if err != nil {
 			return err
 		}
-		if relPath == "." {
-			return nil
-		}
-		// Treat all files and directories beginning with "." specially.
-		if _, name := filepath.Split(relPath); strings.HasPrefix(name, ".") {
-			switch {
-			case info.Name() == ignoreName:
-				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
-				return ts.addPatterns(fs, ts.TargetIgnore, path, filepath.Join(dns...))
-			case info.Name() == removeName:
-				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
-				return ts.addPatterns(fs, ts.TargetRemove, path, filepath.Join(dns...))
-			case info.Name() == templatesDirName:
-				if err := ts.addTemplatesDir(fs, path); err != nil {
-					return err
-				}
-				return filepath.SkipDir
-			case info.Name() == versionName:
-				data, err := fs.ReadFile(path)
-				if err != nil {
-					return err
-				}
-				version, err := semver.NewVersion(strings.TrimSpace(string(data)))
-				if err != nil {
-					return err
-				}
-				if ts.MinVersion == nil || ts.MinVersion.LessThan(*version) {
-					ts.MinVersion = version
-				}
-				return nil
-			case info.IsDir():
-				// Don't recurse into ignored subdirectories.
-				return filepath.SkipDir
-			}
-			// Ignore all other files and directories.
-			return nil
+		if targetName == "." {
+			continue
 		}
-		switch {
-		case info.IsDir():
-			components := splitPathList(relPath)
-			das := parseDirNameComponents(components)
-			dns := dirNames(das)
-			targetName := filepath.Join(dns...)
-			entries, err := ts.findEntries(dns[:len(dns)-1])
+		var contents []byte
+		switch header.Typeflag {
+		case tar.TypeDir:
+			dirTargetNames = append(dirTargetNames, targetName)
+		case tar.TypeReg:
+			contents, err = ioutil.ReadAll(r)
 			if err != nil {
 				return err
 			}
-			da := das[len(das)-1]
-			entries[da.Name] = newDir(relPath, targetName, da.Exact, da.Perm)
-		case info.Mode().IsRegular():
-			psfp := parseSourceFilePath(relPath)
-			dns := dirNames(psfp.dirAttributes)
-			entries, err := ts.findEntries(dns)
-			if err != nil {
+			fileContents[path.Clean(header.Name)] = contents
+		case tar.TypeLink:
+			var ok bool
+			contents, ok = fileContents[path.Clean(header.Linkname)]
+			if !ok {
+				return fmt.Errorf("%s: hard link to unknown file %s", header.Name, header.Linkname)
+			}
+			linkHeader := *header
+			linkHeader.Typeflag = tar.TypeReg
+			linkHeader.Size = int64(len(contents))
+			header = &linkHeader
+		}
+		if err := ts.importHeader(targetName, header, contents, importTAROptions, mutator); err != nil {
+			return err
+		}
+	}
+
+	// Create a .keep file in each imported directory that is still empty so
+	// that it is managed by git.
+	for _, dirTargetName := range dirTargetNames {
+		entry, err := ts.findEntry(dirTargetName)
+		if err != nil {
+			return err
+		}
+		if dir, ok := entry.(*Dir); ok && len(dir.Entries) == 0 && ts.inSourceDir(dir) {
+			if err := mutator.WriteFile(filepath.Join(ts.SourceDir, dir.sourceName, keepName), nil, 0666&^ts.Umask, nil); err != nil {
 				return err
 			}
+		}
+	}
+
+	return nil
+}
+
+// PopulateTemplateData merges the data in all template data files in all
+// source directories into ts.TemplateData, without populating any entries or
+// executing any templates. Unlike Populate, it does not skip the template data
+// files in ignored directories.
+func (ts *TargetState) PopulateTemplateData(fs vfs.FS) error {
+	for _, sourceDir := range ts.sourceDirs() {
+		if err := walk(fs, sourceDir, func(path string, info os.FileInfo, err error) error {
 			switch {
-			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == 0 || psfp.scriptAttributes != nil:
-				readFile := func() ([]byte, error) {
-					return fs.ReadFile(path)
-				}
-				evaluateContents := readFile
-				if psfp.fileAttributes != nil && psfp.fileAttributes.Encrypted {
-					prevEvaluateContents := evaluateContents
-					evaluateContents = func() ([]byte, error) {
-						ciphertext, err := prevEvaluateContents()
-						if err != nil {
-							return nil, err
-						}
-						return ts.GPG.Decrypt(path, ciphertext)
-					}
-				}
-				if psfp.fileAttributes != nil && psfp.fileAttributes.Template || psfp.scriptAttributes != nil && psfp.scriptAttributes.Template {
-					if options == nil || options.ExecuteTemplates {
-						prevEvaluateContents := evaluateContents
-						evaluateContents = func() ([]byte, error) {
-							data, err := prevEvaluateContents()
-							if err != nil {
-								return nil, err
-							}
-							return ts.ExecuteTemplateData(path, data)
-						}
-					}
-				}
-				switch {
-				case psfp.fileAttributes != nil:
-					entry := &File{
-						sourceName:       relPath,
-						targetName:       filepath.Join(append(dns, psfp.fileAttributes.Name)...),
-						Empty:            psfp.fileAttributes.Empty,
-						Encrypted:        psfp.fileAttributes.Encrypted,
-						Perm:             psfp.fileAttributes.Mode.Perm(),
-						Template:         psfp.fileAttributes.Template,
-						evaluateContents: evaluateContents,
-					}
-					entries[psfp.fileAttributes.Name] = entry
-				case psfp.scriptAttributes != nil:
-					entry := &Script{
-						sourceName:       relPath,
-						targetName:       filepath.Join(append(dns, psfp.scriptAttributes.Name)...),
-						Once:             psfp.scriptAttributes.Once,
-						Template:         psfp.scriptAttributes.Template,
-						evaluateContents: evaluateContents,
-					}
-					entries[psfp.scriptAttributes.Name] = entry
-				}
-			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == os.ModeSymlink:
-				evaluateLinkname := func() (string, error) {
-					data, err := fs.ReadFile(path)
-					return string(data), err
-				}
-				if psfp.fileAttributes.Template {
-					evaluateLinkname = func() (string, error) {
-						data, err := ts.executeTemplate(fs, path)
-						return string(data), err
-					}
-				}
-				entry := &Symlink{
-					sourceName:       relPath,
-					targetName:       filepath.Join(append(dns, psfp.fileAttributes.Name)...),
-					Template:         psfp.fileAttributes.Template,
-					evaluateLinkname: evaluateLinkname,
-				}
-				entries[psfp.fileAttributes.Name] = entry
+			case os.IsNotExist(err) && path == sourceDir:
+				return nil
+			case err != nil:
+				return err
+			case !info.IsDir():
+				return nil
+			case path != sourceDir && strings.HasPrefix(info.Name(), "."):
+				return filepath.SkipDir
 			default:
-				return fmt.Errorf("%s: unsupported file type", path)
+				return ts.addTemplateDataDir(fs, path)
 			}
-		default:
-			return fmt.Errorf("%s: unsupported file type", path)
+		}); err != nil {
+			return err
 		}
-		return nil
-	})
+	}
+	return nil
 }
 
-func (ts *TargetState) addDir(targetName string, entries map[string]Entry, parentDirSourceName string, exact bool, perm os.FileMode, createKeepFile bool, mutator Mutator) error {
-	name := filepath.Base(targetName)
-	if entry, ok := entries[name]; ok {
-		if _, ok = entry.(*Dir); !ok {
-			return fmt.Errorf("%s: already added and not a directory", targetName)
+// Populate walks fs from each of ts.BaseSourceDirs, in order, and then from
+// ts.SourceDir to populate ts. Entries, ignore and remove patterns, templates,
+// and template data in later source directories override those in earlier
+// ones.
+func (ts *TargetState) Populate(fs vfs.FS, options *PopulateOptions) error {
+	for _, sourceDir := range ts.sourceDirs() {
+		if err := ts.populateSourceDir(fs, sourceDir, options); err != nil {
+			return err
 		}
-		return nil
-	}
-	sourceName := DirAttributes{
-		Name:  name,
-		Exact: exact,
-		Perm:  perm,
-	}.SourceName()
-	if parentDirSourceName != "" {
-		sourceName = filepath.Join(parentDirSourceName, sourceName)
 	}
-	dir := newDir(sourceName, targetName, exact, perm)
-	if err := mutator.Mkdir(filepath.Join(ts.SourceDir, sourceName), 0777&^ts.Umask); err != nil {
-		return err
+	if len(ts.ownershipPatterns) == 0 {
+		return nil
 	}
-	if createKeepFile {
-		if err := mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName, ".keep"), nil, 0666&^ts.Umask, nil); err != nil {
-			return err
+	for _, entry := range ts.AllEntries() {
+		switch entry := entry.(type) {
+		case *Dir:
+			entry.Ownership = ts.ownership(AsDir(entry.targetName))
+		case *File:
+			entry.Ownership = ts.ownership(entry.targetName)
+		case *Symlink:
+			entry.Ownership = ts.ownership(entry.targetName)
 		}
 	}
-	entries[name] = dir
 	return nil
 }
 
-func (ts *TargetState) addFile(targetName string, entries map[string]Entry, parentDirSourceName string, info os.FileInfo, perm os.FileMode, encrypted, template bool, contents []byte, mutator Mutator) error {
-	name := filepath.Base(targetName)
-	var existingFile *File
-	var existingContents []byte
-	if entry, ok := entries[name]; ok {
-		existingFile, ok = entry.(*File)
-		if !ok {
-			return fmt.Errorf("%s: already added and not a regular file", targetName)
-		}
-		var err error
-		existingContents, err = existingFile.Contents()
-		if err != nil {
-			return err
-		}
+// SourcePath returns the path of entry's source file or directory.
+func (ts *TargetState) SourcePath(entry Entry) string {
+	sourceDir, ok := ts.layerDirs[entry]
+	if !ok {
+		sourceDir = ts.SourceDir
 	}
+	return filepath.Join(sourceDir, entry.SourceName())
+}
 
-	empty := info.Size() == 0
-	sourceName := FileAttributes{
+// SourcePaths returns the source path of entry followed by the source paths of
+// the entries in earlier source directories that it overrides.
+func (ts *TargetState) SourcePaths(entry Entry) []string {
+	return append([]string{ts.SourcePath(entry)}, ts.overrides[entry]...)
+}
+
+// removeCandidates returns the paths in fs of everything in ts.DestDir and in
+// every directory managed by ts.
+func (ts *TargetState) removeCandidates(fs vfs.FS) ([]string, error) {
+	dirs := []string{ts.DestDir}
+	for _, entry := range ts.AllEntries() {
+		if _, ok := entry.(*Dir); ok {
+			dirs = append(dirs, filepath.Join(ts.DestDir, entry.TargetName()))
+		}
+	}
+	candidates := []string{}
+	for _, dir := range dirs {
+		info, err := fs.Lstat(dir)
+		switch {
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			return nil, err
+		case !info.IsDir():
+			continue
+		}
+		infos, err := fs.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			candidates = append(candidates, filepath.Join(dir, info.Name()))
+		}
+	}
+	return candidates, nil
+}
+
+// TargetsToRemove returns the targets in fs that match ts.TargetRemove, in the
+// order in which they should be removed. It returns an error if any target to
+// remove is, or contains, an entry in ts.
+func (ts *TargetState) TargetsToRemove(fs vfs.FS) ([]string, error) {
+	// Build a set of targets to remove, recording the pattern that matched
+	// each.
+	targetsToRemove := make(map[string]string)
+	var candidates []string
+	for _, include := range ts.TargetRemove.includes() {
+		pattern := filepath.Join(ts.DestDir, include.pattern)
+		var matches []string
+		if !include.anchored {
+			// Globbing unanchored patterns would walk the whole destination
+			// directory, so match them against the targets in the directories
+			// that ts manages instead.
+			if candidates == nil {
+				var err error
+				if candidates, err = ts.removeCandidates(fs); err != nil {
+					return nil, err
+				}
+			}
+			for _, candidate := range candidates {
+				if ok, _ := doublestar.PathMatch(pattern, candidate); ok {
+					matches = append(matches, candidate)
+				}
+			}
+		} else {
+			var err error
+			if matches, err = doublestar.GlobOS(fs, pattern); err != nil {
+				return nil, err
+			}
+		}
+		for _, match := range matches {
+			// Never remove a source directory or anything in it.
+			if ts.isInOrContainsSourceDir(match) {
+				continue
+			}
+			relPath := strings.TrimPrefix(match, ts.DestDir+string(filepath.Separator))
+			info, err := fs.Lstat(match)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				relPath = AsDir(relPath)
+			}
+			// Don't remove targets that are ignored.
+			if ts.TargetIgnore.Match(relPath) {
+				continue
+			}
+			// Don't remove targets that are excluded from remove.
+			if !ts.TargetRemove.Match(relPath) {
+				continue
+			}
+			if _, ok := targetsToRemove[match]; !ok {
+				targetsToRemove[match] = include.text
+			}
+		}
+	}
+
+	// Remove targets in reverse order so we remove children before their
+	// parents.
+	sortedTargetsToRemove := make([]string, 0, len(targetsToRemove))
+	for target := range targetsToRemove {
+		sortedTargetsToRemove = append(sortedTargetsToRemove, target)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sortedTargetsToRemove)))
+
+	// Check that no target to remove is, or contains, an entry. Scripts do
+	// not have targets so they are not checked.
+	var entryTargets []string
+	for _, entry := range ts.AllEntries() {
+		if _, ok := entry.(*Script); ok {
+			continue
+		}
+		entryTargets = append(entryTargets, filepath.Join(ts.DestDir, entry.TargetName()))
+	}
+	var conflicts []string
+	for i := len(sortedTargetsToRemove) - 1; i >= 0; i-- {
+		target := sortedTargetsToRemove[i]
+		for _, entryTarget := range entryTargets {
+			if entryTarget == target || strings.HasPrefix(entryTarget, target+string(filepath.Separator)) {
+				conflicts = append(conflicts, fmt.Sprintf("%s (matched by %s)", target, targetsToRemove[target]))
+				break
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%s: refusing to remove managed targets: %s", removeName, strings.Join(conflicts, "; "))
+	}
+
+	return sortedTargetsToRemove, nil
+}
+
+func (ts *TargetState) addBlock(targetName string, entries map[string]Entry, parentDirSourceName string, template bool, contents []byte, mutator Mutator) error {
+	name := filepath.Base(targetName)
+	var existingBlock *Block
+	var existingContents []byte
+	// An entry in a base source directory is overridden, not replaced.
+	if entry, ok := entries[name]; ok && ts.inSourceDir(entry) {
+		existingBlock, ok = entry.(*Block)
+		if !ok {
+			return fmt.Errorf("%s: already added and not a block", targetName)
+		}
+		var err error
+		existingContents, err = existingBlock.Contents()
+		if err != nil {
+			return err
+		}
+	}
+	sourceName := BlockAttributes{
+		Name:     name,
+		Template: template,
+	}.SourceName()
+	if parentDirSourceName != "" {
+		sourceName = filepath.Join(parentDirSourceName, sourceName)
+	}
+	block := &Block{
+		sourceName: sourceName,
+		targetName: targetName,
+		Begin:      ts.BlockBegin,
+		End:        ts.BlockEnd,
+		Template:   template,
+		contents:   contents,
+	}
+	if existingBlock != nil && existingBlock.sourceName != block.sourceName {
+		if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingBlock.sourceName)); err != nil {
+			return err
+		}
+	}
+	entries[name] = block
+	return mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName), contents, 0666&^ts.Umask, existingContents)
+}
+
+func (ts *TargetState) addDir(targetName string, entries map[string]Entry, parentDirSourceName string, exact bool, perm os.FileMode, createKeepFile bool, mutator Mutator) error {
+	name := filepath.Base(targetName)
+	if entry, ok := entries[name]; ok {
+		if _, ok = entry.(*Dir); !ok {
+			return fmt.Errorf("%s: already added and not a directory", targetName)
+		}
+		return nil
+	}
+	sourceName := DirAttributes{
+		Name:  name,
+		Exact: exact,
+		Perm:  perm,
+	}.SourceName()
+	if parentDirSourceName != "" {
+		sourceName = filepath.Join(parentDirSourceName, sourceName)
+	}
+	dir := newDir(sourceName, targetName, exact, perm)
+	if err := mutator.Mkdir(filepath.Join(ts.SourceDir, sourceName), 0777&^ts.Umask); err != nil {
+		return err
+	}
+	if createKeepFile {
+		if err := mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName, keepName), nil, 0666&^ts.Umask, nil); err != nil {
+			return err
+		}
+	}
+	entries[name] = dir
+	return nil
+}
+
+func (ts *TargetState) addFile(targetName string, entries map[string]Entry, parentDirSourceName string, info os.FileInfo, perm os.FileMode, encrypted, template bool, contents []byte, mutator Mutator) error {
+	name := filepath.Base(targetName)
+	var existingFile *File
+	var existingContents []byte
+	create := false
+	link := false
+	// An entry in a base source directory is overridden, not replaced.
+	if entry, ok := entries[name]; ok && ts.inSourceDir(entry) {
+		existingFile, ok = entry.(*File)
+		if !ok {
+			return fmt.Errorf("%s: already added and not a regular file", targetName)
+		}
+		switch {
+		case existingFile.Modify:
+			return fmt.Errorf("%s: already added as a modify script", targetName)
+		case existingFile.Merge:
+			return fmt.Errorf("%s: already added as a merge file", targetName)
+		}
+		var err error
+		existingContents, err = existingFile.Contents()
+		if err != nil {
+			return err
+		}
+		create = existingFile.Create
+		// existingFile.Link is also set by the symlink mode, so only preserve
+		// the link attribute if it is in the source name.
+		link = ParseFileAttributes(filepath.Base(existingFile.sourceName)).Link
+	}
+
+	empty := info.Size() == 0
+	sourceName := FileAttributes{
 		Name:      name,
 		Mode:      perm,
+		Create:    create,
+		Link:      link,
 		Empty:     empty,
 		Encrypted: encrypted,
 		Template:  template,
@@ -644,6 +904,8 @@ func (ts *TargetState) addFile(targetName string, entries map[string]Entry, pare
 	file := &File{
 		sourceName: sourceName,
 		targetName: targetName,
+		Create:     create,
+		Link:       link || ts.Mode == ModeSymlink,
 		Empty:      empty,
 		Encrypted:  encrypted,
 		Perm:       perm,
@@ -672,7 +934,9 @@ func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath stri
 	}
 	dir := filepath.Dir(relPath)
 	s := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
 	for s.Scan() {
+		lineNumber++
 		text := s.Text()
 		if index := strings.IndexRune(text, '#'); index != -1 {
 			text = text[:index]
@@ -681,14 +945,8 @@ func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath stri
 		if text == "" {
 			continue
 		}
-		include := true
-		if strings.HasPrefix(text, "!") {
-			include = false
-			text = strings.TrimPrefix(text, "!")
-		}
-		pattern := filepath.Join(dir, text)
-		if err := ps.Add(pattern, include); err != nil {
-			return fmt.Errorf("%s: %w", path, err)
+		if err := ps.Add(dir, text); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
 		}
 	}
 	if err := s.Err(); err != nil {
@@ -697,11 +955,52 @@ func (ts *TargetState) addPatterns(fs vfs.FS, ps *PatternSet, path, relPath stri
 	return nil
 }
 
+func (ts *TargetState) addScript(targetName string, entries map[string]Entry, parentDirSourceName string, scriptAttributes ScriptAttributes, contents []byte, mutator Mutator) error {
+	name := filepath.Base(targetName)
+	var existingScript *Script
+	var existingContents []byte
+	// An entry in a base source directory is overridden, not replaced.
+	if entry, ok := entries[name]; ok && ts.inSourceDir(entry) {
+		existingScript, ok = entry.(*Script)
+		if !ok {
+			return fmt.Errorf("%s: already added and not a script", targetName)
+		}
+		var err error
+		existingContents, err = existingScript.Contents()
+		if err != nil {
+			return err
+		}
+	}
+	sourceName := scriptAttributes.SourceName()
+	if parentDirSourceName != "" {
+		sourceName = filepath.Join(parentDirSourceName, sourceName)
+	}
+	script := &Script{
+		sourceName: sourceName,
+		targetName: targetName,
+		Encrypted:  scriptAttributes.Encrypted,
+		Once:       scriptAttributes.Once,
+		OnChange:   scriptAttributes.OnChange,
+		Before:     scriptAttributes.Before,
+		After:      scriptAttributes.After,
+		Template:   scriptAttributes.Template,
+		contents:   contents,
+	}
+	if existingScript != nil && existingScript.sourceName != script.sourceName {
+		if err := mutator.RemoveAll(filepath.Join(ts.SourceDir, existingScript.sourceName)); err != nil {
+			return err
+		}
+	}
+	entries[name] = script
+	return mutator.WriteFile(filepath.Join(ts.SourceDir, sourceName), contents, 0666&^ts.Umask, existingContents)
+}
+
 func (ts *TargetState) addSymlink(targetName string, entries map[string]Entry, parentDirSourceName string, linkname string, mutator Mutator) error {
 	name := filepath.Base(targetName)
 	var existingSymlink *Symlink
 	var existingLinkname string
-	if entry, ok := entries[name]; ok {
+	// An entry in a base source directory is overridden, not replaced.
+	if entry, ok := entries[name]; ok && ts.inSourceDir(entry) {
 		existingSymlink, ok = entry.(*Symlink)
 		if !ok {
 			return fmt.Errorf("%s: already added and not a symlink", targetName)
@@ -739,6 +1038,53 @@ func (ts *TargetState) addSymlink(targetName string, entries map[string]Entry, p
 	return mutator.WriteFile(filepath.Join(ts.SourceDir, symlink.sourceName), []byte(symlink.linkname), 0666&^ts.Umask, []byte(existingLinkname))
 }
 
+// addTemplateDataDir merges the data in the template data files in the source
+// directory dir into ts.TemplateData, in the order in which they are found.
+// Data from later files takes precedence over data from earlier files, and the
+// data that ts.TemplateData was created with takes precedence over both.
+func (ts *TargetState) addTemplateDataDir(fs vfs.FS, dir string) error {
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() || !strings.HasPrefix(name, dataName+".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		format, ok := ts.Formats[formatName(name)]
+		if !ok {
+			return fmt.Errorf("%s: unsupported data format", path)
+		}
+		contents, err := fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var fileData map[string]interface{}
+		if err := format.Decode(contents, &fileData); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if ts.sourceData == nil {
+			ts.configData = copyData(ts.TemplateData).(map[string]interface{})
+			ts.sourceData = make(map[string]interface{})
+		}
+		ts.sourceData = deepMerge(ts.sourceData, normalizeKeys(fileData))
+		ts.TemplateData = deepMerge(copyData(ts.sourceData), copyData(ts.configData)).(map[string]interface{})
+	}
+	return nil
+}
+
+// addTemplates adds all templates in ts.Templates to tmpl.
+func (ts *TargetState) addTemplates(tmpl *template.Template) error {
+	for name, t := range ts.Templates {
+		if _, err := tmpl.AddParseTree(name, t.Tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ts *TargetState) addTemplatesDir(fs vfs.FS, path string) error {
 	prefix := filepath.ToSlash(path) + "/"
 	return vfs.Walk(fs, path, func(path string, info os.FileInfo, err error) error {
@@ -752,7 +1098,7 @@ func (ts *TargetState) addTemplatesDir(fs vfs.FS, path string) error {
 				return err
 			}
 			name := strings.TrimPrefix(filepath.ToSlash(path), prefix)
-			tmpl, err := template.New(name).Parse(string(contents))
+			tmpl, err := ts.parseTemplate(name, contents)
 			if err != nil {
 				return err
 			}
@@ -769,6 +1115,28 @@ func (ts *TargetState) addTemplatesDir(fs vfs.FS, path string) error {
 	})
 }
 
+// allScripts returns all Scripts in ts that are not in ignored directories, in
+// the order in which they would be applied.
+func (ts *TargetState) allScripts(ignore func(string) bool) []*Script {
+	var scripts []*Script
+	var appendScripts func(map[string]Entry)
+	appendScripts = func(entries map[string]Entry) {
+		for _, entryName := range sortedEntryNames(entries) {
+			switch entry := entries[entryName].(type) {
+			case *Dir:
+				if ignore(entry.targetName) {
+					continue
+				}
+				appendScripts(entry.Entries)
+			case *Script:
+				scripts = append(scripts, entry)
+			}
+		}
+	}
+	appendScripts(ts.Entries)
+	return scripts
+}
+
 func (ts *TargetState) executeTemplate(fs vfs.FS, path string) ([]byte, error) {
 	data, err := fs.ReadFile(path)
 	if err != nil {
@@ -777,6 +1145,49 @@ func (ts *TargetState) executeTemplate(fs vfs.FS, path string) ([]byte, error) {
 	return ts.ExecuteTemplateData(path, data)
 }
 
+// executeTemplateWithArgs returns the result of executing the template name
+// in ts.Templates with ts.TemplateData. The keys in each of args, in order,
+// replace the keys in ts.TemplateData. It returns an error if name is already
+// being executed, as the template would include itself forever.
+func (ts *TargetState) executeTemplateWithArgs(name string, args ...map[string]interface{}) (string, error) {
+	for _, includeName := range ts.includeStack {
+		if includeName == name {
+			return "", fmt.Errorf("%s: recursive includeTemplate (%s)", name, strings.Join(append(ts.includeStack, name), " -> "))
+		}
+	}
+	ts.includeStack = append(ts.includeStack, name)
+	defer func() {
+		ts.includeStack = ts.includeStack[:len(ts.includeStack)-1]
+	}()
+
+	data := make(map[string]interface{}, len(ts.TemplateData))
+	for key, value := range ts.TemplateData {
+		data[key] = value
+	}
+	for _, arg := range args {
+		for key, value := range arg {
+			data[key] = value
+		}
+	}
+	// Clone the named template, if it exists, to preserve the options set by
+	// its template directives.
+	tmpl := ts.newTemplate(name)
+	if t, ok := ts.Templates[name]; ok {
+		var err error
+		if tmpl, err = t.Clone(); err != nil {
+			return "", err
+		}
+	}
+	if err := ts.addTemplates(tmpl); err != nil {
+		return "", err
+	}
+	output := &strings.Builder{}
+	if err := tmpl.ExecuteTemplate(output, name, data); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
 func (ts *TargetState) findEntries(dirNames []string) (map[string]Entry, error) {
 	entries := ts.Entries
 	for i, dirName := range dirNames {
@@ -804,46 +1215,88 @@ func (ts *TargetState) findEntry(name string) (Entry, error) {
 	return entry, nil
 }
 
-func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptions, header *tar.Header, mutator Mutator) error {
-	targetPath := header.Name
-	if importTAROptions.StripComponents > 0 {
-		targetPath = filepath.Join(strings.Split(targetPath, string(os.PathSeparator))[importTAROptions.StripComponents:]...)
-	}
-	if importTAROptions.DestinationDir != "" {
-		targetPath = filepath.Join(importTAROptions.DestinationDir, targetPath)
-	} else {
-		targetPath = filepath.Join(ts.DestDir, targetPath)
+// importDir returns the source name and entries of the directory dirName,
+// creating it and any missing parent directories.
+func (ts *TargetState) importDir(dirName string, importTAROptions ImportTAROptions, mutator Mutator) (string, map[string]Entry, error) {
+	dirSourceName := ""
+	entries := ts.Entries
+	if dirName == "." {
+		return dirSourceName, entries, nil
 	}
-	targetName, err := filepath.Rel(ts.DestDir, targetPath)
+	rootName, err := ts.importTargetName("", importTAROptions)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	parentDirSourceName := ""
-	entries := ts.Entries
-	if parentDirName := filepath.Dir(targetName); parentDirName != "." {
-		parentEntry, err := ts.findEntry(parentDirName)
-		if err != nil {
-			return err
+	names := splitPathList(dirName)
+	for i, name := range names {
+		targetName := filepath.Join(names[:i+1]...)
+		if _, ok := entries[name]; !ok {
+			// Only directories in the archive are exact, not their parents.
+			exact := importTAROptions.Exact && (rootName == "." || targetName == rootName || strings.HasPrefix(targetName, rootName+string(filepath.Separator)))
+			if err := ts.addDir(targetName, entries, dirSourceName, exact, 0777&^ts.Umask, false, mutator); err != nil {
+				return "", nil, err
+			}
 		}
-		parentDir, ok := parentEntry.(*Dir)
+		dir, ok := entries[name].(*Dir)
 		if !ok {
-			return fmt.Errorf("%s: parent is not a directory", targetName)
+			return "", nil, fmt.Errorf("%s: not a directory", targetName)
 		}
-		parentDirSourceName = parentDir.sourceName
-		entries = parentDir.Entries
+		// New entries are always added to ts.SourceDir, so create the
+		// directory there if it is only in a base source directory.
+		if !ts.inSourceDir(dir) {
+			if err := vfs.MkdirAll(mutator, filepath.Join(ts.SourceDir, dir.sourceName), 0777&^ts.Umask); err != nil {
+				return "", nil, err
+			}
+			delete(ts.layerDirs, dir)
+		}
+		dirSourceName = dir.sourceName
+		entries = dir.Entries
 	}
+	return dirSourceName, entries, nil
+}
+
+// importHeader imports the archive entry described by header, with contents
+// contents, as targetName.
+func (ts *TargetState) importHeader(targetName string, header *tar.Header, contents []byte, importTAROptions ImportTAROptions, mutator Mutator) error {
+	parentDirSourceName, entries, err := ts.importDir(filepath.Dir(targetName), importTAROptions, mutator)
+	if err != nil {
+		return err
+	}
+	targetPath := filepath.Join(ts.DestDir, targetName)
 	switch header.Typeflag {
 	case tar.TypeDir:
 		perm := os.FileMode(header.Mode).Perm()
-		createKeepFile := false // FIXME don't assume that we don't need a keep file
-		return ts.addDir(targetName, entries, parentDirSourceName, importTAROptions.Exact, perm, createKeepFile, mutator)
+		return ts.addDir(targetName, entries, parentDirSourceName, importTAROptions.Exact, perm, false, mutator)
 	case tar.TypeReg:
+		switch header.PAXRecords[PAXRecordType] {
+		case PAXRecordTypeBlock:
+			contents = bytes.TrimPrefix(contents, []byte(ts.BlockBegin+"\n"))
+			contents = bytes.TrimSuffix(contents, []byte(ts.BlockEnd+"\n"))
+			return ts.addBlock(targetName, entries, parentDirSourceName, importTAROptions.Template, contents, mutator)
+		case PAXRecordTypeScript:
+			if importTAROptions.Encrypt {
+				if contents, err = ts.GPG.Encrypt(targetPath, contents); err != nil {
+					return err
+				}
+			}
+			scriptAttributes := ScriptAttributes{
+				Name:      filepath.Base(targetName),
+				Encrypted: importTAROptions.Encrypt,
+				Once:      header.PAXRecords[PAXRecordOnce] == "true",
+				OnChange:  header.PAXRecords[PAXRecordOnChange] == "true",
+				Before:    header.PAXRecords[PAXRecordBefore] == "true",
+				After:     header.PAXRecords[PAXRecordAfter] == "true",
+				Template:  importTAROptions.Template,
+			}
+			return ts.addScript(targetName, entries, parentDirSourceName, scriptAttributes, contents, mutator)
+		}
 		info := header.FileInfo()
-		contents, err := ioutil.ReadAll(r)
-		if err != nil {
-			return err
+		if importTAROptions.Encrypt {
+			if contents, err = ts.GPG.Encrypt(targetPath, contents); err != nil {
+				return err
+			}
 		}
-		return ts.addFile(targetName, entries, parentDirSourceName, info, info.Mode().Perm(), false, false, contents, mutator)
+		return ts.addFile(targetName, entries, parentDirSourceName, info, info.Mode().Perm(), importTAROptions.Encrypt, importTAROptions.Template, contents, mutator)
 	case tar.TypeSymlink:
 		linkname := header.Linkname
 		return ts.addSymlink(targetName, entries, parentDirSourceName, linkname, mutator)
@@ -851,3 +1304,317 @@ func (ts *TargetState) importHeader(r io.Reader, importTAROptions ImportTAROptio
 		return fmt.Errorf("%s: unspported typeflag '%c'", header.Name, header.Typeflag)
 	}
 }
+
+// importTargetName returns the target name of the archive entry name.
+func (ts *TargetState) importTargetName(name string, importTAROptions ImportTAROptions) (string, error) {
+	targetPath := name
+	if importTAROptions.StripComponents > 0 {
+		components := strings.Split(targetPath, "/")
+		if len(components) < importTAROptions.StripComponents {
+			components = nil
+		} else {
+			components = components[importTAROptions.StripComponents:]
+		}
+		targetPath = filepath.Join(components...)
+	}
+	if importTAROptions.DestinationDir != "" {
+		targetPath = filepath.Join(importTAROptions.DestinationDir, targetPath)
+	} else {
+		targetPath = filepath.Join(ts.DestDir, targetPath)
+	}
+	targetName, err := filepath.Rel(ts.DestDir, targetPath)
+	if err != nil {
+		return "", err
+	}
+	if targetName == ".." || strings.HasPrefix(targetName, ".."+string(filepath.Separator)) || filepath.IsAbs(targetName) {
+		return "", fmt.Errorf("%s: outside target directory", targetPath)
+	}
+	return targetName, nil
+}
+
+// inSourceDir returns true if entry is in ts.SourceDir, rather than in one of
+// ts.BaseSourceDirs.
+func (ts *TargetState) inSourceDir(entry Entry) bool {
+	_, ok := ts.layerDirs[entry]
+	return !ok
+}
+
+// isInOrContainsSourceDir returns true if path is, is in, or contains any
+// source directory.
+func (ts *TargetState) isInOrContainsSourceDir(path string) bool {
+	for _, sourceDir := range ts.sourceDirs() {
+		if path == sourceDir || strings.HasPrefix(path, sourceDir+string(filepath.Separator)) || strings.HasPrefix(sourceDir, path+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTemplate returns a new template with the given name and ts's template
+// options and functions.
+func (ts *TargetState) newTemplate(name string) *template.Template {
+	return template.New(name).Option(ts.TemplateOptions...).Funcs(template.FuncMap{
+		"includeTemplate": ts.executeTemplateWithArgs,
+	}).Funcs(ts.TemplateFuncs)
+}
+
+// parseTemplate parses data as the template name, using the delimiters and
+// options set by any template directives in data.
+func (ts *TargetState) parseTemplate(name string, data []byte) (*template.Template, error) {
+	td, data, err := parseTemplateDirective(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return ts.newTemplate(name).
+		Option(td.options...).
+		Delims(td.leftDelimiter, td.rightDelimiter).
+		Parse(string(data))
+}
+
+// enterSourceDir adds the template data files, unless targetDir is ignored,
+// and the ignore file in the source directory at path, whose target is
+// targetDir, to ts. They are added before anything else in the directory so
+// that they apply to the templates in the rest of it.
+func (ts *TargetState) enterSourceDir(fs vfs.FS, path, targetDir string) error {
+	if targetDir == "." || !ts.TargetIgnore.Match(AsDir(targetDir)) {
+		if err := ts.addTemplateDataDir(fs, path); err != nil {
+			return err
+		}
+	}
+	ignorePath := filepath.Join(path, ignoreName)
+	switch info, err := fs.Stat(ignorePath); {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case !info.Mode().IsRegular():
+		return nil
+	default:
+		return ts.addPatterns(fs, ts.TargetIgnore, ignorePath, filepath.Join(targetDir, ignoreName))
+	}
+}
+
+// populateSourceDir walks fs from sourceDir to populate ts.
+func (ts *TargetState) populateSourceDir(fs vfs.FS, sourceDir string, options *PopulateOptions) error {
+	// Record the source paths of all target names so that duplicates, for
+	// example dot_bashrc and dot_bashrc.tmpl, can be detected.
+	sourcePaths := make(map[string][]string)
+	addEntry := func(entries map[string]Entry, name, path string, entry Entry) {
+		sourcePaths[entry.TargetName()] = append(sourcePaths[entry.TargetName()], path)
+		// If this is the first entry for the target name in sourceDir then
+		// any existing entry is from an earlier source directory, so
+		// override it, keeping the existing entries if both are directories.
+		if existingEntry, ok := entries[name]; ok && len(sourcePaths[entry.TargetName()]) == 1 {
+			if existingDir, ok := existingEntry.(*Dir); ok {
+				if dir, ok := entry.(*Dir); ok {
+					dir.Entries = existingDir.Entries
+				}
+			}
+			if ts.overrides == nil {
+				ts.overrides = make(map[Entry][]string)
+			}
+			ts.overrides[entry] = ts.SourcePaths(existingEntry)
+			delete(ts.layerDirs, existingEntry)
+			delete(ts.overrides, existingEntry)
+		}
+		entries[name] = entry
+		if sourceDir != ts.SourceDir {
+			if ts.layerDirs == nil {
+				ts.layerDirs = make(map[Entry]string)
+			}
+			ts.layerDirs[entry] = sourceDir
+		}
+	}
+	if err := walk(fs, sourceDir, func(path string, info os.FileInfo, _ error) error {
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			if info == nil {
+				return nil
+			}
+			return ts.enterSourceDir(fs, path, ".")
+		}
+		// Treat all files and directories beginning with "." specially.
+		if _, name := filepath.Split(relPath); strings.HasPrefix(name, ".") {
+			switch {
+			case info.Name() == attributesName:
+				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+				return ts.addOwnershipPatterns(fs, path, filepath.Join(dns...))
+			case info.Name() == ignoreName:
+				// Ignore files are added by enterSourceDir.
+				return nil
+			case info.Name() == removeName:
+				dns := dirNames(parseDirNameComponents(splitPathList(relPath)))
+				return ts.addPatterns(fs, ts.TargetRemove, path, filepath.Join(dns...))
+			case info.Name() == templatesDirName:
+				if err := ts.addTemplatesDir(fs, path); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			case info.Name() == versionName:
+				data, err := fs.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				version, err := semver.NewVersion(strings.TrimSpace(string(data)))
+				if err != nil {
+					return err
+				}
+				if ts.MinVersion == nil || ts.MinVersion.LessThan(*version) {
+					ts.MinVersion = version
+				}
+				return nil
+			case info.IsDir():
+				// Don't recurse into ignored subdirectories.
+				return filepath.SkipDir
+			}
+			// Ignore all other files and directories.
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			components := splitPathList(relPath)
+			das := parseDirNameComponents(components)
+			dns := dirNames(das)
+			targetName := filepath.Join(dns...)
+			entries, err := ts.findEntries(dns[:len(dns)-1])
+			if err != nil {
+				return err
+			}
+			da := das[len(das)-1]
+			addEntry(entries, da.Name, path, newDir(relPath, targetName, da.Exact, da.Perm))
+			return ts.enterSourceDir(fs, path, targetName)
+		case info.Mode().IsRegular():
+			psfp := parseSourceFilePath(relPath)
+			dns := dirNames(psfp.dirAttributes)
+			entries, err := ts.findEntries(dns)
+			if err != nil {
+				return err
+			}
+			switch {
+			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == 0 || psfp.scriptAttributes != nil || psfp.blockAttributes != nil:
+				readFile := func() ([]byte, error) {
+					return fs.ReadFile(path)
+				}
+				evaluateContents := readFile
+				if psfp.fileAttributes != nil && psfp.fileAttributes.Encrypted || psfp.scriptAttributes != nil && psfp.scriptAttributes.Encrypted {
+					prevEvaluateContents := evaluateContents
+					evaluateContents = func() ([]byte, error) {
+						ciphertext, err := prevEvaluateContents()
+						if err != nil {
+							return nil, err
+						}
+						return ts.GPG.Decrypt(path, ciphertext)
+					}
+				}
+				if psfp.fileAttributes != nil && psfp.fileAttributes.Template || psfp.scriptAttributes != nil && psfp.scriptAttributes.Template || psfp.blockAttributes != nil && psfp.blockAttributes.Template {
+					if options == nil || options.ExecuteTemplates {
+						prevEvaluateContents := evaluateContents
+						evaluateContents = func() ([]byte, error) {
+							data, err := prevEvaluateContents()
+							if err != nil {
+								return nil, err
+							}
+							return ts.ExecuteTemplateData(path, data)
+						}
+					}
+				}
+				switch {
+				case psfp.fileAttributes != nil:
+					entry := &File{
+						sourceName:       relPath,
+						targetName:       filepath.Join(append(dns, psfp.fileAttributes.Name)...),
+						Create:           psfp.fileAttributes.Create,
+						Modify:           psfp.fileAttributes.Modify,
+						Merge:            psfp.fileAttributes.Merge,
+						Link:             psfp.fileAttributes.Link || ts.Mode == ModeSymlink,
+						Empty:            psfp.fileAttributes.Empty,
+						Encrypted:        psfp.fileAttributes.Encrypted,
+						Perm:             psfp.fileAttributes.Mode.Perm(),
+						Template:         psfp.fileAttributes.Template,
+						evaluateContents: evaluateContents,
+					}
+					if entry.Merge {
+						format, ok := ts.Formats[formatName(entry.targetName)]
+						if !ok {
+							return fmt.Errorf("%s: unsupported merge format", path)
+						}
+						entry.format = &format
+					}
+					addEntry(entries, psfp.fileAttributes.Name, path, entry)
+				case psfp.scriptAttributes != nil:
+					entry := &Script{
+						sourceName:       relPath,
+						targetName:       filepath.Join(append(dns, psfp.scriptAttributes.Name)...),
+						Encrypted:        psfp.scriptAttributes.Encrypted,
+						Once:             psfp.scriptAttributes.Once,
+						OnChange:         psfp.scriptAttributes.OnChange,
+						Before:           psfp.scriptAttributes.Before,
+						After:            psfp.scriptAttributes.After,
+						Template:         psfp.scriptAttributes.Template,
+						evaluateContents: evaluateContents,
+					}
+					addEntry(entries, psfp.scriptAttributes.Name, path, entry)
+				case psfp.blockAttributes != nil:
+					entry := &Block{
+						sourceName:       relPath,
+						targetName:       filepath.Join(append(dns, psfp.blockAttributes.Name)...),
+						Begin:            ts.BlockBegin,
+						End:              ts.BlockEnd,
+						Template:         psfp.blockAttributes.Template,
+						evaluateContents: evaluateContents,
+					}
+					addEntry(entries, psfp.blockAttributes.Name, path, entry)
+				}
+			case psfp.fileAttributes != nil && psfp.fileAttributes.Mode&os.ModeType == os.ModeSymlink:
+				evaluateLinkname := func() (string, error) {
+					data, err := fs.ReadFile(path)
+					return string(data), err
+				}
+				if psfp.fileAttributes.Template {
+					evaluateLinkname = func() (string, error) {
+						data, err := ts.executeTemplate(fs, path)
+						return string(data), err
+					}
+				}
+				entry := &Symlink{
+					sourceName:       relPath,
+					targetName:       filepath.Join(append(dns, psfp.fileAttributes.Name)...),
+					Template:         psfp.fileAttributes.Template,
+					evaluateLinkname: evaluateLinkname,
+				}
+				addEntry(entries, psfp.fileAttributes.Name, path, entry)
+			default:
+				return fmt.Errorf("%s: unsupported file type", path)
+			}
+		default:
+			return fmt.Errorf("%s: unsupported file type", path)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	var duplicateTargetNames []string
+	for targetName, paths := range sourcePaths {
+		if len(paths) > 1 {
+			duplicateTargetNames = append(duplicateTargetNames, targetName)
+		}
+	}
+	if len(duplicateTargetNames) == 0 {
+		return nil
+	}
+	sort.Strings(duplicateTargetNames)
+	duplicates := make([]string, 0, len(duplicateTargetNames))
+	for _, targetName := range duplicateTargetNames {
+		duplicates = append(duplicates, fmt.Sprintf("%s (%s)", targetName, strings.Join(sourcePaths[targetName], ", ")))
+	}
+	return fmt.Errorf("duplicate target names: %s", strings.Join(duplicates, "; "))
+}
+
+// sourceDirs returns all source directories, in the order in which they are
+// populated.
+func (ts *TargetState) sourceDirs() []string {
+	return append(append([]string(nil), ts.BaseSourceDirs...), ts.SourceDir)
+}
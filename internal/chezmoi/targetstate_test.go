@@ -1,6 +1,7 @@
 package chezmoi
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -365,14 +366,10 @@ func TestTargetStatePopulate(t *testing.T) {
 			want: NewTargetState(
 				WithDestDir("/"),
 				WithSourceDir("/"),
-				WithTargetIgnore(&PatternSet{
-					includes: map[string]struct{}{
-						"f*": {},
-					},
-					excludes: map[string]struct{}{
-						"g": {},
-					},
-				}),
+				WithTargetIgnore(mustNewPatternSet(t, ".",
+					"f*",
+					"!g",
+				)),
 			),
 		},
 		{
@@ -386,14 +383,10 @@ func TestTargetStatePopulate(t *testing.T) {
 			want: NewTargetState(
 				WithDestDir("/"),
 				WithSourceDir("/"),
-				WithTargetRemove(&PatternSet{
-					includes: map[string]struct{}{
-						"f*": {},
-					},
-					excludes: map[string]struct{}{
-						"g": {},
-					},
-				}),
+				WithTargetRemove(mustNewPatternSet(t, ".",
+					"f*",
+					"!g",
+				)),
 			),
 		},
 		{
@@ -415,14 +408,10 @@ func TestTargetStatePopulate(t *testing.T) {
 					},
 				}),
 				WithSourceDir("/"),
-				WithTargetIgnore(&PatternSet{
-					includes: map[string]struct{}{
-						filepath.Join("dir", "foo"): {},
-					},
-					excludes: map[string]struct{}{
-						filepath.Join("dir", "bar"): {},
-					},
-				}),
+				WithTargetIgnore(mustNewPatternSet(t, "dir",
+					"foo",
+					"!bar",
+				)),
 			),
 		},
 		{
@@ -484,7 +473,401 @@ func TestTargetStatePopulate(t *testing.T) {
 			)
 			assert.NoError(t, ts.Populate(fs, nil))
 			assert.NoError(t, ts.Evaluate())
+			// Templates contain functions, which cannot be compared, so
+			// compare their parse trees instead.
+			assert.Equal(t, templateTrees(tc.want.Templates), templateTrees(ts.Templates))
+			tc.want.Templates, ts.Templates = nil, nil
 			assert.Equal(t, tc.want, ts)
 		})
 	}
 }
+
+func TestTargetStatePopulateTemplateData(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/src": map[string]interface{}{
+			".chezmoiattributes":    "{{ .ignore }} owner=root\n",
+			".chezmoidata.json":     `{"ignore":"bar"}`,
+			".chezmoiignore":        "{{ .ignore }}\n",
+			"bar/.chezmoidata.json": `{"color":"red"}`,
+			"foo/.chezmoidata.json": `{"size":1}`,
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	formats := map[string]Format{
+		"json": {
+			Decode: json.Unmarshal,
+		},
+	}
+
+	ts := NewTargetState(
+		WithFormats(formats),
+		WithSourceDir("/src"),
+		WithTemplateData(map[string]interface{}{
+			"size": 2,
+		}),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	assert.True(t, ts.TargetIgnore.Match(AsDir("bar")))
+	assert.Equal(t, Ownership{Owner: "root"}, ts.ownership("bar"))
+	assert.Equal(t, map[string]interface{}{
+		"ignore": "bar",
+		"size":   2,
+	}, ts.TemplateData)
+
+	ts = NewTargetState(
+		WithFormats(formats),
+		WithSourceDir("/src"),
+	)
+	require.NoError(t, ts.PopulateTemplateData(fs))
+	assert.Equal(t, map[string]interface{}{
+		"color":  "red",
+		"ignore": "bar",
+		"size":   1.0,
+	}, ts.TemplateData)
+}
+
+func TestTargetStateIncludeTemplateRecursive(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/src": map[string]interface{}{
+			".chezmoitemplates": map[string]interface{}{
+				"bar": `{{ includeTemplate "baz" }}`,
+				"baz": `{{ includeTemplate "bar" }}`,
+				"foo": `{{ includeTemplate "bar" }}`,
+				"qux": `qux`,
+			},
+			"dot_bashrc.tmpl":  `{{ includeTemplate "foo" }}`,
+			"dot_profile.tmpl": `{{ includeTemplate "baz" }}`,
+			"dot_zshrc.tmpl":   `{{ includeTemplate "qux" }}{{ includeTemplate "qux" }}`,
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+
+	ts := NewTargetState(
+		WithSourceDir("/src"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	_, err = ts.Entries[".bashrc"].(*File).Contents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bar: recursive includeTemplate (foo -> bar -> baz -> bar)")
+	assert.Empty(t, ts.includeStack)
+	_, err = ts.Entries[".profile"].(*File).Contents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "baz: recursive includeTemplate (baz -> bar -> baz)")
+	contents, err := ts.Entries[".zshrc"].(*File).Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "quxqux", string(contents))
+}
+
+func TestTargetStatePopulateDuplicateTargetNames(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		root    interface{}
+		wantErr string
+	}{
+		{
+			name: "file_and_template",
+			root: map[string]interface{}{
+				"/dot_bashrc":      "",
+				"/dot_bashrc.tmpl": "",
+			},
+			wantErr: "duplicate target names: .bashrc (/dot_bashrc, /dot_bashrc.tmpl)",
+		},
+		{
+			name: "file_attributes",
+			root: map[string]interface{}{
+				"/empty_foo":      "",
+				"/encrypted_foo":  "",
+				"/executable_foo": "",
+				"/foo":            "",
+				"/private_foo":    "",
+			},
+			wantErr: "duplicate target names: foo (/empty_foo, /encrypted_foo, /executable_foo, /foo, /private_foo)",
+		},
+		{
+			name: "file_and_create_modify_and_merge",
+			root: map[string]interface{}{
+				"/create_foo.json": "",
+				"/foo.json":        "",
+				"/merge_foo.json":  "",
+				"/modify_foo.json": "",
+			},
+			wantErr: "duplicate target names: foo.json (/create_foo.json, /foo.json, /merge_foo.json, /modify_foo.json)",
+		},
+		{
+			name: "file_and_block",
+			root: map[string]interface{}{
+				"/block_dot_bashrc": "",
+				"/dot_bashrc":       "",
+			},
+			wantErr: "duplicate target names: .bashrc (/block_dot_bashrc, /dot_bashrc)",
+		},
+		{
+			name: "file_and_symlink",
+			root: map[string]interface{}{
+				"/foo":         "",
+				"/symlink_foo": "bar",
+			},
+			wantErr: "duplicate target names: foo (/foo, /symlink_foo)",
+		},
+		{
+			name: "file_and_dir",
+			root: map[string]interface{}{
+				"/foo":         &vfst.Dir{Perm: 0755},
+				"/private_foo": "",
+			},
+			wantErr: "duplicate target names: foo (/foo, /private_foo)",
+		},
+		{
+			name: "dirs",
+			root: map[string]interface{}{
+				"/dot_ssh":         &vfst.Dir{Perm: 0755},
+				"/exact_dot_ssh":   &vfst.Dir{Perm: 0755},
+				"/private_dot_ssh": &vfst.Dir{Perm: 0755},
+			},
+			wantErr: "duplicate target names: .ssh (/dot_ssh, /exact_dot_ssh, /private_dot_ssh)",
+		},
+		{
+			name: "scripts",
+			root: map[string]interface{}{
+				"/run_after_foo":    "",
+				"/run_before_foo":   "",
+				"/run_foo":          "",
+				"/run_once_foo":     "",
+				"/run_onchange_foo": "",
+			},
+			wantErr: "duplicate target names: foo (/run_after_foo, /run_before_foo, /run_foo, /run_once_foo, /run_onchange_foo)",
+		},
+		{
+			name: "multiple",
+			root: map[string]interface{}{
+				"/dot_bashrc":      "",
+				"/dot_bashrc.tmpl": "",
+				"/dot_ssh": map[string]interface{}{
+					"config": "",
+				},
+				"/private_dot_ssh": map[string]interface{}{
+					"config": "",
+				},
+			},
+			wantErr: "duplicate target names: " +
+				".bashrc (/dot_bashrc, /dot_bashrc.tmpl); " +
+				".ssh (/dot_ssh, /private_dot_ssh); " +
+				filepath.Join(".ssh", "config") + " (/dot_ssh/config, /private_dot_ssh/config)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			ts := NewTargetState(
+				WithDestDir("/"),
+				WithFormats(map[string]Format{
+					"json": {},
+				}),
+				WithSourceDir("/"),
+			)
+			assert.EqualError(t, ts.Populate(fs, nil), tc.wantErr)
+		})
+	}
+}
+
+func TestTargetStateAddBaseSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/base/dot_config/htop/.keep":    "",
+		"/home/user/.config/htop/htoprc": "# contents of .config/htop/htoprc\n",
+		"/src":                           &vfst.Dir{Perm: 0755},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	ts := NewTargetState(
+		WithBaseSourceDirs([]string{"/base"}),
+		WithDestDir("/home/user"),
+		WithSourceDir("/src"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	require.NoError(t, ts.Add(fs, AddOptions{}, "/home/user/.config/htop/htoprc", nil, false, NewFSMutator(fs)))
+	// Both the parent directory and its parent are created in the source
+	// directory, so both are now there.
+	for _, targetName := range []string{".config", ".config/htop"} {
+		entry, err := ts.findEntry(targetName)
+		require.NoError(t, err)
+		assert.True(t, ts.inSourceDir(entry), targetName)
+	}
+	vfst.RunTests(t, fs, "",
+		vfst.TestPath("/src/dot_config/htop/htoprc",
+			vfst.TestContentsString("# contents of .config/htop/htoprc\n"),
+		),
+	)
+}
+
+func TestTargetStatePopulateBaseSourceDirs(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/base": map[string]interface{}{
+			".chezmoiignore":           "bar\nfoo\n",
+			".chezmoitemplates/header": "# base",
+			"bar":                      "bar",
+			"dot_bashrc":               "# base .bashrc\n",
+			"dot_profile":              "# base .profile\n",
+			"foo":                      "foo",
+			"private_dot_config/base":  "base",
+		},
+		"/src": map[string]interface{}{
+			".chezmoiignore":           "!foo\n",
+			".chezmoitemplates/header": "# src",
+			"dot_bashrc.tmpl":          "{{ template \"header\" }}\n",
+			"dot_config/src":           "src",
+		},
+	})
+	require.NoError(t, err)
+	defer cleanup()
+	ts := NewTargetState(
+		WithBaseSourceDirs([]string{"/base"}),
+		WithDestDir("/"),
+		WithSourceDir("/src"),
+	)
+	require.NoError(t, ts.Populate(fs, nil))
+	got, err := ts.ConcreteValue(true)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		&fileConcreteValue{
+			Type:       "file",
+			SourcePath: "/src/dot_bashrc.tmpl",
+			TargetPath: ".bashrc",
+			Perm:       0666,
+			Template:   true,
+			Contents:   "# src\n",
+			Overrides:  []string{"/base/dot_bashrc"},
+		},
+		&dirConcreteValue{
+			Type:       "dir",
+			SourcePath: "/src/dot_config",
+			TargetPath: ".config",
+			Perm:       0777,
+			Entries: []interface{}{
+				&fileConcreteValue{
+					Type:       "file",
+					SourcePath: "/base/private_dot_config/base",
+					TargetPath: ".config/base",
+					Perm:       0666,
+					Contents:   "base",
+					Overrides:  []string{},
+				},
+				&fileConcreteValue{
+					Type:       "file",
+					SourcePath: "/src/dot_config/src",
+					TargetPath: ".config/src",
+					Perm:       0666,
+					Contents:   "src",
+					Overrides:  []string{},
+				},
+			},
+			Overrides: []string{"/base/private_dot_config"},
+		},
+		&fileConcreteValue{
+			Type:       "file",
+			SourcePath: "/base/dot_profile",
+			TargetPath: ".profile",
+			Perm:       0666,
+			Contents:   "# base .profile\n",
+			Overrides:  []string{},
+		},
+		&fileConcreteValue{
+			Type:       "file",
+			SourcePath: "/base/foo",
+			TargetPath: "foo",
+			Perm:       0666,
+			Contents:   "foo",
+			Overrides:  []string{},
+		},
+	}, got)
+}
+
+func TestTargetStatePopulateOwnership(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		root    interface{}
+		want    map[string]Ownership
+		wantErr string
+	}{
+		{
+			name: "patterns",
+			root: map[string]interface{}{
+				"/src": map[string]interface{}{
+					".chezmoiattributes": "# comment\n" +
+						"etc owner=root group=root\n" +
+						"etc/sudoers group=wheel # trailing comment\n",
+					"etc/hosts":   "",
+					"etc/sudoers": "",
+					"foo":         "",
+				},
+			},
+			want: map[string]Ownership{
+				"etc":         {Owner: "root", Group: "root"},
+				"etc/hosts":   {Owner: "root", Group: "root"},
+				"etc/sudoers": {Owner: "root", Group: "wheel"},
+				"foo":         {},
+			},
+		},
+		{
+			name: "no_attributes",
+			root: map[string]interface{}{
+				"/src/.chezmoiattributes": "foo\n",
+			},
+			wantErr: "/src/.chezmoiattributes:1: foo: no attributes",
+		},
+		{
+			name: "invalid_attribute",
+			root: map[string]interface{}{
+				"/src/.chezmoiattributes": "\nfoo owner\n",
+			},
+			wantErr: "/src/.chezmoiattributes:2: owner: invalid attribute",
+		},
+		{
+			name: "unknown_attribute",
+			root: map[string]interface{}{
+				"/src/.chezmoiattributes": "foo mode=0644\n",
+			},
+			wantErr: "/src/.chezmoiattributes:1: mode: unknown attribute",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, cleanup, err := vfst.NewTestFS(tc.root)
+			require.NoError(t, err)
+			defer cleanup()
+			ts := NewTargetState(
+				WithDestDir("/"),
+				WithSourceDir("/src"),
+			)
+			err = ts.Populate(fs, nil)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			got := make(map[string]Ownership)
+			for _, entry := range ts.AllEntries() {
+				switch entry := entry.(type) {
+				case *Dir:
+					got[entry.TargetName()] = entry.Ownership
+				case *File:
+					got[entry.TargetName()] = entry.Ownership
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func templateTrees(templates map[string]*template.Template) map[string]string {
+	if templates == nil {
+		return nil
+	}
+	trees := make(map[string]string, len(templates))
+	for name, tmpl := range templates {
+		trees[name] = tmpl.Tree.Root.String()
+	}
+	return trees
+}
@@ -0,0 +1,109 @@
+package chezmoi
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const templateDirectivePrefix = "chezmoi:template:"
+
+var templateDirectiveArgRegexp = regexp.MustCompile(`(?:^|\s)([-a-z]+)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+// A templateDirective holds the delimiters and options set by the directive
+// lines in a template.
+type templateDirective struct {
+	leftDelimiter  string
+	rightDelimiter string
+	options        []string
+}
+
+// commentPrefixes are the prefixes of lines that are treated as comments when
+// looking for directive lines.
+var commentPrefixes = []string{
+	"#", "--", "//", ";", "<!--",
+}
+
+// parseTemplateDirective returns the directive set by the directive lines at
+// the start of data and data with those lines removed. Directive lines are
+// only recognized before the first line that is neither blank, a comment, nor
+// a directive, so text later in data that contains templateDirectivePrefix is
+// left unchanged.
+func parseTemplateDirective(data []byte) (*templateDirective, []byte, error) {
+	if !bytes.Contains(data, []byte(templateDirectivePrefix)) {
+		return &templateDirective{}, data, nil
+	}
+	td := &templateDirective{}
+	var result []byte
+	offset := 0
+	for lineNumber := 1; offset < len(data); lineNumber++ {
+		nextOffset := len(data)
+		if index := bytes.IndexByte(data[offset:], '\n'); index != -1 {
+			nextOffset = offset + index + 1
+		}
+		line := data[offset:nextOffset]
+		index := bytes.Index(line, []byte(templateDirectivePrefix))
+		if index == -1 {
+			if !isBlankOrComment(line) {
+				break
+			}
+			result = append(result, line...)
+			offset = nextOffset
+			continue
+		}
+		if err := td.parseArgs(bytes.TrimRight(line[index+len(templateDirectivePrefix):], "\r\n")); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		offset = nextOffset
+	}
+	return td, append(result, data[offset:]...), nil
+}
+
+// isBlankOrComment returns true if line is blank or starts with one of
+// commentPrefixes.
+func isBlankOrComment(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return true
+	}
+	for _, prefix := range commentPrefixes {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseArgs parses the key=value arguments of a single directive line into
+// td. Other text, such as the delimiters of the comment containing the
+// directive, is ignored.
+func (td *templateDirective) parseArgs(args []byte) error {
+	for _, m := range templateDirectiveArgRegexp.FindAllSubmatch(args, -1) {
+		key := string(m[1])
+		value := string(m[2])
+		if strings.HasPrefix(value, `"`) {
+			var err error
+			if value, err = strconv.Unquote(value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		switch key {
+		case "left-delimiter":
+			td.leftDelimiter = value
+		case "right-delimiter":
+			td.rightDelimiter = value
+		case "missingkey":
+			switch value {
+			case "default", "invalid", "zero", "error":
+				td.options = append(td.options, "missingkey="+value)
+			default:
+				return fmt.Errorf("%s: invalid value %q", key, value)
+			}
+		default:
+			return fmt.Errorf("%s: unknown template directive", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package chezmoi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetStateExecuteTemplateDataDirective(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "no_directive",
+			data: "{{ .foo }}\n",
+			want: "bar\n",
+		},
+		{
+			name: "delimiters",
+			data: "# chezmoi:template:left-delimiter=[[ right-delimiter=]]\n" +
+				"{{ .Values.name }}: [[ .foo ]]\n",
+			want: "{{ .Values.name }}: bar\n",
+		},
+		{
+			name: "quoted_delimiters",
+			data: "{{/* chezmoi:template:left-delimiter=\"<< \" right-delimiter=\" >>\" */}}\n" +
+				"<< .foo >>\n",
+			want: "bar\n",
+		},
+		{
+			name: "directive_after_comments",
+			data: "#!/bin/sh\n" +
+				"\n" +
+				"# comment\n" +
+				"# chezmoi:template:left-delimiter=[[\n" +
+				"[[ .foo }}\n",
+			want: "#!/bin/sh\n\n# comment\nbar\n",
+		},
+		{
+			name: "directive_in_middle",
+			data: "first\n" +
+				"# chezmoi:template:left-delimiter=[[\n" +
+				"{{ .foo }}\n",
+			want: "first\n# chezmoi:template:left-delimiter=[[\nbar\n",
+		},
+		{
+			name: "directive_after_key",
+			data: "\"key\": \"value\",\n" +
+				"// chezmoi:template:left-delimiter=[[\n" +
+				"{{ .foo }}\n",
+			want: "\"key\": \"value\",\n// chezmoi:template:left-delimiter=[[\nbar\n",
+		},
+		{
+			name: "directive_after_directives",
+			data: "# chezmoi:template:left-delimiter=[[ right-delimiter=]]\n" +
+				"[[ .foo ]]\n" +
+				"# chezmoi:template:missingkey=foo\n",
+			want: "bar\n# chezmoi:template:missingkey=foo\n",
+		},
+		{
+			name: "missingkey",
+			data: "# chezmoi:template:missingkey=zero\n" +
+				"{{ .missing }}\n",
+			want: "<no value>\n",
+		},
+		{
+			name:    "missingkey_default",
+			data:    "{{ .missing }}\n",
+			wantErr: `template: name:1:3: executing "name" at <.missing>: map has no entry for key "missing"`,
+		},
+		{
+			name:    "invalid_missingkey",
+			data:    "# chezmoi:template:missingkey=foo\n",
+			wantErr: `name: line 1: missingkey: invalid value "foo"`,
+		},
+		{
+			name:    "unknown_directive",
+			data:    "\n# chezmoi:template:foo=bar\n",
+			wantErr: "name: line 2: foo: unknown template directive",
+		},
+		{
+			name: "html_comment",
+			data: "<!-- chezmoi:template:left-delimiter=[[ right-delimiter=]] -->\n" +
+				"<p>{{ name }}</p><p>[[ .foo ]]</p>\n",
+			want: "<p>{{ name }}</p><p>bar</p>\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := NewTargetState(
+				WithTemplateData(map[string]interface{}{
+					"foo": "bar",
+				}),
+			)
+			got, err := ts.ExecuteTemplateData("name", []byte(tc.data))
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
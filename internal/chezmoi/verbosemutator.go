@@ -56,6 +56,18 @@ func (m *VerboseMutator) IdempotentCmdOutput(cmd *exec.Cmd) ([]byte, error) {
 	return output, err
 }
 
+// Lchown implements Mutator.Lchown.
+func (m *VerboseMutator) Lchown(name string, uid, gid int) error {
+	action := fmt.Sprintf("chown -h %s %s", ownerGroupString(uid, gid), MaybeShellQuote(name))
+	err := m.m.Lchown(name, uid, gid)
+	if err == nil {
+		_, _ = fmt.Fprintln(m.w, action)
+	} else {
+		_, _ = fmt.Fprintf(m.w, "%s: %v\n", action, err)
+	}
+	return err
+}
+
 // Mkdir implements Mutator.Mkdir.
 func (m *VerboseMutator) Mkdir(name string, perm os.FileMode) error {
 	action := fmt.Sprintf("mkdir -m %o %s", perm, MaybeShellQuote(name))
@@ -104,6 +116,16 @@ func (m *VerboseMutator) RunCmd(cmd *exec.Cmd) error {
 	return err
 }
 
+// RunScript implements Mutator.RunScript. The contents of scripts are written
+// by Script.Apply, so only errors are written.
+func (m *VerboseMutator) RunScript(name, dir string, data []byte) error {
+	err := m.m.RunScript(name, dir, data)
+	if err != nil {
+		_, _ = fmt.Fprintf(m.w, "%s: %v\n", MaybeShellQuote(name), err)
+	}
+	return err
+}
+
 // Stat implements Mutator.Stat.
 func (m *VerboseMutator) Stat(name string) (os.FileInfo, error) {
 	return m.m.Stat(name)